@@ -48,6 +48,36 @@ func (_m *RISPClient) Connect(ctx context.Context, opts ...grpc.CallOption) (_go
 	return r0, r1
 }
 
+// ListSequences provides a mock function with given fields: ctx, in, opts
+func (_m *RISPClient) ListSequences(ctx context.Context, in *_go.ListSequencesRequest, opts ...grpc.CallOption) (*_go.ListSequencesResponse, error) {
+	_va := make([]interface{}, len(opts))
+	for _i := range opts {
+		_va[_i] = opts[_i]
+	}
+	var _ca []interface{}
+	_ca = append(_ca, ctx, in)
+	_ca = append(_ca, _va...)
+	ret := _m.Called(_ca...)
+
+	var r0 *_go.ListSequencesResponse
+	if rf, ok := ret.Get(0).(func(context.Context, *_go.ListSequencesRequest, ...grpc.CallOption) *_go.ListSequencesResponse); ok {
+		r0 = rf(ctx, in, opts...)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*_go.ListSequencesResponse)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *_go.ListSequencesRequest, ...grpc.CallOption) error); ok {
+		r1 = rf(ctx, in, opts...)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // NewRISPClient creates a new instance of RISPClient. It also registers the testing.TB interface on the mock and a cleanup function to assert the mocks expectations.
 func NewRISPClient(t testing.TB) *RISPClient {
 	mock := &RISPClient{}
@@ -3,6 +3,7 @@
 package mocks
 
 import (
+	context "context"
 	_go "risp/api/proto/gen/pb-go/github.com/mschristensen/risp/api/build/go"
 
 	mock "github.com/stretchr/testify/mock"
@@ -29,6 +30,29 @@ func (_m *RISPServer) Connect(_a0 _go.RISP_ConnectServer) error {
 	return r0
 }
 
+// ListSequences provides a mock function with given fields: _a0, _a1
+func (_m *RISPServer) ListSequences(_a0 context.Context, _a1 *_go.ListSequencesRequest) (*_go.ListSequencesResponse, error) {
+	ret := _m.Called(_a0, _a1)
+
+	var r0 *_go.ListSequencesResponse
+	if rf, ok := ret.Get(0).(func(context.Context, *_go.ListSequencesRequest) *_go.ListSequencesResponse); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*_go.ListSequencesResponse)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *_go.ListSequencesRequest) error); ok {
+		r1 = rf(_a0, _a1)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // NewRISPServer creates a new instance of RISPServer. It also registers the testing.TB interface on the mock and a cleanup function to assert the mocks expectations.
 func NewRISPServer(t testing.TB) *RISPServer {
 	mock := &RISPServer{}
@@ -76,16 +76,133 @@ func (ConnectionState) EnumDescriptor() ([]byte, []int) {
 	return file_risp_proto_rawDescGZIP(), []int{0}
 }
 
+// Encoding is the encoding of the items sent by the server, which is negotiated in the handshake.
+type Encoding int32
+
+const (
+	// NONE sends each item in the payload of its own message.
+	Encoding_NONE Encoding = 0
+	// PACKED sends the items of a window in a batch, each as a varint.
+	Encoding_PACKED Encoding = 1
+	// DELTA sends the items of a window in a batch, each as the zigzag varint of its difference from the item before it.
+	Encoding_DELTA Encoding = 2
+	// GZIP sends the items of a window in a batch, packed as varints and compressed with gzip.
+	Encoding_GZIP Encoding = 3
+	// ZSTD sends the items of a window in a batch, packed as varints and compressed with zstd.
+	Encoding_ZSTD Encoding = 4
+)
+
+// Enum value maps for Encoding.
+var (
+	Encoding_name = map[int32]string{
+		0: "NONE",
+		1: "PACKED",
+		2: "DELTA",
+		3: "GZIP",
+		4: "ZSTD",
+	}
+	Encoding_value = map[string]int32{
+		"NONE":   0,
+		"PACKED": 1,
+		"DELTA":  2,
+		"GZIP":   3,
+		"ZSTD":   4,
+	}
+)
+
+func (x Encoding) Enum() *Encoding {
+	p := new(Encoding)
+	*p = x
+	return p
+}
+
+func (x Encoding) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Encoding) Descriptor() protoreflect.EnumDescriptor {
+	return file_risp_proto_enumTypes[1].Descriptor()
+}
+
+func (Encoding) Type() protoreflect.EnumType {
+	return &file_risp_proto_enumTypes[1]
+}
+
+func (x Encoding) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Encoding.Descriptor instead.
+func (Encoding) EnumDescriptor() ([]byte, []int) {
+	return file_risp_proto_rawDescGZIP(), []int{1}
+}
+
+// Direction is the direction of a recorded message, relative to the client or server that recorded it.
+type Direction int32
+
+const (
+	Direction_SENT     Direction = 0
+	Direction_RECEIVED Direction = 1
+)
+
+// Enum value maps for Direction.
+var (
+	Direction_name = map[int32]string{
+		0: "SENT",
+		1: "RECEIVED",
+	}
+	Direction_value = map[string]int32{
+		"SENT":     0,
+		"RECEIVED": 1,
+	}
+)
+
+func (x Direction) Enum() *Direction {
+	p := new(Direction)
+	*p = x
+	return p
+}
+
+func (x Direction) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Direction) Descriptor() protoreflect.EnumDescriptor {
+	return file_risp_proto_enumTypes[2].Descriptor()
+}
+
+func (Direction) Type() protoreflect.EnumType {
+	return &file_risp_proto_enumTypes[2]
+}
+
+func (x Direction) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Direction.Descriptor instead.
+func (Direction) EnumDescriptor() ([]byte, []int) {
+	return file_risp_proto_rawDescGZIP(), []int{2}
+}
+
 type ClientMessage struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	State  ConnectionState `protobuf:"varint,1,opt,name=state,proto3,enum=risp.v1.ConnectionState" json:"state,omitempty"`
-	Len    uint32          `protobuf:"varint,2,opt,name=len,proto3" json:"len,omitempty"`
-	Uuid   []byte          `protobuf:"bytes,3,opt,name=uuid,proto3" json:"uuid,omitempty"`
-	Window uint32          `protobuf:"varint,4,opt,name=window,proto3" json:"window,omitempty"`
-	Ack    uint32          `protobuf:"varint,5,opt,name=ack,proto3" json:"ack,omitempty"`
+	State      ConnectionState `protobuf:"varint,1,opt,name=state,proto3,enum=risp.v1.ConnectionState" json:"state,omitempty"`
+	Len        uint32          `protobuf:"varint,2,opt,name=len,proto3" json:"len,omitempty"`
+	Uuid       []byte          `protobuf:"bytes,3,opt,name=uuid,proto3" json:"uuid,omitempty"`
+	Window     uint32          `protobuf:"varint,4,opt,name=window,proto3" json:"window,omitempty"`
+	Ack        uint32          `protobuf:"varint,5,opt,name=ack,proto3" json:"ack,omitempty"`
+	Heartbeat  bool            `protobuf:"varint,6,opt,name=heartbeat,proto3" json:"heartbeat,omitempty"`
+	Version    uint32          `protobuf:"varint,7,opt,name=version,proto3" json:"version,omitempty"`
+	Features   uint64          `protobuf:"varint,8,opt,name=features,proto3" json:"features,omitempty"`
+	TransferId uint32          `protobuf:"varint,9,opt,name=transfer_id,json=transferId,proto3" json:"transfer_id,omitempty"`
+	RangeStart uint32          `protobuf:"varint,10,opt,name=range_start,json=rangeStart,proto3" json:"range_start,omitempty"`
+	RangeEnd   uint32          `protobuf:"varint,11,opt,name=range_end,json=rangeEnd,proto3" json:"range_end,omitempty"`
+	Live       bool            `protobuf:"varint,12,opt,name=live,proto3" json:"live,omitempty"`
+	Name       string          `protobuf:"bytes,13,opt,name=name,proto3" json:"name,omitempty"`
+	Encodings  []Encoding      `protobuf:"varint,14,rep,packed,name=encodings,proto3,enum=risp.v1.Encoding" json:"encodings,omitempty"`
 }
 
 func (x *ClientMessage) Reset() {
@@ -155,15 +272,87 @@ func (x *ClientMessage) GetAck() uint32 {
 	return 0
 }
 
+func (x *ClientMessage) GetHeartbeat() bool {
+	if x != nil {
+		return x.Heartbeat
+	}
+	return false
+}
+
+func (x *ClientMessage) GetVersion() uint32 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+func (x *ClientMessage) GetFeatures() uint64 {
+	if x != nil {
+		return x.Features
+	}
+	return 0
+}
+
+func (x *ClientMessage) GetTransferId() uint32 {
+	if x != nil {
+		return x.TransferId
+	}
+	return 0
+}
+
+func (x *ClientMessage) GetRangeStart() uint32 {
+	if x != nil {
+		return x.RangeStart
+	}
+	return 0
+}
+
+func (x *ClientMessage) GetRangeEnd() uint32 {
+	if x != nil {
+		return x.RangeEnd
+	}
+	return 0
+}
+
+func (x *ClientMessage) GetLive() bool {
+	if x != nil {
+		return x.Live
+	}
+	return false
+}
+
+func (x *ClientMessage) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *ClientMessage) GetEncodings() []Encoding {
+	if x != nil {
+		return x.Encodings
+	}
+	return nil
+}
+
 type ServerMessage struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	State    ConnectionState `protobuf:"varint,1,opt,name=state,proto3,enum=risp.v1.ConnectionState" json:"state,omitempty"`
-	Index    uint32          `protobuf:"varint,2,opt,name=index,proto3" json:"index,omitempty"`
-	Payload  uint32          `protobuf:"varint,3,opt,name=payload,proto3" json:"payload,omitempty"`
-	Checksum uint64          `protobuf:"varint,4,opt,name=checksum,proto3" json:"checksum,omitempty"`
+	State      ConnectionState `protobuf:"varint,1,opt,name=state,proto3,enum=risp.v1.ConnectionState" json:"state,omitempty"`
+	Index      uint32          `protobuf:"varint,2,opt,name=index,proto3" json:"index,omitempty"`
+	Payload    uint32          `protobuf:"varint,3,opt,name=payload,proto3" json:"payload,omitempty"`
+	Checksum   uint64          `protobuf:"varint,4,opt,name=checksum,proto3" json:"checksum,omitempty"`
+	Heartbeat  bool            `protobuf:"varint,5,opt,name=heartbeat,proto3" json:"heartbeat,omitempty"`
+	Version    uint32          `protobuf:"varint,6,opt,name=version,proto3" json:"version,omitempty"`
+	Features   uint64          `protobuf:"varint,7,opt,name=features,proto3" json:"features,omitempty"`
+	TransferId uint32          `protobuf:"varint,8,opt,name=transfer_id,json=transferId,proto3" json:"transfer_id,omitempty"`
+	Len        uint32          `protobuf:"varint,9,opt,name=len,proto3" json:"len,omitempty"`
+	Encoding   Encoding        `protobuf:"varint,10,opt,name=encoding,proto3,enum=risp.v1.Encoding" json:"encoding,omitempty"`
+	Items      []byte          `protobuf:"bytes,11,opt,name=items,proto3" json:"items,omitempty"`
+	Count      uint32          `protobuf:"varint,12,opt,name=count,proto3" json:"count,omitempty"`
+	Signature  []byte          `protobuf:"bytes,13,opt,name=signature,proto3" json:"signature,omitempty"`
 }
 
 func (x *ServerMessage) Reset() {
@@ -226,11 +415,394 @@ func (x *ServerMessage) GetChecksum() uint64 {
 	return 0
 }
 
+func (x *ServerMessage) GetHeartbeat() bool {
+	if x != nil {
+		return x.Heartbeat
+	}
+	return false
+}
+
+func (x *ServerMessage) GetVersion() uint32 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+func (x *ServerMessage) GetFeatures() uint64 {
+	if x != nil {
+		return x.Features
+	}
+	return 0
+}
+
+func (x *ServerMessage) GetTransferId() uint32 {
+	if x != nil {
+		return x.TransferId
+	}
+	return 0
+}
+
+func (x *ServerMessage) GetLen() uint32 {
+	if x != nil {
+		return x.Len
+	}
+	return 0
+}
+
+func (x *ServerMessage) GetEncoding() Encoding {
+	if x != nil {
+		return x.Encoding
+	}
+	return Encoding_NONE
+}
+
+func (x *ServerMessage) GetItems() []byte {
+	if x != nil {
+		return x.Items
+	}
+	return nil
+}
+
+func (x *ServerMessage) GetCount() uint32 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+func (x *ServerMessage) GetSignature() []byte {
+	if x != nil {
+		return x.Signature
+	}
+	return nil
+}
+
+type ListSequencesRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *ListSequencesRequest) Reset() {
+	*x = ListSequencesRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_risp_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ListSequencesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListSequencesRequest) ProtoMessage() {}
+
+func (x *ListSequencesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_risp_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListSequencesRequest.ProtoReflect.Descriptor instead.
+func (*ListSequencesRequest) Descriptor() ([]byte, []int) {
+	return file_risp_proto_rawDescGZIP(), []int{2}
+}
+
+type SequenceInfo struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Name     string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Len      uint32 `protobuf:"varint,2,opt,name=len,proto3" json:"len,omitempty"`
+	Checksum uint64 `protobuf:"varint,3,opt,name=checksum,proto3" json:"checksum,omitempty"`
+}
+
+func (x *SequenceInfo) Reset() {
+	*x = SequenceInfo{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_risp_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *SequenceInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SequenceInfo) ProtoMessage() {}
+
+func (x *SequenceInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_risp_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SequenceInfo.ProtoReflect.Descriptor instead.
+func (*SequenceInfo) Descriptor() ([]byte, []int) {
+	return file_risp_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *SequenceInfo) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *SequenceInfo) GetLen() uint32 {
+	if x != nil {
+		return x.Len
+	}
+	return 0
+}
+
+func (x *SequenceInfo) GetChecksum() uint64 {
+	if x != nil {
+		return x.Checksum
+	}
+	return 0
+}
+
+type ListSequencesResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Sequences []*SequenceInfo `protobuf:"bytes,1,rep,name=sequences,proto3" json:"sequences,omitempty"`
+}
+
+func (x *ListSequencesResponse) Reset() {
+	*x = ListSequencesResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_risp_proto_msgTypes[4]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ListSequencesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListSequencesResponse) ProtoMessage() {}
+
+func (x *ListSequencesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_risp_proto_msgTypes[4]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListSequencesResponse.ProtoReflect.Descriptor instead.
+func (*ListSequencesResponse) Descriptor() ([]byte, []int) {
+	return file_risp_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *ListSequencesResponse) GetSequences() []*SequenceInfo {
+	if x != nil {
+		return x.Sequences
+	}
+	return nil
+}
+
+// Sequence is a received sequence, as written by a client with the protobuf output format.
+type Sequence struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Items []uint32 `protobuf:"varint,1,rep,packed,name=items,proto3" json:"items,omitempty"`
+}
+
+func (x *Sequence) Reset() {
+	*x = Sequence{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_risp_proto_msgTypes[5]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Sequence) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Sequence) ProtoMessage() {}
+
+func (x *Sequence) ProtoReflect() protoreflect.Message {
+	mi := &file_risp_proto_msgTypes[5]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Sequence.ProtoReflect.Descriptor instead.
+func (*Sequence) Descriptor() ([]byte, []int) {
+	return file_risp_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *Sequence) GetItems() []uint32 {
+	if x != nil {
+		return x.Items
+	}
+	return nil
+}
+
+// TranscriptEntry is a message recorded by a client or a server, or the error with which a message was rejected.
+// A transcript is a file of entries, each preceded by its length as a varint.
+type TranscriptEntry struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// when the entry was recorded, in nanoseconds since the Unix epoch
+	Time int64 `protobuf:"varint,1,opt,name=time,proto3" json:"time,omitempty"`
+	// identifies the stream over which the message was sent or received
+	Stream    uint64    `protobuf:"varint,2,opt,name=stream,proto3" json:"stream,omitempty"`
+	Direction Direction `protobuf:"varint,3,opt,name=direction,proto3,enum=risp.v1.Direction" json:"direction,omitempty"`
+	// Types that are assignable to Event:
+	//	*TranscriptEntry_Client
+	//	*TranscriptEntry_Server
+	//	*TranscriptEntry_Error
+	Event isTranscriptEntry_Event `protobuf_oneof:"event"`
+}
+
+func (x *TranscriptEntry) Reset() {
+	*x = TranscriptEntry{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_risp_proto_msgTypes[6]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *TranscriptEntry) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TranscriptEntry) ProtoMessage() {}
+
+func (x *TranscriptEntry) ProtoReflect() protoreflect.Message {
+	mi := &file_risp_proto_msgTypes[6]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TranscriptEntry.ProtoReflect.Descriptor instead.
+func (*TranscriptEntry) Descriptor() ([]byte, []int) {
+	return file_risp_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *TranscriptEntry) GetTime() int64 {
+	if x != nil {
+		return x.Time
+	}
+	return 0
+}
+
+func (x *TranscriptEntry) GetStream() uint64 {
+	if x != nil {
+		return x.Stream
+	}
+	return 0
+}
+
+func (x *TranscriptEntry) GetDirection() Direction {
+	if x != nil {
+		return x.Direction
+	}
+	return Direction_SENT
+}
+
+func (m *TranscriptEntry) GetEvent() isTranscriptEntry_Event {
+	if m != nil {
+		return m.Event
+	}
+	return nil
+}
+
+func (x *TranscriptEntry) GetClient() *ClientMessage {
+	if x, ok := x.GetEvent().(*TranscriptEntry_Client); ok {
+		return x.Client
+	}
+	return nil
+}
+
+func (x *TranscriptEntry) GetServer() *ServerMessage {
+	if x, ok := x.GetEvent().(*TranscriptEntry_Server); ok {
+		return x.Server
+	}
+	return nil
+}
+
+func (x *TranscriptEntry) GetError() string {
+	if x, ok := x.GetEvent().(*TranscriptEntry_Error); ok {
+		return x.Error
+	}
+	return ""
+}
+
+type isTranscriptEntry_Event interface {
+	isTranscriptEntry_Event()
+}
+
+type TranscriptEntry_Client struct {
+	Client *ClientMessage `protobuf:"bytes,4,opt,name=client,proto3,oneof"`
+}
+
+type TranscriptEntry_Server struct {
+	Server *ServerMessage `protobuf:"bytes,5,opt,name=server,proto3,oneof"`
+}
+
+type TranscriptEntry_Error struct {
+	// the error with which the recording side rejected the last message it received, ending the stream,
+	// or the error with which the other side ended the stream
+	Error string `protobuf:"bytes,6,opt,name=error,proto3,oneof"`
+}
+
+func (*TranscriptEntry_Client) isTranscriptEntry_Event() {}
+
+func (*TranscriptEntry_Server) isTranscriptEntry_Event() {}
+
+func (*TranscriptEntry_Error) isTranscriptEntry_Event() {}
+
 var File_risp_proto protoreflect.FileDescriptor
 
 var file_risp_proto_rawDesc = []byte{
 	0x0a, 0x0a, 0x72, 0x69, 0x73, 0x70, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x07, 0x72, 0x69,
-	0x73, 0x70, 0x2e, 0x76, 0x31, 0x22, 0x8f, 0x01, 0x0a, 0x0d, 0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74,
+	0x73, 0x70, 0x2e, 0x76, 0x31, 0x22, 0x9b, 0x03, 0x0a, 0x0d, 0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74,
 	0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x12, 0x2e, 0x0a, 0x05, 0x73, 0x74, 0x61, 0x74, 0x65,
 	0x18, 0x01, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x18, 0x2e, 0x72, 0x69, 0x73, 0x70, 0x2e, 0x76, 0x31,
 	0x2e, 0x43, 0x6f, 0x6e, 0x6e, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x53, 0x74, 0x61, 0x74, 0x65,
@@ -239,28 +811,102 @@ var file_risp_proto_rawDesc = []byte{
 	0x64, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x04, 0x75, 0x75, 0x69, 0x64, 0x12, 0x16, 0x0a,
 	0x06, 0x77, 0x69, 0x6e, 0x64, 0x6f, 0x77, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x06, 0x77,
 	0x69, 0x6e, 0x64, 0x6f, 0x77, 0x12, 0x10, 0x0a, 0x03, 0x61, 0x63, 0x6b, 0x18, 0x05, 0x20, 0x01,
-	0x28, 0x0d, 0x52, 0x03, 0x61, 0x63, 0x6b, 0x22, 0x8b, 0x01, 0x0a, 0x0d, 0x53, 0x65, 0x72, 0x76,
-	0x65, 0x72, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x12, 0x2e, 0x0a, 0x05, 0x73, 0x74, 0x61,
-	0x74, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x18, 0x2e, 0x72, 0x69, 0x73, 0x70, 0x2e,
-	0x76, 0x31, 0x2e, 0x43, 0x6f, 0x6e, 0x6e, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x53, 0x74, 0x61,
-	0x74, 0x65, 0x52, 0x05, 0x73, 0x74, 0x61, 0x74, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x69, 0x6e, 0x64,
-	0x65, 0x78, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x05, 0x69, 0x6e, 0x64, 0x65, 0x78, 0x12,
-	0x18, 0x0a, 0x07, 0x70, 0x61, 0x79, 0x6c, 0x6f, 0x61, 0x64, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0d,
-	0x52, 0x07, 0x70, 0x61, 0x79, 0x6c, 0x6f, 0x61, 0x64, 0x12, 0x1a, 0x0a, 0x08, 0x63, 0x68, 0x65,
-	0x63, 0x6b, 0x73, 0x75, 0x6d, 0x18, 0x04, 0x20, 0x01, 0x28, 0x04, 0x52, 0x08, 0x63, 0x68, 0x65,
-	0x63, 0x6b, 0x73, 0x75, 0x6d, 0x2a, 0x49, 0x0a, 0x0f, 0x43, 0x6f, 0x6e, 0x6e, 0x65, 0x63, 0x74,
-	0x69, 0x6f, 0x6e, 0x53, 0x74, 0x61, 0x74, 0x65, 0x12, 0x0e, 0x0a, 0x0a, 0x43, 0x4f, 0x4e, 0x4e,
-	0x45, 0x43, 0x54, 0x49, 0x4e, 0x47, 0x10, 0x00, 0x12, 0x0d, 0x0a, 0x09, 0x43, 0x4f, 0x4e, 0x4e,
-	0x45, 0x43, 0x54, 0x45, 0x44, 0x10, 0x01, 0x12, 0x0b, 0x0a, 0x07, 0x43, 0x4c, 0x4f, 0x53, 0x49,
-	0x4e, 0x47, 0x10, 0x02, 0x12, 0x0a, 0x0a, 0x06, 0x43, 0x4c, 0x4f, 0x53, 0x45, 0x44, 0x10, 0x03,
-	0x32, 0x45, 0x0a, 0x04, 0x52, 0x49, 0x53, 0x50, 0x12, 0x3d, 0x0a, 0x07, 0x43, 0x6f, 0x6e, 0x6e,
-	0x65, 0x63, 0x74, 0x12, 0x16, 0x2e, 0x72, 0x69, 0x73, 0x70, 0x2e, 0x76, 0x31, 0x2e, 0x43, 0x6c,
-	0x69, 0x65, 0x6e, 0x74, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x1a, 0x16, 0x2e, 0x72, 0x69,
-	0x73, 0x70, 0x2e, 0x76, 0x31, 0x2e, 0x53, 0x65, 0x72, 0x76, 0x65, 0x72, 0x4d, 0x65, 0x73, 0x73,
-	0x61, 0x67, 0x65, 0x28, 0x01, 0x30, 0x01, 0x42, 0x2c, 0x5a, 0x2a, 0x67, 0x69, 0x74, 0x68, 0x75,
-	0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x6d, 0x73, 0x63, 0x68, 0x72, 0x69, 0x73, 0x74, 0x65, 0x6e,
-	0x73, 0x65, 0x6e, 0x2f, 0x72, 0x69, 0x73, 0x70, 0x2f, 0x61, 0x70, 0x69, 0x2f, 0x62, 0x75, 0x69,
-	0x6c, 0x64, 0x2f, 0x67, 0x6f, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+	0x28, 0x0d, 0x52, 0x03, 0x61, 0x63, 0x6b, 0x12, 0x1c, 0x0a, 0x09, 0x68, 0x65, 0x61, 0x72, 0x74,
+	0x62, 0x65, 0x61, 0x74, 0x18, 0x06, 0x20, 0x01, 0x28, 0x08, 0x52, 0x09, 0x68, 0x65, 0x61, 0x72,
+	0x74, 0x62, 0x65, 0x61, 0x74, 0x12, 0x18, 0x0a, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e,
+	0x18, 0x07, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x12,
+	0x1a, 0x0a, 0x08, 0x66, 0x65, 0x61, 0x74, 0x75, 0x72, 0x65, 0x73, 0x18, 0x08, 0x20, 0x01, 0x28,
+	0x04, 0x52, 0x08, 0x66, 0x65, 0x61, 0x74, 0x75, 0x72, 0x65, 0x73, 0x12, 0x1f, 0x0a, 0x0b, 0x74,
+	0x72, 0x61, 0x6e, 0x73, 0x66, 0x65, 0x72, 0x5f, 0x69, 0x64, 0x18, 0x09, 0x20, 0x01, 0x28, 0x0d,
+	0x52, 0x0a, 0x74, 0x72, 0x61, 0x6e, 0x73, 0x66, 0x65, 0x72, 0x49, 0x64, 0x12, 0x1f, 0x0a, 0x0b,
+	0x72, 0x61, 0x6e, 0x67, 0x65, 0x5f, 0x73, 0x74, 0x61, 0x72, 0x74, 0x18, 0x0a, 0x20, 0x01, 0x28,
+	0x0d, 0x52, 0x0a, 0x72, 0x61, 0x6e, 0x67, 0x65, 0x53, 0x74, 0x61, 0x72, 0x74, 0x12, 0x1b, 0x0a,
+	0x09, 0x72, 0x61, 0x6e, 0x67, 0x65, 0x5f, 0x65, 0x6e, 0x64, 0x18, 0x0b, 0x20, 0x01, 0x28, 0x0d,
+	0x52, 0x08, 0x72, 0x61, 0x6e, 0x67, 0x65, 0x45, 0x6e, 0x64, 0x12, 0x12, 0x0a, 0x04, 0x6c, 0x69,
+	0x76, 0x65, 0x18, 0x0c, 0x20, 0x01, 0x28, 0x08, 0x52, 0x04, 0x6c, 0x69, 0x76, 0x65, 0x12, 0x12,
+	0x0a, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x0d, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61,
+	0x6d, 0x65, 0x12, 0x2f, 0x0a, 0x09, 0x65, 0x6e, 0x63, 0x6f, 0x64, 0x69, 0x6e, 0x67, 0x73, 0x18,
+	0x0e, 0x20, 0x03, 0x28, 0x0e, 0x32, 0x11, 0x2e, 0x72, 0x69, 0x73, 0x70, 0x2e, 0x76, 0x31, 0x2e,
+	0x45, 0x6e, 0x63, 0x6f, 0x64, 0x69, 0x6e, 0x67, 0x52, 0x09, 0x65, 0x6e, 0x63, 0x6f, 0x64, 0x69,
+	0x6e, 0x67, 0x73, 0x22, 0x8b, 0x03, 0x0a, 0x0d, 0x53, 0x65, 0x72, 0x76, 0x65, 0x72, 0x4d, 0x65,
+	0x73, 0x73, 0x61, 0x67, 0x65, 0x12, 0x2e, 0x0a, 0x05, 0x73, 0x74, 0x61, 0x74, 0x65, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x0e, 0x32, 0x18, 0x2e, 0x72, 0x69, 0x73, 0x70, 0x2e, 0x76, 0x31, 0x2e, 0x43,
+	0x6f, 0x6e, 0x6e, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x53, 0x74, 0x61, 0x74, 0x65, 0x52, 0x05,
+	0x73, 0x74, 0x61, 0x74, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x69, 0x6e, 0x64, 0x65, 0x78, 0x18, 0x02,
+	0x20, 0x01, 0x28, 0x0d, 0x52, 0x05, 0x69, 0x6e, 0x64, 0x65, 0x78, 0x12, 0x18, 0x0a, 0x07, 0x70,
+	0x61, 0x79, 0x6c, 0x6f, 0x61, 0x64, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x07, 0x70, 0x61,
+	0x79, 0x6c, 0x6f, 0x61, 0x64, 0x12, 0x1a, 0x0a, 0x08, 0x63, 0x68, 0x65, 0x63, 0x6b, 0x73, 0x75,
+	0x6d, 0x18, 0x04, 0x20, 0x01, 0x28, 0x04, 0x52, 0x08, 0x63, 0x68, 0x65, 0x63, 0x6b, 0x73, 0x75,
+	0x6d, 0x12, 0x1c, 0x0a, 0x09, 0x68, 0x65, 0x61, 0x72, 0x74, 0x62, 0x65, 0x61, 0x74, 0x18, 0x05,
+	0x20, 0x01, 0x28, 0x08, 0x52, 0x09, 0x68, 0x65, 0x61, 0x72, 0x74, 0x62, 0x65, 0x61, 0x74, 0x12,
+	0x18, 0x0a, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x06, 0x20, 0x01, 0x28, 0x0d,
+	0x52, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x1a, 0x0a, 0x08, 0x66, 0x65, 0x61,
+	0x74, 0x75, 0x72, 0x65, 0x73, 0x18, 0x07, 0x20, 0x01, 0x28, 0x04, 0x52, 0x08, 0x66, 0x65, 0x61,
+	0x74, 0x75, 0x72, 0x65, 0x73, 0x12, 0x1f, 0x0a, 0x0b, 0x74, 0x72, 0x61, 0x6e, 0x73, 0x66, 0x65,
+	0x72, 0x5f, 0x69, 0x64, 0x18, 0x08, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x0a, 0x74, 0x72, 0x61, 0x6e,
+	0x73, 0x66, 0x65, 0x72, 0x49, 0x64, 0x12, 0x10, 0x0a, 0x03, 0x6c, 0x65, 0x6e, 0x18, 0x09, 0x20,
+	0x01, 0x28, 0x0d, 0x52, 0x03, 0x6c, 0x65, 0x6e, 0x12, 0x2d, 0x0a, 0x08, 0x65, 0x6e, 0x63, 0x6f,
+	0x64, 0x69, 0x6e, 0x67, 0x18, 0x0a, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x11, 0x2e, 0x72, 0x69, 0x73,
+	0x70, 0x2e, 0x76, 0x31, 0x2e, 0x45, 0x6e, 0x63, 0x6f, 0x64, 0x69, 0x6e, 0x67, 0x52, 0x08, 0x65,
+	0x6e, 0x63, 0x6f, 0x64, 0x69, 0x6e, 0x67, 0x12, 0x14, 0x0a, 0x05, 0x69, 0x74, 0x65, 0x6d, 0x73,
+	0x18, 0x0b, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x05, 0x69, 0x74, 0x65, 0x6d, 0x73, 0x12, 0x14, 0x0a,
+	0x05, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x18, 0x0c, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x05, 0x63, 0x6f,
+	0x75, 0x6e, 0x74, 0x12, 0x1c, 0x0a, 0x09, 0x73, 0x69, 0x67, 0x6e, 0x61, 0x74, 0x75, 0x72, 0x65,
+	0x18, 0x0d, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x09, 0x73, 0x69, 0x67, 0x6e, 0x61, 0x74, 0x75, 0x72,
+	0x65, 0x22, 0x16, 0x0a, 0x14, 0x4c, 0x69, 0x73, 0x74, 0x53, 0x65, 0x71, 0x75, 0x65, 0x6e, 0x63,
+	0x65, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x22, 0x50, 0x0a, 0x0c, 0x53, 0x65, 0x71,
+	0x75, 0x65, 0x6e, 0x63, 0x65, 0x49, 0x6e, 0x66, 0x6f, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d,
+	0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x10, 0x0a,
+	0x03, 0x6c, 0x65, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x03, 0x6c, 0x65, 0x6e, 0x12,
+	0x1a, 0x0a, 0x08, 0x63, 0x68, 0x65, 0x63, 0x6b, 0x73, 0x75, 0x6d, 0x18, 0x03, 0x20, 0x01, 0x28,
+	0x04, 0x52, 0x08, 0x63, 0x68, 0x65, 0x63, 0x6b, 0x73, 0x75, 0x6d, 0x22, 0x4c, 0x0a, 0x15, 0x4c,
+	0x69, 0x73, 0x74, 0x53, 0x65, 0x71, 0x75, 0x65, 0x6e, 0x63, 0x65, 0x73, 0x52, 0x65, 0x73, 0x70,
+	0x6f, 0x6e, 0x73, 0x65, 0x12, 0x33, 0x0a, 0x09, 0x73, 0x65, 0x71, 0x75, 0x65, 0x6e, 0x63, 0x65,
+	0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x15, 0x2e, 0x72, 0x69, 0x73, 0x70, 0x2e, 0x76,
+	0x31, 0x2e, 0x53, 0x65, 0x71, 0x75, 0x65, 0x6e, 0x63, 0x65, 0x49, 0x6e, 0x66, 0x6f, 0x52, 0x09,
+	0x73, 0x65, 0x71, 0x75, 0x65, 0x6e, 0x63, 0x65, 0x73, 0x22, 0x20, 0x0a, 0x08, 0x53, 0x65, 0x71,
+	0x75, 0x65, 0x6e, 0x63, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x69, 0x74, 0x65, 0x6d, 0x73, 0x18, 0x01,
+	0x20, 0x03, 0x28, 0x0d, 0x52, 0x05, 0x69, 0x74, 0x65, 0x6d, 0x73, 0x22, 0xf4, 0x01, 0x0a, 0x0f,
+	0x54, 0x72, 0x61, 0x6e, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12,
+	0x12, 0x0a, 0x04, 0x74, 0x69, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x03, 0x52, 0x04, 0x74,
+	0x69, 0x6d, 0x65, 0x12, 0x16, 0x0a, 0x06, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x04, 0x52, 0x06, 0x73, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x12, 0x30, 0x0a, 0x09, 0x64,
+	0x69, 0x72, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x12,
+	0x2e, 0x72, 0x69, 0x73, 0x70, 0x2e, 0x76, 0x31, 0x2e, 0x44, 0x69, 0x72, 0x65, 0x63, 0x74, 0x69,
+	0x6f, 0x6e, 0x52, 0x09, 0x64, 0x69, 0x72, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x30, 0x0a,
+	0x06, 0x63, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x16, 0x2e,
+	0x72, 0x69, 0x73, 0x70, 0x2e, 0x76, 0x31, 0x2e, 0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x4d, 0x65,
+	0x73, 0x73, 0x61, 0x67, 0x65, 0x48, 0x00, 0x52, 0x06, 0x63, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x12,
+	0x30, 0x0a, 0x06, 0x73, 0x65, 0x72, 0x76, 0x65, 0x72, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0b, 0x32,
+	0x16, 0x2e, 0x72, 0x69, 0x73, 0x70, 0x2e, 0x76, 0x31, 0x2e, 0x53, 0x65, 0x72, 0x76, 0x65, 0x72,
+	0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x48, 0x00, 0x52, 0x06, 0x73, 0x65, 0x72, 0x76, 0x65,
+	0x72, 0x12, 0x16, 0x0a, 0x05, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x18, 0x06, 0x20, 0x01, 0x28, 0x09,
+	0x48, 0x00, 0x52, 0x05, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x42, 0x07, 0x0a, 0x05, 0x65, 0x76, 0x65,
+	0x6e, 0x74, 0x2a, 0x49, 0x0a, 0x0f, 0x43, 0x6f, 0x6e, 0x6e, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e,
+	0x53, 0x74, 0x61, 0x74, 0x65, 0x12, 0x0e, 0x0a, 0x0a, 0x43, 0x4f, 0x4e, 0x4e, 0x45, 0x43, 0x54,
+	0x49, 0x4e, 0x47, 0x10, 0x00, 0x12, 0x0d, 0x0a, 0x09, 0x43, 0x4f, 0x4e, 0x4e, 0x45, 0x43, 0x54,
+	0x45, 0x44, 0x10, 0x01, 0x12, 0x0b, 0x0a, 0x07, 0x43, 0x4c, 0x4f, 0x53, 0x49, 0x4e, 0x47, 0x10,
+	0x02, 0x12, 0x0a, 0x0a, 0x06, 0x43, 0x4c, 0x4f, 0x53, 0x45, 0x44, 0x10, 0x03, 0x2a, 0x3f, 0x0a,
+	0x08, 0x45, 0x6e, 0x63, 0x6f, 0x64, 0x69, 0x6e, 0x67, 0x12, 0x08, 0x0a, 0x04, 0x4e, 0x4f, 0x4e,
+	0x45, 0x10, 0x00, 0x12, 0x0a, 0x0a, 0x06, 0x50, 0x41, 0x43, 0x4b, 0x45, 0x44, 0x10, 0x01, 0x12,
+	0x09, 0x0a, 0x05, 0x44, 0x45, 0x4c, 0x54, 0x41, 0x10, 0x02, 0x12, 0x08, 0x0a, 0x04, 0x47, 0x5a,
+	0x49, 0x50, 0x10, 0x03, 0x12, 0x08, 0x0a, 0x04, 0x5a, 0x53, 0x54, 0x44, 0x10, 0x04, 0x2a, 0x23,
+	0x0a, 0x09, 0x44, 0x69, 0x72, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x08, 0x0a, 0x04, 0x53,
+	0x45, 0x4e, 0x54, 0x10, 0x00, 0x12, 0x0c, 0x0a, 0x08, 0x52, 0x45, 0x43, 0x45, 0x49, 0x56, 0x45,
+	0x44, 0x10, 0x01, 0x32, 0x95, 0x01, 0x0a, 0x04, 0x52, 0x49, 0x53, 0x50, 0x12, 0x3d, 0x0a, 0x07,
+	0x43, 0x6f, 0x6e, 0x6e, 0x65, 0x63, 0x74, 0x12, 0x16, 0x2e, 0x72, 0x69, 0x73, 0x70, 0x2e, 0x76,
+	0x31, 0x2e, 0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x1a,
+	0x16, 0x2e, 0x72, 0x69, 0x73, 0x70, 0x2e, 0x76, 0x31, 0x2e, 0x53, 0x65, 0x72, 0x76, 0x65, 0x72,
+	0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x28, 0x01, 0x30, 0x01, 0x12, 0x4e, 0x0a, 0x0d, 0x4c,
+	0x69, 0x73, 0x74, 0x53, 0x65, 0x71, 0x75, 0x65, 0x6e, 0x63, 0x65, 0x73, 0x12, 0x1d, 0x2e, 0x72,
+	0x69, 0x73, 0x70, 0x2e, 0x76, 0x31, 0x2e, 0x4c, 0x69, 0x73, 0x74, 0x53, 0x65, 0x71, 0x75, 0x65,
+	0x6e, 0x63, 0x65, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x1e, 0x2e, 0x72, 0x69,
+	0x73, 0x70, 0x2e, 0x76, 0x31, 0x2e, 0x4c, 0x69, 0x73, 0x74, 0x53, 0x65, 0x71, 0x75, 0x65, 0x6e,
+	0x63, 0x65, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x42, 0x2c, 0x5a, 0x2a, 0x67,
+	0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x6d, 0x73, 0x63, 0x68, 0x72, 0x69,
+	0x73, 0x74, 0x65, 0x6e, 0x73, 0x65, 0x6e, 0x2f, 0x72, 0x69, 0x73, 0x70, 0x2f, 0x61, 0x70, 0x69,
+	0x2f, 0x62, 0x75, 0x69, 0x6c, 0x64, 0x2f, 0x67, 0x6f, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f,
+	0x33,
 }
 
 var (
@@ -275,23 +921,38 @@ func file_risp_proto_rawDescGZIP() []byte {
 	return file_risp_proto_rawDescData
 }
 
-var file_risp_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
-var file_risp_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
+var file_risp_proto_enumTypes = make([]protoimpl.EnumInfo, 3)
+var file_risp_proto_msgTypes = make([]protoimpl.MessageInfo, 7)
 var file_risp_proto_goTypes = []interface{}{
-	(ConnectionState)(0),  // 0: risp.v1.ConnectionState
-	(*ClientMessage)(nil), // 1: risp.v1.ClientMessage
-	(*ServerMessage)(nil), // 2: risp.v1.ServerMessage
+	(ConnectionState)(0),          // 0: risp.v1.ConnectionState
+	(Encoding)(0),                 // 1: risp.v1.Encoding
+	(Direction)(0),                // 2: risp.v1.Direction
+	(*ClientMessage)(nil),         // 3: risp.v1.ClientMessage
+	(*ServerMessage)(nil),         // 4: risp.v1.ServerMessage
+	(*ListSequencesRequest)(nil),  // 5: risp.v1.ListSequencesRequest
+	(*SequenceInfo)(nil),          // 6: risp.v1.SequenceInfo
+	(*ListSequencesResponse)(nil), // 7: risp.v1.ListSequencesResponse
+	(*Sequence)(nil),              // 8: risp.v1.Sequence
+	(*TranscriptEntry)(nil),       // 9: risp.v1.TranscriptEntry
 }
 var file_risp_proto_depIdxs = []int32{
-	0, // 0: risp.v1.ClientMessage.state:type_name -> risp.v1.ConnectionState
-	0, // 1: risp.v1.ServerMessage.state:type_name -> risp.v1.ConnectionState
-	1, // 2: risp.v1.RISP.Connect:input_type -> risp.v1.ClientMessage
-	2, // 3: risp.v1.RISP.Connect:output_type -> risp.v1.ServerMessage
-	3, // [3:4] is the sub-list for method output_type
-	2, // [2:3] is the sub-list for method input_type
-	2, // [2:2] is the sub-list for extension type_name
-	2, // [2:2] is the sub-list for extension extendee
-	0, // [0:2] is the sub-list for field type_name
+	0,  // 0: risp.v1.ClientMessage.state:type_name -> risp.v1.ConnectionState
+	1,  // 1: risp.v1.ClientMessage.encodings:type_name -> risp.v1.Encoding
+	0,  // 2: risp.v1.ServerMessage.state:type_name -> risp.v1.ConnectionState
+	1,  // 3: risp.v1.ServerMessage.encoding:type_name -> risp.v1.Encoding
+	6,  // 4: risp.v1.ListSequencesResponse.sequences:type_name -> risp.v1.SequenceInfo
+	2,  // 5: risp.v1.TranscriptEntry.direction:type_name -> risp.v1.Direction
+	3,  // 6: risp.v1.TranscriptEntry.client:type_name -> risp.v1.ClientMessage
+	4,  // 7: risp.v1.TranscriptEntry.server:type_name -> risp.v1.ServerMessage
+	3,  // 8: risp.v1.RISP.Connect:input_type -> risp.v1.ClientMessage
+	5,  // 9: risp.v1.RISP.ListSequences:input_type -> risp.v1.ListSequencesRequest
+	4,  // 10: risp.v1.RISP.Connect:output_type -> risp.v1.ServerMessage
+	7,  // 11: risp.v1.RISP.ListSequences:output_type -> risp.v1.ListSequencesResponse
+	10, // [10:12] is the sub-list for method output_type
+	8,  // [8:10] is the sub-list for method input_type
+	8,  // [8:8] is the sub-list for extension type_name
+	8,  // [8:8] is the sub-list for extension extendee
+	0,  // [0:8] is the sub-list for field type_name
 }
 
 func init() { file_risp_proto_init() }
@@ -324,14 +985,79 @@ func file_risp_proto_init() {
 				return nil
 			}
 		}
+		file_risp_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ListSequencesRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_risp_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*SequenceInfo); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_risp_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ListSequencesResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_risp_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Sequence); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_risp_proto_msgTypes[6].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*TranscriptEntry); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	file_risp_proto_msgTypes[6].OneofWrappers = []interface{}{
+		(*TranscriptEntry_Client)(nil),
+		(*TranscriptEntry_Server)(nil),
+		(*TranscriptEntry_Error)(nil),
 	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_risp_proto_rawDesc,
-			NumEnums:      1,
-			NumMessages:   2,
+			NumEnums:      3,
+			NumMessages:   7,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
@@ -360,6 +1086,8 @@ const _ = grpc.SupportPackageIsVersion6
 type RISPClient interface {
 	// Connect establishes a connection over which data is exchanged.
 	Connect(ctx context.Context, opts ...grpc.CallOption) (RISP_ConnectClient, error)
+	// ListSequences lists the named sequences served by the server.
+	ListSequences(ctx context.Context, in *ListSequencesRequest, opts ...grpc.CallOption) (*ListSequencesResponse, error)
 }
 
 type rISPClient struct {
@@ -401,10 +1129,21 @@ func (x *rISPConnectClient) Recv() (*ServerMessage, error) {
 	return m, nil
 }
 
+func (c *rISPClient) ListSequences(ctx context.Context, in *ListSequencesRequest, opts ...grpc.CallOption) (*ListSequencesResponse, error) {
+	out := new(ListSequencesResponse)
+	err := c.cc.Invoke(ctx, "/risp.v1.RISP/ListSequences", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // RISPServer is the server API for RISP service.
 type RISPServer interface {
 	// Connect establishes a connection over which data is exchanged.
 	Connect(RISP_ConnectServer) error
+	// ListSequences lists the named sequences served by the server.
+	ListSequences(context.Context, *ListSequencesRequest) (*ListSequencesResponse, error)
 }
 
 // UnimplementedRISPServer can be embedded to have forward compatible implementations.
@@ -414,6 +1153,9 @@ type UnimplementedRISPServer struct {
 func (*UnimplementedRISPServer) Connect(RISP_ConnectServer) error {
 	return status.Errorf(codes.Unimplemented, "method Connect not implemented")
 }
+func (*UnimplementedRISPServer) ListSequences(context.Context, *ListSequencesRequest) (*ListSequencesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListSequences not implemented")
+}
 
 func RegisterRISPServer(s *grpc.Server, srv RISPServer) {
 	s.RegisterService(&_RISP_serviceDesc, srv)
@@ -445,10 +1187,33 @@ func (x *rISPConnectServer) Recv() (*ClientMessage, error) {
 	return m, nil
 }
 
+func _RISP_ListSequences_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListSequencesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RISPServer).ListSequences(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/risp.v1.RISP/ListSequences",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RISPServer).ListSequences(ctx, req.(*ListSequencesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 var _RISP_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "risp.v1.RISP",
 	HandlerType: (*RISPServer)(nil),
-	Methods:     []grpc.MethodDesc{},
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListSequences",
+			Handler:    _RISP_ListSequences_Handler,
+		},
+	},
 	Streams: []grpc.StreamDesc{
 		{
 			StreamName:    "Connect",
@@ -4,12 +4,20 @@ package main
 import (
 	"context"
 	"fmt"
+	"os/signal"
 	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"unicode"
 
 	"risp/internal"
 	"risp/internal/app/apps"
 	"risp/internal/app/cfg"
+	"risp/internal/pkg/conformance"
 	"risp/internal/pkg/log"
+	"risp/internal/pkg/output"
+	"risp/internal/pkg/tracing"
 
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
@@ -27,26 +35,93 @@ var (
 	}
 
 	clientCmd = &cobra.Command{
-		Use:   "client [sequence_length]",
+		Use:   "client [sequence_length|sequence_name...]",
 		Short: "Starts a RISP client.",
+		Long: "Starts a RISP client. A sequence is requested either by length, in which case the server generates a random sequence, " +
+			"or by the name of a sequence in the server's catalog. If several sequences are given, they are transferred concurrently over a single stream.",
 		Args: func(cmd *cobra.Command, args []string) error {
-			if len(args) <= 1 {
-				return nil
+			// the argument of a live stream is the number of items to receive, which is not limited to a uint16
+			bitSize := 16
+			if internal.Live {
+				bitSize = 32
 			}
-			_, err := strconv.ParseUint(args[0], 10, 16)
-			if err != nil {
-				return errors.Wrap(err, "parse sequence length argument failed")
+			for _, arg := range args {
+				if arg == "" {
+					return errors.New("empty sequence argument, want a sequence length or name")
+				}
+				// sequence names cannot start with a digit
+				if !internal.Live && !unicode.IsDigit(rune(arg[0])) {
+					continue
+				}
+				_, err := strconv.ParseUint(arg, 10, bitSize)
+				if err != nil {
+					return errors.Wrap(err, "parse sequence length argument failed")
+				}
 			}
 			return nil
 		},
 		RunE: runCmd,
 	}
 
+	listCmd = &cobra.Command{
+		Use:   "list",
+		Short: "Lists the named sequences served by a RISP server.",
+		Args:  cobra.NoArgs,
+		RunE:  runCmd,
+	}
+
+	benchCmd = &cobra.Command{
+		Use:   "bench",
+		Short: "Runs concurrent RISP clients against a server, and reports their throughput and latency.",
+		Long: "Runs concurrent RISP clients against a server, and reports the completed transfers, the failures by type, " +
+			"the items transferred per second and the percentiles of the time to complete a transfer.",
+		Args: cobra.NoArgs,
+		RunE: runCmd,
+	}
+
+	conformanceCmd = &cobra.Command{
+		Use:   "conformance",
+		Short: "Checks that RISP servers speak the protocol correctly.",
+		Args:  cobra.NoArgs,
+		RunE:  runCmd,
+	}
+
+	replayCmd = &cobra.Command{
+		Use:   "replay <transcript>",
+		Short: "Replays the transcript recorded by a RISP client or server.",
+		Long: "Replays the transcript recorded by a RISP client or server with --record, re-driving it with the messages it received " +
+			"to reproduce its state transitions. The command fails at the first message the replay sends or rejects differently.",
+		Args: cobra.ExactArgs(1),
+		RunE: runCmd,
+	}
+
+	inspectCmd = &cobra.Command{
+		Use:   "inspect <transcript>",
+		Short: "Prints the timeline of each session in the transcript recorded by a RISP client or server.",
+		Long: "Prints the timeline of each session in the transcript recorded by a RISP client or server with --record: " +
+			"its state transitions, the windows requested and the items sent for them, the retransmitted items, " +
+			"the gaps before each reconnect, and whether the checksum the server sent matches the items.",
+		Args: cobra.ExactArgs(1),
+		RunE: runCmd,
+	}
+
 	serverCmd = &cobra.Command{
 		Use:   "server",
 		Short: "Starts a RISP server.",
 		RunE:  runCmd,
 	}
+
+	configCmd = &cobra.Command{
+		Use:   "config",
+		Short: "Inspects the RISP configuration.",
+	}
+
+	configPrintCmd = &cobra.Command{
+		Use:   "print",
+		Short: "Prints the effective configuration, merged from the defaults, the config file, the environment and the flags, with the source of each value.",
+		Args:  cobra.NoArgs,
+		RunE:  printConfig,
+	}
 )
 
 func newApp(_ context.Context, cmd *cobra.Command) (apps.App, error) {
@@ -54,13 +129,67 @@ func newApp(_ context.Context, cmd *cobra.Command) (apps.App, error) {
 	var app apps.App
 	switch cmd.Name() {
 	case "client":
-		app, err = apps.NewClientApp(cfg.PortFromEnv())
+		app, err = apps.NewClientApp(
+			cfg.PortFromEnv(),
+			cfg.ServerAddrsFromEnv(),
+			cfg.RetryFromEnv(),
+			cfg.StateFileFromEnv(),
+			cfg.ParallelismFromEnv(),
+			cfg.LiveFromEnv(),
+			cfg.OutputFromEnv(),
+			cfg.RecordFromEnv(),
+			cfg.EncodingsFromEnv(),
+			cfg.VerifyKeyFromEnv(),
+			cfg.WebSocketFromEnv(),
+		)
 		if err != nil {
 			return nil, errors.Wrap(err, "new client app failed")
 		}
 		return app, nil
+	case "list":
+		app, err = apps.NewListApp(
+			cfg.PortFromEnv(),
+			cfg.ServerAddrsFromEnv(),
+		)
+		if err != nil {
+			return nil, errors.Wrap(err, "new list app failed")
+		}
+		return app, nil
+	case "bench":
+		app, err = apps.NewBenchApp(
+			cfg.PortFromEnv(),
+			cfg.ServerAddrsFromEnv(),
+			cfg.RetryFromEnv(),
+			cfg.BenchFromEnv(),
+		)
+		if err != nil {
+			return nil, errors.Wrap(err, "new bench app failed")
+		}
+		return app, nil
+	case "conformance":
+		app, err = apps.NewConformanceApp(
+			cfg.PortFromEnv(),
+			cfg.ServerAddrsFromEnv(),
+			cfg.ConformanceFromEnv(),
+		)
+		if err != nil {
+			return nil, errors.Wrap(err, "new conformance app failed")
+		}
+		return app, nil
+	case "replay":
+		app, err = apps.NewReplayApp()
+		if err != nil {
+			return nil, errors.Wrap(err, "new replay app failed")
+		}
+		return app, nil
+	case "inspect":
+		app, err = apps.NewInspectApp(cfg.InspectFromEnv())
+		if err != nil {
+			return nil, errors.Wrap(err, "new inspect app failed")
+		}
+		return app, nil
 	case "server":
-		app, err = apps.NewServerApp(cfg.PortFromEnv())
+		app, err = apps.NewServerApp(cfg.PortFromEnv(), cfg.LiveFromEnv(), cfg.DataDirFromEnv(), cfg.RecordFromEnv(), cfg.SigningKeyFromEnv(), cfg.HTTPPortFromEnv(), cfg.WebSocketFromEnv(), cfg.SessionTTLFromEnv(), cfg.AdminPortFromEnv())
 		if err != nil {
 			return nil, errors.Wrap(err, "new server app failed")
 		}
@@ -71,14 +200,30 @@ func newApp(_ context.Context, cmd *cobra.Command) (apps.App, error) {
 }
 
 func runCmd(cmd *cobra.Command, args []string) error {
-	ctx, cancel := context.WithCancel(cmd.Context())
+	// stop the app when terminated, so that the remaining spans are flushed on the way out
+	ctx, cancel := signal.NotifyContext(cmd.Context(), syscall.SIGTERM)
 	defer cancel()
+	if err := internal.LoadConfig(cmd); err != nil {
+		return errors.Wrap(err, "load config failed")
+	}
 	if err := chainedCheck(
 		ctx,
 		envCheck,
 	); err != nil {
 		return errors.Wrap(err, "chained check failed")
 	}
+	shutdown, err := tracing.Setup(ctx, "risp-"+cmd.Name(),
+		tracing.WithExporter(internal.TraceExporter, internal.TraceFile, internal.TraceEndpoint),
+	)
+	if err != nil {
+		return errors.Wrap(err, "set up tracing failed")
+	}
+	defer func() {
+		// flush the remaining spans even if the command was cancelled
+		if err := shutdown(context.Background()); err != nil {
+			logger.Warning(errors.Wrap(err, "shut down tracing failed"))
+		}
+	}()
 	app, err := newApp(cmd.Context(), cmd)
 	if err != nil {
 		return errors.Wrapf(err, "new %s app failed", cmd.Name())
@@ -86,12 +231,51 @@ func runCmd(cmd *cobra.Command, args []string) error {
 	return errors.Wrap(app.Run(ctx, args), "run app failed")
 }
 
+// printConfig prints the effective value of every flag, and where it was set from.
+// The configuration is printed even if it is invalid, so that the invalid values can be traced to their source.
+func printConfig(cmd *cobra.Command, _ []string) error {
+	if err := internal.LoadConfig(cmd); err != nil {
+		return errors.Wrap(err, "load config failed")
+	}
+	w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tVALUE\tSOURCE")
+	for _, s := range internal.Settings() {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", s.Name, s.Value, s.Source)
+	}
+	if err := w.Flush(); err != nil {
+		return errors.Wrap(err, "print config failed")
+	}
+	return errors.Wrap(internal.ValidateEnv(), "validate env failed")
+}
+
+// conformanceHelp describes the conformance command and the scenarios it runs.
+func conformanceHelp() string {
+	var b strings.Builder
+	b.WriteString("Checks that RISP servers speak the protocol correctly, by driving scripted scenarios against each server " +
+		"and reporting whether each passed. The command fails if any scenario fails. The scenarios are:\n")
+	for _, s := range conformance.Scenarios() {
+		fmt.Fprintf(&b, "\n  %-34s %s", s.Name, s.Description)
+	}
+	return b.String()
+}
+
 func envCheck(ctx context.Context) error {
 	err := internal.ValidateEnv()
 	if err != nil {
 		return errors.Wrap(err, "validate env failed")
 	}
-	log.SetLogger(internal.LogLevel)
+	err = log.SetLogger(
+		internal.LogLevel,
+		log.WithFormat(internal.LogFormat),
+		log.WithSampling(internal.LogSample),
+		log.WithFile(internal.LogFile, internal.LogMaxSizeMB, internal.LogMaxBackups),
+	)
+	if err != nil {
+		return errors.Wrap(err, "set logger failed")
+	}
+	if internal.TraceExporter == tracing.Stdout && internal.Output == output.Stdout {
+		return errors.New("the sequence and the spans cannot both be written to stdout")
+	}
 	return nil
 }
 
@@ -107,35 +291,115 @@ func chainedCheck(ctx context.Context, checks ...func(context.Context) error) er
 
 func init() {
 	err := internal.RegisterCommandFlags(rootCmd, []*internal.Flag{
+		&internal.ConfigFlag,
 		&internal.EnvFlag,
 		&internal.LogLevelFlag,
+		&internal.LogFormatFlag,
+		&internal.LogSampleFlag,
+		&internal.LogFileFlag,
+		&internal.LogMaxSizeMBFlag,
+		&internal.LogMaxBackupsFlag,
+		&internal.TraceExporterFlag,
+		&internal.TraceFileFlag,
+		&internal.TraceEndpointFlag,
 
 		&internal.HealthPortFlag,
 		&internal.PortFlag,
 
 		&internal.MaxGoroutinesFlag,
+
+		&internal.HeartbeatIntervalFlag,
+		&internal.IdleTimeoutFlag,
 	})
 	if err != nil {
 		logger.Fatalln(err)
 	}
 
 	err = internal.RegisterCommandFlags(clientCmd, []*internal.Flag{
-		&internal.ClientTickerMSFlag,
-		&internal.ClientKillswitchMSFlag,
+		&internal.ServerAddrFlag,
+		&internal.ClientTickerFlag,
+		&internal.ClientKillswitchFlag,
+		&internal.RetryMaxAttemptsFlag,
+		&internal.RetryMaxElapsedFlag,
+		&internal.StateFileFlag,
+		&internal.ParallelismFlag,
+		&internal.LiveFlag,
+		&internal.OutputFlag,
+		&internal.FormatFlag,
+		&internal.RecordFlag,
+		&internal.EncodingsFlag,
+		&internal.VerifyKeyFlag,
+		&internal.WebSocketFlag,
 	})
 	if err != nil {
 		logger.Fatalln(err)
 	}
 
 	err = internal.RegisterCommandFlags(serverCmd, []*internal.Flag{
-		&internal.ServerTickerMSFlag,
+		&internal.ServerTickerFlag,
+		&internal.DataDirFlag,
+		&internal.LiveIntervalFlag,
+		&internal.LiveLimitFlag,
+		&internal.ReplayBufferFlag,
+		&internal.RecordFlag,
+		&internal.SigningKeyFlag,
+		&internal.HTTPPortFlag,
+		&internal.WSPortFlag,
+		&internal.SessionTTLFlag,
+		&internal.AdminPortFlag,
+	})
+	if err != nil {
+		logger.Fatalln(err)
+	}
+
+	err = internal.RegisterCommandFlags(listCmd, []*internal.Flag{
+		&internal.ServerAddrFlag,
+	})
+	if err != nil {
+		logger.Fatalln(err)
+	}
+
+	err = internal.RegisterCommandFlags(benchCmd, []*internal.Flag{
+		&internal.ServerAddrFlag,
+		&internal.ClientTickerFlag,
+		&internal.ClientKillswitchFlag,
+		&internal.RetryMaxAttemptsFlag,
+		&internal.RetryMaxElapsedFlag,
+		&internal.BenchClientsFlag,
+		&internal.BenchLengthsFlag,
+		&internal.BenchRampUpFlag,
+		&internal.BenchDurationFlag,
+		&internal.BenchReportFlag,
+	})
+	if err != nil {
+		logger.Fatalln(err)
+	}
+
+	err = internal.RegisterCommandFlags(conformanceCmd, []*internal.Flag{
+		&internal.ServerAddrFlag,
+		&internal.ConformanceTimeoutFlag,
+	})
+	if err != nil {
+		logger.Fatalln(err)
+	}
+	conformanceCmd.Long = conformanceHelp()
+
+	err = internal.RegisterCommandFlags(inspectCmd, []*internal.Flag{
+		&internal.InspectFormatFlag,
 	})
 	if err != nil {
 		logger.Fatalln(err)
 	}
 
+	configCmd.AddCommand(configPrintCmd)
 	rootCmd.AddCommand(
+		benchCmd,
 		clientCmd,
+		configCmd,
+		conformanceCmd,
+		inspectCmd,
+		listCmd,
+		replayCmd,
 		serverCmd,
 	)
 }
@@ -12,6 +12,11 @@ import (
 type AppCfg interface {
 	ClientAppCfg
 	ServerAppCfg
+	ListAppCfg
+	BenchAppCfg
+	ConformanceAppCfg
+	ReplayAppCfg
+	InspectAppCfg
 	// ... add more here to configure additional apps
 }
 
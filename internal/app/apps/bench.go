@@ -0,0 +1,149 @@
+package apps
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"risp/internal/pkg/bench"
+	"risp/internal/pkg/client"
+	"risp/internal/pkg/reconnect"
+	"risp/internal/pkg/validate"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/status"
+)
+
+// BenchAppCfg configures a BenchApp.
+type BenchAppCfg interface {
+	ApplyBenchApp(*BenchApp) error
+}
+
+// BenchApp runs many RISP clients concurrently against a server, and reports their throughput and latency.
+type BenchApp struct {
+	Port             uint16        `validate:"required"`
+	ServerAddrs      []string      `validate:"dive,hostname_port"`
+	RetryMaxAttempts int           `validate:"gte=0"`
+	RetryMaxElapsed  time.Duration `validate:"gte=0"`
+	Clients          int           `validate:"gte=1"`
+	Lengths          []uint16      `validate:"min=1,dive,gte=1"`
+	RampUp           time.Duration `validate:"gte=0"` // the time over which the start of the clients is spread
+	Duration         time.Duration `validate:"gte=0"` // the time during which the clients start new transfers, where 0 is a single transfer each
+	Report           string        `validate:"oneof=text json"`
+
+	out io.Writer
+}
+
+// NewBenchApp creates a new BenchApp.
+func NewBenchApp(cfgs ...BenchAppCfg) (*BenchApp, error) {
+	app := &BenchApp{
+		out: os.Stdout,
+	}
+	for _, cfg := range cfgs {
+		if err := cfg.ApplyBenchApp(app); err != nil {
+			return nil, errors.Wrap(err, "apply BenchApp cfg failed")
+		}
+	}
+	if err := validate.Validate().Struct(app); err != nil {
+		return nil, errors.Wrap(err, "validate BenchApp failed")
+	}
+	return app, nil
+}
+
+// Run runs the clients until each has made its transfers, then writes the report.
+// A transfer in progress when the duration elapses is completed, so that it counts towards the report.
+func (app *BenchApp) Run(ctx context.Context, _ []string) error {
+	addrs := app.ServerAddrs
+	if len(addrs) == 0 {
+		addrs = []string{fmt.Sprintf("localhost:%d", app.Port)}
+	}
+	runner := &ClientApp{
+		RetryMaxAttempts: app.RetryMaxAttempts,
+		RetryMaxElapsed:  app.RetryMaxElapsed,
+	}
+	recorder := bench.NewRecorder()
+	start := time.Now()
+	deadline := start.Add(app.Duration)
+	var wg sync.WaitGroup
+	for i := 0; i < app.Clients; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// spread the start of the clients evenly over the ramp-up
+			delay := app.RampUp * time.Duration(i) / time.Duration(app.Clients)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(delay):
+			}
+			for k := 0; ctx.Err() == nil; k++ {
+				length := app.Lengths[(i+k)%len(app.Lengths)]
+				recorder.Record(app.transfer(ctx, runner, addrs, length))
+				if !time.Now().Before(deadline) {
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	report := recorder.Report(app.Clients, time.Since(start))
+	if app.Report == "json" {
+		return report.WriteJSON(app.out)
+	}
+	return report.WriteText(app.out)
+}
+
+// transfer transfers a random sequence of the given length with a new client, reconnecting according to the retry policy.
+func (app *BenchApp) transfer(ctx context.Context, runner *ClientApp, addrs []string, length uint16) bench.Result {
+	start := time.Now()
+	result := func(err error) bench.Result {
+		r := bench.Result{Len: length, Duration: time.Since(start)}
+		// a transfer cut short by the interruption of the benchmark fails because of it, whatever the client reports
+		if err != nil && ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		if err != nil {
+			logger.WithField("len", length).Debug(errors.Wrap(err, "transfer failed"))
+			r.Failure = failure(err)
+		}
+		return r
+	}
+	c, err := client.NewClient(
+		client.WithServerAddrs(addrs...),
+		client.WithSequenceLength(length),
+	)
+	if err != nil {
+		return result(err)
+	}
+	if err := runner.runClient(ctx, c); err != nil {
+		return result(err)
+	}
+	return result(c.Finish())
+}
+
+// failure classifies the error a transfer failed with, so that failures of the same type are counted together.
+func failure(err error) string {
+	sentinels := []error{
+		reconnect.ErrMaxAttempts,
+		reconnect.ErrMaxElapsed,
+		client.ErrChecksumMismatch,
+		client.ErrMissingChecksum,
+		client.ErrNotDone,
+		client.ErrProtocol,
+		context.Canceled,
+		context.DeadlineExceeded,
+	}
+	for _, sentinel := range sentinels {
+		if errors.Is(err, sentinel) {
+			return sentinel.Error()
+		}
+	}
+	var grpcErr interface{ GRPCStatus() *status.Status }
+	if errors.As(err, &grpcErr) {
+		return "grpc " + grpcErr.GRPCStatus().Code().String()
+	}
+	return "other"
+}
@@ -2,16 +2,35 @@ package apps
 
 import (
 	"context"
+	"crypto/ed25519"
+	"fmt"
+	"os"
+	"os/signal"
 	"strconv"
+	"sync"
 	"time"
+	"unicode"
 
+	risppb "risp/api/proto/gen/pb-go/github.com/mschristensen/risp/api/build/go"
 	"risp/internal/pkg/client"
+	"risp/internal/pkg/output"
+	"risp/internal/pkg/reconnect"
+	"risp/internal/pkg/signature"
+	"risp/internal/pkg/tracing"
+	"risp/internal/pkg/transcript"
 	"risp/internal/pkg/validate"
 
-	"github.com/arsham/retry"
 	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = otel.Tracer("risp/internal/app/apps")
+
+// liveDeliveryBuffer is the number of items of a live stream that may be received before they are collected for output.
+const liveDeliveryBuffer = 1 << 10
+
 // ClientAppCfg configures a ClientApp.
 type ClientAppCfg interface {
 	ApplyClientApp(*ClientApp) error
@@ -19,7 +38,22 @@ type ClientAppCfg interface {
 
 // ClientApp is the demo RISP client application.
 type ClientApp struct {
-	Port uint16 `validate:"required"`
+	Port             uint16        `validate:"required_without=ServerAddrs"` // the port of the local server, used unless ServerAddrs are given
+	ServerAddrs      []string      `validate:"dive,hostname_port"`
+	RetryMaxAttempts int           `validate:"gte=0"`
+	RetryMaxElapsed  time.Duration `validate:"gte=0"`
+	StateFile        string
+	Parallelism      int `validate:"gte=0"` // the number of parallel streams, where 0 or 1 fetch over a single stream
+	Live             bool
+	Output           string            // the path the sequence is written to, if any
+	Format           string            `validate:"omitempty,oneof=text json csv binary protobuf"`
+	Record           string            // the path of the transcript the messages are recorded to, if any
+	Encodings        []risppb.Encoding // the encodings accepted for batches of items, if any
+	VerifyKey        string            // the path of the public key the checksum signatures are verified with, if any
+	WebSocket        bool              // whether to connect over a WebSocket instead of a gRPC stream
+
+	recorder  *transcript.Recorder
+	verifyKey ed25519.PublicKey
 }
 
 // NewClientApp creates a new ClientApp.
@@ -36,30 +70,165 @@ func NewClientApp(cfgs ...ClientAppCfg) (*ClientApp, error) {
 	return app, nil
 }
 
+// serverAddrs returns the addresses of the servers to connect to.
+func (app *ClientApp) serverAddrs() []string {
+	if len(app.ServerAddrs) > 0 {
+		return app.ServerAddrs
+	}
+	return []string{fmt.Sprintf("localhost:%d", app.Port)}
+}
+
 // Run runs the demo RISP client application.
-func (app *ClientApp) Run(ctx context.Context, args []string) error {
-	cfgs := []client.Cfg{
-		client.WithServerPort(app.Port),
+// If several sequence lengths are given, the sequences are transferred concurrently over a single stream.
+func (app *ClientApp) Run(ctx context.Context, args []string) (err error) {
+	ctx, span := tracer.Start(ctx, "client.run", trace.WithAttributes(
+		attribute.StringSlice("args", args),
+		attribute.Int("parallelism", app.Parallelism),
+		attribute.Bool("live", app.Live),
+	))
+	defer func() { tracing.End(span, err) }()
+	if app.VerifyKey != "" {
+		if app.verifyKey, err = signature.LoadPublicKey(app.VerifyKey); err != nil {
+			return errors.Wrap(err, "load verify key failed")
+		}
+	}
+	if app.Record != "" {
+		if app.recorder, err = transcript.Create(app.Record); err != nil {
+			return errors.Wrap(err, "create transcript failed")
+		}
+		defer func() {
+			if cerr := app.recorder.Close(); cerr != nil && err == nil {
+				err = errors.Wrap(cerr, "record transcript failed")
+			}
+		}()
 	}
+	if app.Live {
+		return app.runLive(ctx, args)
+	}
+	if len(args) > 1 {
+		return app.runMultiplexed(ctx, args)
+	}
+	cfgs := append(app.clientCfgs(), client.WithServerAddrs(app.serverAddrs()...))
 	if len(args) > 0 {
-		sequenceLength, err := strconv.ParseUint(args[0], 10, 16)
+		cfg, err := sequenceCfg(args[0])
 		if err != nil {
-			return errors.Wrap(err, "parse sequence length argument failed")
+			return err
+		}
+		cfgs = append(cfgs, cfg)
+		// the ranges of a named sequence can only be split once its length is known
+		if !isLength(args[0]) && app.Parallelism > 1 {
+			length, err := app.lookupLength(ctx, args[0])
+			if err != nil {
+				return err
+			}
+			cfgs = append(cfgs, client.WithSequenceLength(length))
 		}
-		cfgs = append(cfgs, client.WithSequenceLength(uint16(sequenceLength)))
 	} else {
 		cfgs = append(cfgs, client.WithRandomSequenceLength())
 	}
+	if app.StateFile != "" {
+		cfgs = append(cfgs, client.WithStateFile(app.StateFile))
+	}
 	c, err := client.NewClient(cfgs...)
 	if err != nil {
 		return errors.Wrap(err, "create client failed")
 	}
-	retrier := &retry.Retry{
-		Attempts: 100,
-		Delay:    500 * time.Millisecond,
-		Method:   retry.IncrementalDelay,
+	if app.Parallelism > 1 {
+		if err := app.runRanges(ctx, c); err != nil {
+			return errors.Wrap(err, "fetch ranges failed")
+		}
+	}
+	if err := app.runClient(ctx, c); err != nil {
+		return errors.Wrap(err, "run client failed")
+	}
+	if err := c.Finish(); err != nil {
+		return errors.Wrap(err, "finish failed")
+	}
+	if app.Output == "" {
+		return nil
+	}
+	items, err := c.Sequence()
+	if err != nil {
+		return errors.Wrap(err, "get sequence failed")
+	}
+	return app.write(items)
+}
+
+// clientCfgs returns the configuration shared by every client of the app, which records its messages if the
+// transfers are recorded, asks for batches of items if encodings are accepted, verifies the signature of the
+// checksum if it has a key to verify it with, and connects over a WebSocket if asked to.
+func (app *ClientApp) clientCfgs() []client.Cfg {
+	var cfgs []client.Cfg
+	if app.recorder != nil {
+		cfgs = append(cfgs, client.WithRecorder(app.recorder))
+	}
+	if len(app.Encodings) > 0 {
+		cfgs = append(cfgs, client.WithEncodings(app.Encodings...))
+	}
+	if app.verifyKey != nil {
+		cfgs = append(cfgs, client.WithVerifyKey(app.verifyKey))
+	}
+	if app.WebSocket {
+		cfgs = append(cfgs, client.WithWebSocket())
+	}
+	return cfgs
+}
+
+// write writes the verified items to the output.
+func (app *ClientApp) write(items []uint32) error {
+	format := output.Format(app.Format)
+	if format == "" {
+		format = output.Text
+	}
+	return errors.Wrap(output.Write(app.Output, format, items), "write output failed")
+}
+
+// isLength reports whether the argument is a sequence length rather than the name of a sequence,
+// which cannot start with a digit.
+func isLength(arg string) bool {
+	return arg != "" && unicode.IsDigit(rune(arg[0]))
+}
+
+// sequenceCfg configures a client to request the sequence described by the argument,
+// which is either the length of a random sequence or the name of a sequence in the server's catalog.
+func sequenceCfg(arg string) (client.Cfg, error) {
+	if !isLength(arg) {
+		return client.WithSequenceName(arg), nil
+	}
+	sequenceLength, err := strconv.ParseUint(arg, 10, 16)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse sequence length argument failed")
+	}
+	return client.WithSequenceLength(uint16(sequenceLength)), nil
+}
+
+// lookupLength looks up the length of the named sequence in the server's catalog.
+func (app *ClientApp) lookupLength(ctx context.Context, name string) (uint16, error) {
+	sequences, err := client.ListSequences(ctx, app.serverAddrs()...)
+	if err != nil {
+		return 0, errors.Wrap(err, "list sequences failed")
+	}
+	for _, s := range sequences {
+		if s.Name == name {
+			return uint16(s.Len), nil
+		}
 	}
-	err = retrier.Do(func() error {
+	return 0, errors.Errorf("sequence %q not found", name)
+}
+
+// attempt starts the span of a connection attempt, which is the parent of the spans of the transfer over the connection.
+func attempt(ctx context.Context, n int) (context.Context, trace.Span) {
+	return tracer.Start(ctx, "client.attempt", trace.WithAttributes(attribute.Int("attempt", n)))
+}
+
+// runClient runs the client on its own stream until it completes, reconnecting according to the retry policy.
+func (app *ClientApp) runClient(ctx context.Context, c *client.Client) error {
+	policy := reconnect.NewPolicy(app.RetryMaxAttempts, app.RetryMaxElapsed, client.Retryable)
+	attempts := 0
+	return policy.Do(ctx, func() (err error) {
+		attempts++
+		ctx, span := attempt(ctx, attempts)
+		defer func() { tracing.End(span, err) }()
 		if err := c.Connect(ctx); err != nil {
 			return errors.Wrap(err, "connect client failed")
 		}
@@ -68,11 +237,185 @@ func (app *ClientApp) Run(ctx context.Context, args []string) error {
 		}
 		return nil
 	})
+}
+
+// runRanges splits the sequence into ranges and fetches them over parallel streams,
+// leaving the client to verify the whole sequence.
+func (app *ClientApp) runRanges(ctx context.Context, c *client.Client) error {
+	ranges, err := c.Split(app.Parallelism)
+	if err != nil {
+		return errors.Wrap(err, "split client failed")
+	}
+	errs := make(chan error, len(ranges))
+	var wg sync.WaitGroup
+	for _, r := range ranges {
+		wg.Add(1)
+		go func(r *client.Client) {
+			defer wg.Done()
+			if err := app.runClient(ctx, r); err != nil {
+				errs <- err
+				return
+			}
+			if err := r.Finish(); err != nil {
+				errs <- errors.Wrap(err, "finish range failed")
+			}
+		}(r)
+	}
+	wg.Wait()
+	close(errs)
+	return <-errs
+}
+
+// runMultiplexed transfers a sequence of each of the given lengths concurrently over a single stream.
+func (app *ClientApp) runMultiplexed(ctx context.Context, args []string) error {
+	if app.StateFile != "" {
+		return errors.New("checkpointing is not supported for multiple sequences")
+	}
+	if app.Parallelism > 1 {
+		return errors.New("parallelism is not supported for multiple sequences")
+	}
+	if app.Output != "" {
+		return errors.New("output is not supported for multiple sequences")
+	}
+	clients := make([]*client.Client, len(args))
+	for i := range args {
+		cfg, err := sequenceCfg(args[i])
+		if err != nil {
+			return err
+		}
+		clients[i], err = client.NewClient(append(app.clientCfgs(), cfg)...)
+		if err != nil {
+			return errors.Wrap(err, "create client failed")
+		}
+	}
+	policy := reconnect.NewPolicy(app.RetryMaxAttempts, app.RetryMaxElapsed, client.Retryable)
+	attempts := 0
+	err := policy.Do(ctx, func() (err error) {
+		attempts++
+		ctx, span := attempt(ctx, attempts)
+		defer func() { tracing.End(span, err) }()
+		newMux := client.NewMux
+		if app.WebSocket {
+			newMux = client.NewWebSocketMux
+		}
+		mux, err := newMux(ctx, app.serverAddrs()...)
+		if err != nil {
+			return errors.Wrap(err, "connect mux failed")
+		}
+		defer func() {
+			if err := mux.Close(); err != nil {
+				logger.Warning(errors.Wrap(err, "close mux failed"))
+			}
+		}()
+		errs := make(chan error, len(clients))
+		var wg sync.WaitGroup
+		for _, c := range clients {
+			if c.Done() {
+				continue
+			}
+			if err := c.ConnectVia(mux); err != nil {
+				return errors.Wrap(err, "connect client failed")
+			}
+			wg.Add(1)
+			go func(c *client.Client) {
+				defer wg.Done()
+				if err := c.Run(ctx); err != nil {
+					errs <- errors.Wrap(err, "run client failed")
+				}
+			}(c)
+		}
+		wg.Wait()
+		close(errs)
+		// report a permanent error in preference to one that is worth retrying
+		var result error
+		for err := range errs {
+			if result == nil || !client.Retryable(err) {
+				result = err
+			}
+		}
+		return result
+	})
 	if err != nil {
+		return errors.Wrap(err, "run clients failed")
+	}
+	for _, c := range clients {
+		if err := c.Finish(); err != nil {
+			return errors.Wrap(err, "finish failed")
+		}
+	}
+	return nil
+}
+
+// runLive receives a live stream until it has received the number of items given by the argument,
+// or if none is given, until the client is interrupted or the server closes the stream.
+func (app *ClientApp) runLive(ctx context.Context, args []string) error {
+	if len(args) > 1 {
+		return errors.New("a single live stream is supported")
+	}
+	if app.StateFile != "" {
+		return errors.New("checkpointing is not supported for live streams")
+	}
+	if app.Parallelism > 1 {
+		return errors.New("parallelism is not supported for live streams")
+	}
+	var limit uint64
+	if len(args) > 0 {
+		var err error
+		limit, err = strconv.ParseUint(args[0], 10, 32)
+		if err != nil {
+			return errors.Wrap(err, "parse item limit argument failed")
+		}
+	}
+	cfgs := append(app.clientCfgs(),
+		client.WithServerAddrs(app.serverAddrs()...),
+		client.WithLive(uint32(limit)),
+	)
+	// a live stream is not kept by the client, so the items to write are collected as they are delivered
+	if app.Output != "" {
+		cfgs = append(cfgs, client.WithDelivery(liveDeliveryBuffer))
+	}
+	c, err := client.NewClient(cfgs...)
+	if err != nil {
+		return errors.Wrap(err, "create client failed")
+	}
+	collected := make(chan []uint32, 1)
+	if app.Output != "" {
+		go func() {
+			var items []uint32
+			for event := range c.Deliveries() {
+				if event.Verification == nil {
+					items = append(items, event.Value)
+				}
+			}
+			collected <- items
+		}()
+	}
+
+	// close the stream gracefully on the first interrupt, and exit on the next
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	go func() {
+		if _, ok := <-interrupt; ok {
+			signal.Stop(interrupt)
+			logger.Info("interrupted, closing live stream")
+			c.Stop()
+		}
+	}()
+	defer func() {
+		signal.Stop(interrupt)
+		close(interrupt)
+	}()
+
+	if err := app.runClient(ctx, c); err != nil {
+		// the deliveries are closed by Finish, so that the items are not collected forever
+		_ = c.Finish()
 		return errors.Wrap(err, "run client failed")
 	}
 	if err := c.Finish(); err != nil {
 		return errors.Wrap(err, "finish failed")
 	}
-	return nil
+	if app.Output == "" {
+		return nil
+	}
+	return app.write(<-collected)
 }
@@ -0,0 +1,94 @@
+package apps
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"risp/internal/pkg/conformance"
+	"risp/internal/pkg/validate"
+
+	"github.com/pkg/errors"
+)
+
+// ConformanceAppCfg configures a ConformanceApp.
+type ConformanceAppCfg interface {
+	ApplyConformanceApp(*ConformanceApp) error
+}
+
+// ConformanceApp checks that running RISP servers speak the protocol correctly.
+type ConformanceApp struct {
+	Port        uint16        `validate:"required"`
+	ServerAddrs []string      `validate:"dive,hostname_port"`
+	Timeout     time.Duration `validate:"gt=0"` // the time to wait for each expected message from the server
+
+	out io.Writer
+}
+
+// NewConformanceApp creates a new ConformanceApp.
+func NewConformanceApp(cfgs ...ConformanceAppCfg) (*ConformanceApp, error) {
+	app := &ConformanceApp{
+		Timeout: conformance.DefaultTimeout,
+		out:     os.Stdout,
+	}
+	for _, cfg := range cfgs {
+		if err := cfg.ApplyConformanceApp(app); err != nil {
+			return nil, errors.Wrap(err, "apply ConformanceApp cfg failed")
+		}
+	}
+	if err := validate.Validate().Struct(app); err != nil {
+		return nil, errors.Wrap(err, "validate ConformanceApp failed")
+	}
+	return app, nil
+}
+
+// Run runs every conformance scenario against each server in turn, and prints whether each passed.
+// It fails if any scenario failed against any server.
+func (app *ConformanceApp) Run(ctx context.Context, _ []string) error {
+	addrs := app.ServerAddrs
+	if len(addrs) == 0 {
+		addrs = []string{fmt.Sprintf("localhost:%d", app.Port)}
+	}
+	w := tabwriter.NewWriter(app.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "SERVER\tSCENARIO\tRESULT\tTIME\tDETAIL")
+	failed, total := 0, 0
+	for _, addr := range addrs {
+		results, err := app.check(ctx, addr)
+		if err != nil {
+			return err
+		}
+		for _, r := range results {
+			result, detail := "pass", ""
+			if !r.Passed() {
+				result, detail = "FAIL", r.Err.Error()
+				failed++
+			}
+			total++
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", addr, r.Scenario.Name, result, r.Elapsed.Round(time.Millisecond), detail)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return errors.Wrap(err, "write results failed")
+	}
+	if failed > 0 {
+		return errors.Errorf("%d of %d scenarios failed", failed, total)
+	}
+	return nil
+}
+
+// check runs every conformance scenario against the server at the given address.
+func (app *ConformanceApp) check(ctx context.Context, addr string) ([]conformance.Result, error) {
+	conn, err := conformance.Dial(ctx, addr)
+	if err != nil {
+		return nil, errors.Wrap(err, "dial failed")
+	}
+	defer conn.Close() // nolint: errcheck // the connection is only used for the scenarios
+	c, err := conformance.NewChecker(conn, conformance.WithTimeout(app.Timeout))
+	if err != nil {
+		return nil, errors.Wrap(err, "new checker failed")
+	}
+	return c.Run(ctx, conformance.Scenarios()...), nil
+}
@@ -0,0 +1,56 @@
+package apps
+
+import (
+	"context"
+	"io"
+	"os"
+
+	"risp/internal/pkg/transcript"
+	"risp/internal/pkg/validate"
+
+	"github.com/pkg/errors"
+)
+
+// InspectAppCfg configures an InspectApp.
+type InspectAppCfg interface {
+	ApplyInspectApp(*InspectApp) error
+}
+
+// InspectApp prints the timeline of each session in the transcript recorded by a RISP client or server.
+type InspectApp struct {
+	Format string `validate:"oneof=text json"`
+
+	out io.Writer
+}
+
+// NewInspectApp creates a new InspectApp.
+func NewInspectApp(cfgs ...InspectAppCfg) (*InspectApp, error) {
+	app := &InspectApp{
+		out: os.Stdout,
+	}
+	for _, cfg := range cfgs {
+		if err := cfg.ApplyInspectApp(app); err != nil {
+			return nil, errors.Wrap(err, "apply InspectApp cfg failed")
+		}
+	}
+	if err := validate.Validate().Struct(app); err != nil {
+		return nil, errors.Wrap(err, "validate InspectApp failed")
+	}
+	return app, nil
+}
+
+// Run prints the timeline of each session in the transcript given by the argument.
+func (app *InspectApp) Run(_ context.Context, args []string) error {
+	entries, err := transcript.Load(args[0])
+	if err != nil {
+		return errors.Wrap(err, "load transcript failed")
+	}
+	report, err := transcript.Inspect(entries)
+	if err != nil {
+		return errors.Wrap(err, "inspect transcript failed")
+	}
+	if app.Format == "json" {
+		return report.WriteJSON(app.out)
+	}
+	return report.WriteText(app.out)
+}
@@ -0,0 +1,61 @@
+package apps
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"risp/internal/pkg/client"
+	"risp/internal/pkg/validate"
+
+	"github.com/pkg/errors"
+)
+
+// ListAppCfg configures a ListApp.
+type ListAppCfg interface {
+	ApplyListApp(*ListApp) error
+}
+
+// ListApp lists the named sequences served by a RISP server.
+type ListApp struct {
+	Port        uint16   `validate:"required"`
+	ServerAddrs []string `validate:"dive,hostname_port"`
+
+	out io.Writer
+}
+
+// NewListApp creates a new ListApp.
+func NewListApp(cfgs ...ListAppCfg) (*ListApp, error) {
+	app := &ListApp{
+		out: os.Stdout,
+	}
+	for _, cfg := range cfgs {
+		if err := cfg.ApplyListApp(app); err != nil {
+			return nil, errors.Wrap(err, "apply ListApp cfg failed")
+		}
+	}
+	if err := validate.Validate().Struct(app); err != nil {
+		return nil, errors.Wrap(err, "validate ListApp failed")
+	}
+	return app, nil
+}
+
+// Run prints the name, length and checksum of each sequence served by the server.
+func (app *ListApp) Run(ctx context.Context, _ []string) error {
+	addrs := app.ServerAddrs
+	if len(addrs) == 0 {
+		addrs = []string{fmt.Sprintf("localhost:%d", app.Port)}
+	}
+	sequences, err := client.ListSequences(ctx, addrs...)
+	if err != nil {
+		return errors.Wrap(err, "list sequences failed")
+	}
+	w := tabwriter.NewWriter(app.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tLEN\tCHECKSUM")
+	for _, s := range sequences {
+		fmt.Fprintf(w, "%s\t%d\t%d\n", s.Name, s.Len, s.Checksum)
+	}
+	return errors.Wrap(w.Flush(), "write sequences failed")
+}
@@ -0,0 +1,65 @@
+package apps
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"risp/internal/pkg/client"
+	"risp/internal/pkg/server"
+	"risp/internal/pkg/transcript"
+
+	"github.com/pkg/errors"
+)
+
+// ReplayAppCfg configures a ReplayApp.
+type ReplayAppCfg interface {
+	ApplyReplayApp(*ReplayApp) error
+}
+
+// ReplayApp replays the transcript recorded by a RISP client or server.
+type ReplayApp struct {
+	out io.Writer
+}
+
+// NewReplayApp creates a new ReplayApp.
+func NewReplayApp(cfgs ...ReplayAppCfg) (*ReplayApp, error) {
+	app := &ReplayApp{
+		out: os.Stdout,
+	}
+	for _, cfg := range cfgs {
+		if err := cfg.ApplyReplayApp(app); err != nil {
+			return nil, errors.Wrap(err, "apply ReplayApp cfg failed")
+		}
+	}
+	return app, nil
+}
+
+// Run re-drives the client or the server that recorded the transcript given by the argument with the messages
+// it received, and fails if it does not send and reject exactly the messages it did when the transcript was recorded.
+func (app *ReplayApp) Run(_ context.Context, args []string) error {
+	entries, err := transcript.Load(args[0])
+	if err != nil {
+		return errors.Wrap(err, "load transcript failed")
+	}
+	side, err := transcript.RecordedBy(entries)
+	if err != nil {
+		return errors.Wrap(err, "inspect transcript failed")
+	}
+	switch side {
+	case transcript.Client:
+		err = client.Replay(entries)
+	case transcript.Server:
+		err = server.Replay(entries)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "replay %s failed", side)
+	}
+	streams := make(map[uint64]bool)
+	for _, entry := range entries {
+		streams[entry.Stream] = true
+	}
+	_, err = fmt.Fprintf(app.out, "replayed %d entries recorded by a %s on %d streams without divergence\n", len(entries), side, len(streams))
+	return errors.Wrap(err, "write result failed")
+}
@@ -4,10 +4,21 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"risp/internal"
+	"risp/internal/pkg/admin"
+	"risp/internal/pkg/catalog"
+	"risp/internal/pkg/log"
 	"risp/internal/pkg/server"
 	"risp/internal/pkg/session"
+	"risp/internal/pkg/signature"
+	"risp/internal/pkg/tracing"
+	"risp/internal/pkg/transcript"
 	"risp/internal/pkg/validate"
 
 	risppb "risp/api/proto/gen/pb-go/github.com/mschristensen/risp/api/build/go"
@@ -26,7 +37,17 @@ type ServerAppCfg interface {
 
 // ServerApp is the demo RISP client application.
 type ServerApp struct {
-	Port uint16 `validate:"required"`
+	Port         uint16        `validate:"required"`
+	LiveInterval time.Duration `validate:"gt=0"`
+	LiveLimit    int           `validate:"gte=0"`
+	ReplayBuffer int           `validate:"gt=0"`
+	DataDir      string
+	Record       string        // the path of the transcript the messages are recorded to, if any
+	SigningKey   string        // the path of the private key the checksums are signed with, if any
+	HTTPPort     uint16        // the port of the HTTP/JSON gateway, if it is served
+	WSPort       uint16        // the port of the WebSocket endpoint, if it is served
+	SessionTTL   time.Duration `validate:"gte=0"`
+	AdminPort    uint16        // the port of the admin interface, if it is served
 }
 
 // NewServerApp creates a new ServerApp.
@@ -40,6 +61,12 @@ func NewServerApp(cfgs ...ServerAppCfg) (*ServerApp, error) {
 	if app.Port == 0 {
 		app.Port = uint16(internal.Port)
 	}
+	if app.LiveInterval == 0 {
+		app.LiveInterval = internal.LiveInterval
+	}
+	if app.ReplayBuffer == 0 {
+		app.ReplayBuffer = internal.ReplayBuffer
+	}
 	if err := validate.Validate().Struct(app); err != nil {
 		return nil, errors.Wrap(err, "validate ServerApp failed")
 	}
@@ -47,21 +74,75 @@ func NewServerApp(cfgs ...ServerAppCfg) (*ServerApp, error) {
 }
 
 // Run runs the demo RISP server application.
-func (app *ServerApp) Run(ctx context.Context, _ []string) error {
-	srv, err := server.NewServer(
+func (app *ServerApp) Run(ctx context.Context, _ []string) (err error) {
+	cfgs := []server.Cfg{
 		server.WithSessionStore(session.NewMemoryStore()),
-	)
+		server.WithTunables(app.tunables()),
+		server.WithReplayBuffer(app.ReplayBuffer),
+	}
+	if app.Record != "" {
+		rec, err := transcript.Create(app.Record)
+		if err != nil {
+			return errors.Wrap(err, "create transcript failed")
+		}
+		defer func() {
+			if cerr := rec.Close(); cerr != nil && err == nil {
+				err = errors.Wrap(cerr, "record transcript failed")
+			}
+		}()
+		cfgs = append(cfgs, server.WithRecorder(rec))
+	}
+	if app.SigningKey != "" {
+		key, err := signature.LoadPrivateKey(app.SigningKey)
+		if err != nil {
+			return errors.Wrap(err, "load signing key failed")
+		}
+		cfgs = append(cfgs, server.WithSigningKey(key))
+	}
+	if app.DataDir != "" {
+		c, err := catalog.Load(app.DataDir)
+		if err != nil {
+			return errors.Wrap(err, "load catalog failed")
+		}
+		logger.WithField("sequences", len(c.List())).Info("loaded catalog")
+		cfgs = append(cfgs, server.WithCatalog(c))
+	}
+	srv, err := server.NewServer(cfgs...)
 	if err != nil {
 		return errors.Wrap(err, "new server failed")
 	}
-	grpcServer := grpc.NewServer()
+	grpcServer := grpc.NewServer(tracing.ServerOptions()...)
 	risppb.RegisterRISPServer(grpcServer, srv)
+	gateway, err := serveHTTP("HTTP gateway", app.HTTPPort, srv.Gateway())
+	if err != nil {
+		return errors.Wrap(err, "serve gateway failed")
+	}
+	websocket, err := serveHTTP("WebSocket server", app.WSPort, srv.WebSocket())
+	if err != nil {
+		return errors.Wrap(err, "serve WebSocket failed")
+	}
+	reloads := admin.New(func() (map[string]interface{}, error) {
+		return app.reload(srv)
+	})
+	adminServer, err := serveHTTP("admin interface", app.AdminPort, reloads)
+	if err != nil {
+		return errors.Wrap(err, "serve admin interface failed")
+	}
 
-	// stop the server when the context is done
+	// stop the servers when the context is done
 	go func() {
 		<-ctx.Done()
 		grpcServer.Stop()
+		for _, s := range []*http.Server{gateway, websocket, adminServer} {
+			if s != nil {
+				_ = s.Close()
+			}
+		}
 	}()
+	go srv.ExpireSessions(ctx)
+	if err := app.watch(ctx, reloads); err != nil {
+		return errors.Wrap(err, "watch for reloads failed")
+	}
 
 	logger.WithField("port", app.Port).Info("gRPC server listening")
 	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", app.Port))
@@ -73,3 +154,93 @@ func (app *ServerApp) Run(ctx context.Context, _ []string) error {
 	}
 	return nil
 }
+
+// serveHTTP serves the handler on the given port, unless it is 0, and returns the named HTTP server.
+func serveHTTP(name string, port uint16, handler http.Handler) (*http.Server, error) {
+	if port == 0 {
+		return nil, nil
+	}
+	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return nil, errors.Wrap(err, "listen failed")
+	}
+	s := &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	logger.WithField("port", port).Info(name + " listening")
+	go func() {
+		if err := s.Serve(lis); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error(errors.Wrap(err, name+" failed"))
+		}
+	}()
+	return s, nil
+}
+
+// tunables returns the server tunables from the current configuration.
+func (app *ServerApp) tunables() server.Tunables {
+	return server.Tunables{
+		Ticker:            internal.ServerTicker,
+		HeartbeatInterval: internal.HeartbeatInterval,
+		IdleTimeout:       internal.IdleTimeout,
+		LiveInterval:      app.LiveInterval,
+		LiveLimit:         uint32(app.LiveLimit),
+		SessionTTL:        app.SessionTTL,
+	}
+}
+
+// watch reloads the configuration when the server receives SIGHUP or the config file changes,
+// until the context is done.
+func (app *ServerApp) watch(ctx context.Context, reloads *admin.Admin) error {
+	changed, err := internal.WatchConfig(ctx)
+	if err != nil {
+		return errors.Wrap(err, "watch config failed")
+	}
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	go func() {
+		defer signal.Stop(hangup)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hangup:
+				logger.Info("received SIGHUP, reloading configuration")
+				reloads.Reload(admin.TriggerSignal)
+			case <-changed:
+				logger.WithField("config", internal.Config).Info("config file changed, reloading configuration")
+				reloads.Reload(admin.TriggerConfig)
+			}
+		}
+	}()
+	return nil
+}
+
+// reload loads the configuration again, and applies the settings that are safe to change while the server is
+// running: the server tunables, and the log level and sampling. Nothing is applied unless the whole
+// configuration is valid. It returns the settings in effect afterwards.
+func (app *ServerApp) reload(srv *server.Server) (logrus.Fields, error) {
+	if err := internal.Reload(); err != nil {
+		return nil, errors.Wrap(err, "reload config failed")
+	}
+	app.LiveInterval = internal.LiveInterval
+	app.LiveLimit = internal.LiveLimit
+	app.SessionTTL = internal.SessionTTL
+	if err := srv.Tune(app.tunables()); err != nil {
+		return nil, errors.Wrap(err, "tune server failed")
+	}
+	if err := log.Configure(log.WithLevel(internal.LogLevel), log.WithSampling(internal.LogSample)); err != nil {
+		return nil, errors.Wrap(err, "configure logger failed")
+	}
+	t := srv.Tunables()
+	return logrus.Fields{
+		"server_ticker":      t.Ticker.String(),
+		"heartbeat_interval": t.HeartbeatInterval.String(),
+		"idle_timeout":       t.IdleTimeout.String(),
+		"live_interval":      t.LiveInterval.String(),
+		"live_limit":         t.LiveLimit,
+		"session_ttl":        t.SessionTTL.String(),
+		"log_level":          internal.LogLevel,
+		"log_sample":         internal.LogSample,
+	}, nil
+}
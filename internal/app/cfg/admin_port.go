@@ -0,0 +1,31 @@
+package cfg
+
+import (
+	"risp/internal"
+	"risp/internal/app/apps"
+)
+
+// AdminPortCfg is configuration for the port of the admin interface of a RISP server.
+type AdminPortCfg struct {
+	port uint16
+}
+
+// NewAdminPortCfg creates a new AdminPortCfg from the given config.
+func NewAdminPortCfg(port uint16) *AdminPortCfg {
+	return &AdminPortCfg{
+		port: port,
+	}
+}
+
+// AdminPortFromEnv creates a new AdminPortCfg from the current environment.
+func AdminPortFromEnv() *AdminPortCfg {
+	return &AdminPortCfg{
+		port: uint16(internal.AdminPort),
+	}
+}
+
+// ApplyServerApp applies the AdminPortCfg to a ServerApp.
+func (cfg AdminPortCfg) ApplyServerApp(app *apps.ServerApp) error { // nolint:unparam // its okay that the error is always nil
+	app.AdminPort = cfg.port
+	return nil
+}
@@ -0,0 +1,59 @@
+package cfg
+
+import (
+	"strconv"
+	"time"
+
+	"risp/internal"
+	"risp/internal/app/apps"
+
+	"github.com/pkg/errors"
+)
+
+// BenchCfg is configuration for the load generated by a benchmark.
+type BenchCfg struct {
+	clients  int
+	lengths  []string
+	rampUp   time.Duration
+	duration time.Duration
+	report   string
+}
+
+// NewBenchCfg creates a new BenchCfg from the given config.
+func NewBenchCfg(clients int, lengths []string, rampUp, duration time.Duration, report string) *BenchCfg {
+	return &BenchCfg{
+		clients:  clients,
+		lengths:  lengths,
+		rampUp:   rampUp,
+		duration: duration,
+		report:   report,
+	}
+}
+
+// BenchFromEnv creates a new BenchCfg from the current environment.
+func BenchFromEnv() *BenchCfg {
+	return &BenchCfg{
+		clients:  internal.BenchClients,
+		lengths:  internal.BenchLengths,
+		rampUp:   internal.BenchRampUp,
+		duration: internal.BenchDuration,
+		report:   internal.BenchReport,
+	}
+}
+
+// ApplyBenchApp applies the BenchCfg to a BenchApp.
+func (cfg BenchCfg) ApplyBenchApp(app *apps.BenchApp) error {
+	app.Lengths = make([]uint16, len(cfg.lengths))
+	for i, length := range cfg.lengths {
+		l, err := strconv.ParseUint(length, 10, 16)
+		if err != nil {
+			return errors.Wrap(err, "parse sequence length failed")
+		}
+		app.Lengths[i] = uint16(l)
+	}
+	app.Clients = cfg.clients
+	app.RampUp = cfg.rampUp
+	app.Duration = cfg.duration
+	app.Report = cfg.report
+	return nil
+}
@@ -0,0 +1,33 @@
+package cfg
+
+import (
+	"time"
+
+	"risp/internal"
+	"risp/internal/app/apps"
+)
+
+// ConformanceCfg is configuration for the protocol conformance checker.
+type ConformanceCfg struct {
+	timeout time.Duration
+}
+
+// NewConformanceCfg creates a new ConformanceCfg from the given config.
+func NewConformanceCfg(timeout time.Duration) *ConformanceCfg {
+	return &ConformanceCfg{
+		timeout: timeout,
+	}
+}
+
+// ConformanceFromEnv creates a new ConformanceCfg from the current environment.
+func ConformanceFromEnv() *ConformanceCfg {
+	return &ConformanceCfg{
+		timeout: internal.ConformanceTimeout,
+	}
+}
+
+// ApplyConformanceApp applies the ConformanceCfg to a ConformanceApp.
+func (cfg ConformanceCfg) ApplyConformanceApp(app *apps.ConformanceApp) error { // nolint:unparam // its okay that the error is always nil
+	app.Timeout = cfg.timeout
+	return nil
+}
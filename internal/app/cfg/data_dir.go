@@ -0,0 +1,31 @@
+package cfg
+
+import (
+	"risp/internal"
+	"risp/internal/app/apps"
+)
+
+// DataDirCfg is configuration for the directory of data files served by a RISP server.
+type DataDirCfg struct {
+	dir string
+}
+
+// NewDataDirCfg creates a new DataDirCfg from the given config.
+func NewDataDirCfg(dir string) *DataDirCfg {
+	return &DataDirCfg{
+		dir: dir,
+	}
+}
+
+// DataDirFromEnv creates a new DataDirCfg from the current environment.
+func DataDirFromEnv() *DataDirCfg {
+	return &DataDirCfg{
+		dir: internal.DataDir,
+	}
+}
+
+// ApplyServerApp applies the DataDirCfg to a ServerApp.
+func (cfg DataDirCfg) ApplyServerApp(app *apps.ServerApp) error { // nolint:unparam // its okay that the error is always nil
+	app.DataDir = cfg.dir
+	return nil
+}
@@ -0,0 +1,43 @@
+package cfg
+
+import (
+	"strings"
+
+	risppb "risp/api/proto/gen/pb-go/github.com/mschristensen/risp/api/build/go"
+	"risp/internal"
+	"risp/internal/app/apps"
+
+	"github.com/pkg/errors"
+)
+
+// EncodingsCfg is configuration for the encodings a RISP client accepts for batches of items.
+type EncodingsCfg struct {
+	encodings []string
+}
+
+// NewEncodingsCfg creates a new EncodingsCfg from the given config.
+func NewEncodingsCfg(encodings []string) *EncodingsCfg {
+	return &EncodingsCfg{
+		encodings: encodings,
+	}
+}
+
+// EncodingsFromEnv creates a new EncodingsCfg from the current environment.
+func EncodingsFromEnv() *EncodingsCfg {
+	return &EncodingsCfg{
+		encodings: internal.Encodings,
+	}
+}
+
+// ApplyClientApp applies the EncodingsCfg to a ClientApp.
+func (cfg EncodingsCfg) ApplyClientApp(app *apps.ClientApp) error {
+	app.Encodings = make([]risppb.Encoding, len(cfg.encodings))
+	for i, name := range cfg.encodings {
+		encoding, ok := risppb.Encoding_value[strings.ToUpper(name)]
+		if !ok || encoding == int32(risppb.Encoding_NONE) {
+			return errors.Errorf("unknown encoding %s", name)
+		}
+		app.Encodings[i] = risppb.Encoding(encoding)
+	}
+	return nil
+}
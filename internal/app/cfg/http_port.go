@@ -0,0 +1,31 @@
+package cfg
+
+import (
+	"risp/internal"
+	"risp/internal/app/apps"
+)
+
+// HTTPPortCfg is configuration for the port of the HTTP/JSON gateway of a RISP server.
+type HTTPPortCfg struct {
+	port uint16
+}
+
+// NewHTTPPortCfg creates a new HTTPPortCfg from the given config.
+func NewHTTPPortCfg(port uint16) *HTTPPortCfg {
+	return &HTTPPortCfg{
+		port: port,
+	}
+}
+
+// HTTPPortFromEnv creates a new HTTPPortCfg from the current environment.
+func HTTPPortFromEnv() *HTTPPortCfg {
+	return &HTTPPortCfg{
+		port: uint16(internal.HTTPPort),
+	}
+}
+
+// ApplyServerApp applies the HTTPPortCfg to a ServerApp.
+func (cfg HTTPPortCfg) ApplyServerApp(app *apps.ServerApp) error { // nolint:unparam // its okay that the error is always nil
+	app.HTTPPort = cfg.port
+	return nil
+}
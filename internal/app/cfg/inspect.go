@@ -0,0 +1,31 @@
+package cfg
+
+import (
+	"risp/internal"
+	"risp/internal/app/apps"
+)
+
+// InspectCfg is configuration for the inspection of a transcript.
+type InspectCfg struct {
+	format string
+}
+
+// NewInspectCfg creates a new InspectCfg from the given config.
+func NewInspectCfg(format string) *InspectCfg {
+	return &InspectCfg{
+		format: format,
+	}
+}
+
+// InspectFromEnv creates a new InspectCfg from the current environment.
+func InspectFromEnv() *InspectCfg {
+	return &InspectCfg{
+		format: internal.InspectFormat,
+	}
+}
+
+// ApplyInspectApp applies the InspectCfg to an InspectApp.
+func (cfg InspectCfg) ApplyInspectApp(app *apps.InspectApp) error { // nolint:unparam // its okay that the error is always nil
+	app.Format = cfg.format
+	return nil
+}
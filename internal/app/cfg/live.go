@@ -0,0 +1,50 @@
+package cfg
+
+import (
+	"time"
+
+	"risp/internal"
+	"risp/internal/app/apps"
+)
+
+// LiveCfg is configuration for live streams.
+type LiveCfg struct {
+	live         bool
+	interval     time.Duration
+	limit        int
+	replayBuffer int
+}
+
+// NewLiveCfg creates a new LiveCfg from the given config.
+func NewLiveCfg(live bool, interval time.Duration, limit, replayBuffer int) *LiveCfg {
+	return &LiveCfg{
+		live:         live,
+		interval:     interval,
+		limit:        limit,
+		replayBuffer: replayBuffer,
+	}
+}
+
+// LiveFromEnv creates a new LiveCfg from the current environment.
+func LiveFromEnv() *LiveCfg {
+	return &LiveCfg{
+		live:         internal.Live,
+		interval:     internal.LiveInterval,
+		limit:        internal.LiveLimit,
+		replayBuffer: internal.ReplayBuffer,
+	}
+}
+
+// ApplyClientApp applies the LiveCfg to a ClientApp.
+func (cfg LiveCfg) ApplyClientApp(app *apps.ClientApp) error { // nolint:unparam // its okay that the error is always nil
+	app.Live = cfg.live
+	return nil
+}
+
+// ApplyServerApp applies the LiveCfg to a ServerApp.
+func (cfg LiveCfg) ApplyServerApp(app *apps.ServerApp) error { // nolint:unparam // its okay that the error is always nil
+	app.LiveInterval = cfg.interval
+	app.LiveLimit = cfg.limit
+	app.ReplayBuffer = cfg.replayBuffer
+	return nil
+}
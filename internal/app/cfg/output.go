@@ -0,0 +1,35 @@
+package cfg
+
+import (
+	"risp/internal"
+	"risp/internal/app/apps"
+)
+
+// OutputCfg is configuration for where and how the client writes the received sequence.
+type OutputCfg struct {
+	path   string
+	format string
+}
+
+// NewOutputCfg creates a new OutputCfg from the given config.
+func NewOutputCfg(path, format string) *OutputCfg {
+	return &OutputCfg{
+		path:   path,
+		format: format,
+	}
+}
+
+// OutputFromEnv creates a new OutputCfg from the current environment.
+func OutputFromEnv() *OutputCfg {
+	return &OutputCfg{
+		path:   internal.Output,
+		format: internal.Format,
+	}
+}
+
+// ApplyClientApp applies the OutputCfg to a ClientApp.
+func (cfg OutputCfg) ApplyClientApp(app *apps.ClientApp) error { // nolint:unparam // its okay that the error is always nil
+	app.Output = cfg.path
+	app.Format = cfg.format
+	return nil
+}
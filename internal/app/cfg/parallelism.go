@@ -0,0 +1,31 @@
+package cfg
+
+import (
+	"risp/internal"
+	"risp/internal/app/apps"
+)
+
+// ParallelismCfg is configuration for the number of parallel streams used to fetch a sequence.
+type ParallelismCfg struct {
+	parallelism int
+}
+
+// NewParallelismCfg creates a new ParallelismCfg from the given config.
+func NewParallelismCfg(parallelism int) *ParallelismCfg {
+	return &ParallelismCfg{
+		parallelism: parallelism,
+	}
+}
+
+// ParallelismFromEnv creates a new ParallelismCfg from the current environment.
+func ParallelismFromEnv() *ParallelismCfg {
+	return &ParallelismCfg{
+		parallelism: internal.Parallelism,
+	}
+}
+
+// ApplyClientApp applies the ParallelismCfg to a ClientApp.
+func (cfg ParallelismCfg) ApplyClientApp(app *apps.ClientApp) error { // nolint:unparam // its okay that the error is always nil
+	app.Parallelism = cfg.parallelism
+	return nil
+}
@@ -38,8 +38,26 @@ func (cfg PortCfg) ApplyClientApp(app *apps.ClientApp) error { // nolint:unparam
 	return nil
 }
 
+// ApplyListApp applies the PortCfg to a ListApp.
+func (cfg PortCfg) ApplyListApp(app *apps.ListApp) error { // nolint:unparam // its okay that the error is always nil
+	app.Port = cfg.port
+	return nil
+}
+
 // ApplyServerApp applies the PortCfg to a ServerApp.
 func (cfg PortCfg) ApplyServerApp(app *apps.ServerApp) error { // nolint:unparam // its okay that the error is always nil
 	app.Port = cfg.port
 	return nil
 }
+
+// ApplyBenchApp applies the PortCfg to a BenchApp.
+func (cfg PortCfg) ApplyBenchApp(app *apps.BenchApp) error { // nolint:unparam // its okay that the error is always nil
+	app.Port = cfg.port
+	return nil
+}
+
+// ApplyConformanceApp applies the PortCfg to a ConformanceApp.
+func (cfg PortCfg) ApplyConformanceApp(app *apps.ConformanceApp) error { // nolint:unparam // its okay that the error is always nil
+	app.Port = cfg.port
+	return nil
+}
@@ -0,0 +1,37 @@
+package cfg
+
+import (
+	"risp/internal"
+	"risp/internal/app/apps"
+)
+
+// RecordCfg is configuration for the transcript the messages of a RISP client or server are recorded to.
+type RecordCfg struct {
+	path string
+}
+
+// NewRecordCfg creates a new RecordCfg from the given config.
+func NewRecordCfg(path string) *RecordCfg {
+	return &RecordCfg{
+		path: path,
+	}
+}
+
+// RecordFromEnv creates a new RecordCfg from the current environment.
+func RecordFromEnv() *RecordCfg {
+	return &RecordCfg{
+		path: internal.Record,
+	}
+}
+
+// ApplyClientApp applies the RecordCfg to a ClientApp.
+func (cfg RecordCfg) ApplyClientApp(app *apps.ClientApp) error { // nolint:unparam // its okay that the error is always nil
+	app.Record = cfg.path
+	return nil
+}
+
+// ApplyServerApp applies the RecordCfg to a ServerApp.
+func (cfg RecordCfg) ApplyServerApp(app *apps.ServerApp) error { // nolint:unparam // its okay that the error is always nil
+	app.Record = cfg.path
+	return nil
+}
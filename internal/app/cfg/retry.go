@@ -0,0 +1,44 @@
+package cfg
+
+import (
+	"time"
+
+	"risp/internal"
+	"risp/internal/app/apps"
+)
+
+// RetryCfg is configuration for the client reconnect policy.
+type RetryCfg struct {
+	maxAttempts int
+	maxElapsed  time.Duration
+}
+
+// NewRetryCfg creates a new RetryCfg from the given config.
+func NewRetryCfg(maxAttempts int, maxElapsed time.Duration) *RetryCfg {
+	return &RetryCfg{
+		maxAttempts: maxAttempts,
+		maxElapsed:  maxElapsed,
+	}
+}
+
+// RetryFromEnv creates a new RetryCfg from the current environment.
+func RetryFromEnv() *RetryCfg {
+	return &RetryCfg{
+		maxAttempts: internal.RetryMaxAttempts,
+		maxElapsed:  internal.RetryMaxElapsed,
+	}
+}
+
+// ApplyClientApp applies the RetryCfg to a ClientApp.
+func (cfg RetryCfg) ApplyClientApp(app *apps.ClientApp) error { // nolint:unparam // its okay that the error is always nil
+	app.RetryMaxAttempts = cfg.maxAttempts
+	app.RetryMaxElapsed = cfg.maxElapsed
+	return nil
+}
+
+// ApplyBenchApp applies the RetryCfg to a BenchApp.
+func (cfg RetryCfg) ApplyBenchApp(app *apps.BenchApp) error { // nolint:unparam // its okay that the error is always nil
+	app.RetryMaxAttempts = cfg.maxAttempts
+	app.RetryMaxElapsed = cfg.maxElapsed
+	return nil
+}
@@ -0,0 +1,52 @@
+package cfg
+
+import (
+	"risp/internal"
+	"risp/internal/app/apps"
+)
+
+// ServerAddrsCfg is configuration for the addresses of the RISP servers a client connects to.
+type ServerAddrsCfg struct {
+	addrs []string
+}
+
+// NewServerAddrsCfg creates a new ServerAddrsCfg from the given config.
+func NewServerAddrsCfg(addrs ...string) *ServerAddrsCfg {
+	return &ServerAddrsCfg{
+		addrs: addrs,
+	}
+}
+
+// ServerAddrsFromEnv creates a new ServerAddrsCfg from the current environment.
+func ServerAddrsFromEnv() *ServerAddrsCfg {
+	return &ServerAddrsCfg{
+		addrs: internal.ServerAddrs,
+	}
+}
+
+// ApplyClientApp applies the ServerAddrsCfg to a ClientApp.
+// No addresses leave the addresses of the ClientApp unset, so that it connects to the local server on its port.
+func (cfg ServerAddrsCfg) ApplyClientApp(app *apps.ClientApp) error { // nolint:unparam // its okay that the error is always nil
+	if len(cfg.addrs) > 0 {
+		app.ServerAddrs = cfg.addrs
+	}
+	return nil
+}
+
+// ApplyListApp applies the ServerAddrsCfg to a ListApp.
+func (cfg ServerAddrsCfg) ApplyListApp(app *apps.ListApp) error { // nolint:unparam // its okay that the error is always nil
+	app.ServerAddrs = cfg.addrs
+	return nil
+}
+
+// ApplyBenchApp applies the ServerAddrsCfg to a BenchApp.
+func (cfg ServerAddrsCfg) ApplyBenchApp(app *apps.BenchApp) error { // nolint:unparam // its okay that the error is always nil
+	app.ServerAddrs = cfg.addrs
+	return nil
+}
+
+// ApplyConformanceApp applies the ServerAddrsCfg to a ConformanceApp.
+func (cfg ServerAddrsCfg) ApplyConformanceApp(app *apps.ConformanceApp) error { // nolint:unparam // its okay that the error is always nil
+	app.ServerAddrs = cfg.addrs
+	return nil
+}
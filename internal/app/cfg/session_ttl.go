@@ -0,0 +1,33 @@
+package cfg
+
+import (
+	"time"
+
+	"risp/internal"
+	"risp/internal/app/apps"
+)
+
+// SessionTTLCfg is configuration for the time after which the sessions of a RISP server expire.
+type SessionTTLCfg struct {
+	ttl time.Duration
+}
+
+// NewSessionTTLCfg creates a new SessionTTLCfg from the given config.
+func NewSessionTTLCfg(ttl time.Duration) *SessionTTLCfg {
+	return &SessionTTLCfg{
+		ttl: ttl,
+	}
+}
+
+// SessionTTLFromEnv creates a new SessionTTLCfg from the current environment.
+func SessionTTLFromEnv() *SessionTTLCfg {
+	return &SessionTTLCfg{
+		ttl: internal.SessionTTL,
+	}
+}
+
+// ApplyServerApp applies the SessionTTLCfg to a ServerApp.
+func (cfg SessionTTLCfg) ApplyServerApp(app *apps.ServerApp) error { // nolint:unparam // its okay that the error is always nil
+	app.SessionTTL = cfg.ttl
+	return nil
+}
@@ -0,0 +1,31 @@
+package cfg
+
+import (
+	"risp/internal"
+	"risp/internal/app/apps"
+)
+
+// SigningKeyCfg is configuration for the private key a RISP server signs the checksums with.
+type SigningKeyCfg struct {
+	path string
+}
+
+// NewSigningKeyCfg creates a new SigningKeyCfg from the given config.
+func NewSigningKeyCfg(path string) *SigningKeyCfg {
+	return &SigningKeyCfg{
+		path: path,
+	}
+}
+
+// SigningKeyFromEnv creates a new SigningKeyCfg from the current environment.
+func SigningKeyFromEnv() *SigningKeyCfg {
+	return &SigningKeyCfg{
+		path: internal.SigningKey,
+	}
+}
+
+// ApplyServerApp applies the SigningKeyCfg to a ServerApp.
+func (cfg SigningKeyCfg) ApplyServerApp(app *apps.ServerApp) error { // nolint:unparam // its okay that the error is always nil
+	app.SigningKey = cfg.path
+	return nil
+}
@@ -0,0 +1,31 @@
+package cfg
+
+import (
+	"risp/internal"
+	"risp/internal/app/apps"
+)
+
+// StateFileCfg is configuration for the client checkpoint file.
+type StateFileCfg struct {
+	path string
+}
+
+// NewStateFileCfg creates a new StateFileCfg from the given config.
+func NewStateFileCfg(path string) *StateFileCfg {
+	return &StateFileCfg{
+		path: path,
+	}
+}
+
+// StateFileFromEnv creates a new StateFileCfg from the current environment.
+func StateFileFromEnv() *StateFileCfg {
+	return &StateFileCfg{
+		path: internal.StateFile,
+	}
+}
+
+// ApplyClientApp applies the StateFileCfg to a ClientApp.
+func (cfg StateFileCfg) ApplyClientApp(app *apps.ClientApp) error { // nolint:unparam // its okay that the error is always nil
+	app.StateFile = cfg.path
+	return nil
+}
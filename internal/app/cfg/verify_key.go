@@ -0,0 +1,31 @@
+package cfg
+
+import (
+	"risp/internal"
+	"risp/internal/app/apps"
+)
+
+// VerifyKeyCfg is configuration for the public key a RISP client verifies the signatures of the checksums with.
+type VerifyKeyCfg struct {
+	path string
+}
+
+// NewVerifyKeyCfg creates a new VerifyKeyCfg from the given config.
+func NewVerifyKeyCfg(path string) *VerifyKeyCfg {
+	return &VerifyKeyCfg{
+		path: path,
+	}
+}
+
+// VerifyKeyFromEnv creates a new VerifyKeyCfg from the current environment.
+func VerifyKeyFromEnv() *VerifyKeyCfg {
+	return &VerifyKeyCfg{
+		path: internal.VerifyKey,
+	}
+}
+
+// ApplyClientApp applies the VerifyKeyCfg to a ClientApp.
+func (cfg VerifyKeyCfg) ApplyClientApp(app *apps.ClientApp) error { // nolint:unparam // its okay that the error is always nil
+	app.VerifyKey = cfg.path
+	return nil
+}
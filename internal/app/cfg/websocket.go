@@ -0,0 +1,40 @@
+package cfg
+
+import (
+	"risp/internal"
+	"risp/internal/app/apps"
+)
+
+// WebSocketCfg is configuration for carrying the RISP streams over WebSockets.
+type WebSocketCfg struct {
+	port      uint16 // the port the server accepts WebSockets on, if any
+	websocket bool   // whether the client connects over a WebSocket
+}
+
+// NewWebSocketCfg creates a new WebSocketCfg from the given config.
+func NewWebSocketCfg(port uint16, websocket bool) *WebSocketCfg {
+	return &WebSocketCfg{
+		port:      port,
+		websocket: websocket,
+	}
+}
+
+// WebSocketFromEnv creates a new WebSocketCfg from the current environment.
+func WebSocketFromEnv() *WebSocketCfg {
+	return &WebSocketCfg{
+		port:      uint16(internal.WSPort),
+		websocket: internal.WebSocket,
+	}
+}
+
+// ApplyClientApp applies the WebSocketCfg to a ClientApp.
+func (cfg WebSocketCfg) ApplyClientApp(app *apps.ClientApp) error { // nolint:unparam // its okay that the error is always nil
+	app.WebSocket = cfg.websocket
+	return nil
+}
+
+// ApplyServerApp applies the WebSocketCfg to a ServerApp.
+func (cfg WebSocketCfg) ApplyServerApp(app *apps.ServerApp) error { // nolint:unparam // its okay that the error is always nil
+	app.WSPort = cfg.port
+	return nil
+}
@@ -2,16 +2,32 @@
 // By default, configuration is read from the envionment variables,
 // with sensible defaults in place.
 // These values can be overridden with command-line flags.
+//
+// Configuration can also be read from a YAML or TOML file given by --config, whose keys are the flag names.
+// A value set by a flag takes precedence over one set in the environment, which takes precedence over
+// one set in the config file. Every value is parsed as the type of its flag, and validated by ValidateEnv.
 package internal
 
 import (
+	"context"
 	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
 	"strings"
+	"sync"
+	"time"
+
+	"risp/internal/pkg/validate"
 
 	"github.com/davecgh/go-spew/spew"
+	"github.com/fsnotify/fsnotify"
 	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 )
 
@@ -26,76 +42,410 @@ const (
 	ProdEnv = "prod"
 )
 
+// The sources a configuration value can be set from.
+const (
+	// SourceDefault indicates that a value is the default.
+	SourceDefault = "default"
+	// SourceConfig indicates that a value is set in the config file.
+	SourceConfig = "config"
+	// SourceEnv indicates that a value is set by an environment variable.
+	SourceEnv = "env"
+	// SourceFlag indicates that a value is set by a command-line flag.
+	SourceFlag = "flag"
+)
+
 // Flag describes a piece of application configuration.
 type Flag struct {
 	Name         string
 	Usage        string
+	Validate     string // the validation tag the value must satisfy, see validate.Validate
 	defaultValue interface{}
 	Value        interface{}
+	source       string // where the value was set from
+	deprecatedMS string // the name of the integer milliseconds flag this one replaced, still read with a warning
+}
+
+// Setting describes the effective value of a piece of application configuration.
+type Setting struct {
+	Name   string
+	Value  string
+	Source string
 }
 
+// registry holds every flag registered with a command, in the order they were registered.
+var registry []*Flag
+
+// loaded is the command the configuration was last loaded for, so that it can be reloaded.
+var loaded *cobra.Command
+
 // Application configuration flags.
 var (
+	ConfigFlag = Flag{
+		Name:  "config",
+		Usage: "The path of a YAML or TOML config file, whose keys are the flag names. Leave unset to read no config file.",
+		Value: &Config,
+	}
 	EnvFlag = Flag{
-		Name:  "env",
-		Usage: "Describes the current environment and should be one of: local, test, dev, prod.",
-		Value: &Env,
+		Name:     "env",
+		Usage:    "Describes the current environment and should be one of: local, test, dev, prod.",
+		Value:    &Env,
+		Validate: "oneof=local test dev prod",
 	}
 	LogLevelFlag = Flag{
-		Name:  "log_level",
-		Usage: "Sets the log level and should be one of: debug, info, warn, error.",
-		Value: &LogLevel,
+		Name:     "log_level",
+		Usage:    "Sets the log level and should be one of: trace, debug, info, warn, error.",
+		Value:    &LogLevel,
+		Validate: "oneof=trace debug info warn error",
 	}
 
+	LogFormatFlag = Flag{
+		Name:     "log_format",
+		Usage:    "Sets the log format and should be one of: text, json.",
+		Value:    &LogFormat,
+		Validate: "oneof=text json",
+	}
+	LogSampleFlag = Flag{
+		Name:     "log_sample",
+		Usage:    "Log only one in every n data messages, which are logged at the debug level. Set to 1 to log every message.",
+		Value:    &LogSample,
+		Validate: "gte=1",
+	}
+	LogFileFlag = Flag{
+		Name:  "log_file",
+		Usage: "The path of the file logs are written to, which is rotated once it exceeds --log_max_size_mb. Leave unset to log to stderr.",
+		Value: &LogFile,
+	}
+	LogMaxSizeMBFlag = Flag{
+		Name:     "log_max_size_mb",
+		Usage:    "The size in megabytes after which the log file is rotated.",
+		Value:    &LogMaxSizeMB,
+		Validate: "gte=1",
+	}
+	LogMaxBackupsFlag = Flag{
+		Name:     "log_max_backups",
+		Usage:    "The maximum number of rotated log files to keep.",
+		Value:    &LogMaxBackups,
+		Validate: "gte=0",
+	}
+	TraceExporterFlag = Flag{
+		Name:     "trace_exporter",
+		Usage:    "Exports OpenTelemetry spans and should be one of: none, stdout, file, otlp.",
+		Value:    &TraceExporter,
+		Validate: "oneof=none stdout file otlp",
+	}
+	TraceFileFlag = Flag{
+		Name:  "trace_file",
+		Usage: "The path of the file spans are appended to by the file exporter.",
+		Value: &TraceFile,
+	}
+	TraceEndpointFlag = Flag{
+		Name:     "trace_endpoint",
+		Usage:    "The address (host:port) of the OTLP collector spans are sent to by the otlp exporter.",
+		Value:    &TraceEndpoint,
+		Validate: "hostname_port",
+	}
 	HealthPortFlag = Flag{
-		Name:  "health_port",
-		Usage: "The port the health server should listen on.",
-		Value: &HealthPort,
+		Name:     "health_port",
+		Usage:    "The port the health server should listen on.",
+		Value:    &HealthPort,
+		Validate: "gte=1,lte=65535",
 	}
 	PortFlag = Flag{
-		Name:  "port",
-		Usage: "The port the gRPC server should listen on.",
-		Value: &Port,
+		Name:     "port",
+		Usage:    "The port the gRPC server should listen on.",
+		Value:    &Port,
+		Validate: "gte=0,lte=65535", // the apps that need a port require it, unlike a client given the server addresses
 	}
 
 	MaxGoroutinesFlag = Flag{
-		Name:  "max_goroutines",
-		Usage: "The maximum allowed number of goroutines that can be spawned before healthchecks fail.",
-		Value: &MaxGoroutines,
+		Name:     "max_goroutines",
+		Usage:    "The maximum allowed number of goroutines that can be spawned before healthchecks fail.",
+		Value:    &MaxGoroutines,
+		Validate: "gte=1",
+	}
+
+	HeartbeatIntervalFlag = Flag{
+		Name:     "heartbeat_interval",
+		Usage:    "The interval between heartbeat messages sent to the peer, e.g. 5s.",
+		Value:    &HeartbeatInterval,
+		Validate: "gt=0",
+	}
+	IdleTimeoutFlag = Flag{
+		Name:     "idle_timeout",
+		Usage:    "The time without receiving any message after which the peer is considered dead and the connection is torn down, e.g. 30s.",
+		Value:    &IdleTimeout,
+		Validate: "gt=0",
+	}
+
+	ServerAddrFlag = Flag{
+		Name:     "server_addr",
+		Usage:    "The address (host:port) of a server the client should connect to. Repeat to fail over between servers. Defaults to localhost on the gRPC port.",
+		Value:    &ServerAddrs,
+		Validate: "dive,hostname_port",
+	}
+
+	ClientTickerFlag = Flag{
+		Name:         "client_ticker",
+		Usage:        "The interval between client messages, e.g. 2s.",
+		Value:        &ClientTicker,
+		Validate:     "gt=0",
+		deprecatedMS: "client_ticker_ms",
+	}
+
+	ClientKillswitchFlag = Flag{
+		Name:         "client_killswitch",
+		Usage:        "The interval between client disconnections, e.g. 10s. Leave unset to not trigger this behaviour.",
+		Value:        &ClientKillswitch,
+		Validate:     "gte=0",
+		deprecatedMS: "client_killswitch_ms",
+	}
+
+	RetryMaxAttemptsFlag = Flag{
+		Name:     "retry_max_attempts",
+		Usage:    "The maximum number of connection attempts before the client gives up. Set to 0 for no limit.",
+		Value:    &RetryMaxAttempts,
+		Validate: "gte=0",
+	}
+
+	RetryMaxElapsedFlag = Flag{
+		Name:     "retry_max_elapsed",
+		Usage:    "The maximum time the client spends reconnecting before it gives up, e.g. 5m. Set to 0 for no limit.",
+		Value:    &RetryMaxElapsed,
+		Validate: "gte=0",
+	}
+
+	StateFileFlag = Flag{
+		Name:  "state_file",
+		Usage: "The path of the file used to checkpoint the client state, so that a transfer can resume after a restart. Leave unset to disable checkpointing.",
+		Value: &StateFile,
+	}
+
+	ParallelismFlag = Flag{
+		Name:     "parallelism",
+		Usage:    "The number of streams over which ranges of the sequence are fetched in parallel.",
+		Value:    &Parallelism,
+		Validate: "gte=1",
+	}
+
+	LiveFlag = Flag{
+		Name:  "live",
+		Usage: "Receive a live stream of unbounded length, closing it after the number of items given as the argument, or when interrupted if none is given.",
+		Value: &Live,
+	}
+
+	OutputFlag = Flag{
+		Name:  "output",
+		Usage: "The path of the file the received sequence is written to once it is verified, or - for stdout. Leave unset to not write the sequence.",
+		Value: &Output,
+	}
+	FormatFlag = Flag{
+		Name:     "format",
+		Usage:    "The format the received sequence is written in and should be one of: text, json, csv, binary, protobuf.",
+		Value:    &Format,
+		Validate: "oneof=text json csv binary protobuf",
+	}
+	ServerTickerFlag = Flag{
+		Name:         "server_ticker",
+		Usage:        "The interval between server messages, e.g. 1s.",
+		Value:        &ServerTicker,
+		Validate:     "gt=0",
+		deprecatedMS: "server_ticker_ms",
+	}
+
+	DataDirFlag = Flag{
+		Name:  "data_dir",
+		Usage: "The directory of data files (.txt, .csv or .bin) from which named sequences are served. Leave unset to serve no named sequences.",
+		Value: &DataDir,
+	}
+
+	LiveIntervalFlag = Flag{
+		Name:     "live_interval",
+		Usage:    "The interval between the items produced for a live stream, e.g. 100ms.",
+		Value:    &LiveInterval,
+		Validate: "gt=0",
+	}
+
+	LiveLimitFlag = Flag{
+		Name:     "live_limit",
+		Usage:    "The number of items after which the server closes a live stream. Set to 0 for no limit.",
+		Value:    &LiveLimit,
+		Validate: "gte=0",
 	}
 
-	ClientTickerMSFlag = Flag{
-		Name:  "client_ticker_ms",
-		Usage: "The number of milliseconds between client messages.",
-		Value: &ClientTickerMS,
+	ReplayBufferFlag = Flag{
+		Name:     "replay_buffer",
+		Usage:    "The maximum number of unacknowledged items kept for each live stream, so that they can be sent again.",
+		Value:    &ReplayBuffer,
+		Validate: "gte=1",
 	}
 
-	ClientKillswitchMSFlag = Flag{
-		Name:  "client_killswitch_ms",
-		Usage: "The number of milliseconds between client disconnections. Leave unset to not trigger this behaviour.",
-		Value: &ClientKillswitchMS,
+	RecordFlag = Flag{
+		Name:  "record",
+		Usage: "The path of the file every message sent and received is recorded to, as a transcript that can be replayed with risp replay. Leave unset to not record.",
+		Value: &Record,
 	}
 
-	ServerTickerMSFlag = Flag{
-		Name:  "server_ticker_ms",
-		Usage: "The number of milliseconds between server messages.",
-		Value: &ServerTickerMS,
+	EncodingsFlag = Flag{
+		Name:     "encodings",
+		Usage:    "The encodings the client accepts for the server to send the items of each window in a batch, in order of preference, which should each be one of: packed, delta, gzip, zstd. Leave unset to receive each item in its own message.",
+		Value:    &Encodings,
+		Validate: "dive,oneof=packed delta gzip zstd",
+	}
+
+	HTTPPortFlag = Flag{
+		Name:     "http_port",
+		Usage:    "The port the HTTP/JSON gateway to the sessions should listen on, for clients that cannot stream over gRPC. Set to 0 to not serve the gateway.",
+		Value:    &HTTPPort,
+		Validate: "gte=0,lte=65535",
+	}
+
+	WSPortFlag = Flag{
+		Name:     "ws_port",
+		Usage:    "The port the server should accept WebSocket connections on, for clients behind proxies that block gRPC. Set to 0 to not accept WebSockets.",
+		Value:    &WSPort,
+		Validate: "gte=0,lte=65535",
+	}
+
+	SessionTTLFlag = Flag{
+		Name:     "session_ttl",
+		Usage:    "The time after which the session of a client that is not connected is cleared, so that it can no longer resume its transfer, e.g. 1h. Set to 0 to keep sessions until they are complete.",
+		Value:    &SessionTTL,
+		Validate: "gte=0",
+	}
+
+	AdminPortFlag = Flag{
+		Name:     "admin_port",
+		Usage:    "The port the admin interface should listen on, which reports the outcome of the last configuration reload and can trigger one. Set to 0 to not serve the admin interface.",
+		Value:    &AdminPort,
+		Validate: "gte=0,lte=65535",
+	}
+
+	WebSocketFlag = Flag{
+		Name:  "websocket",
+		Usage: "Connect to the servers over a WebSocket instead of a gRPC stream, in which case each server address is the address of its WebSocket port.",
+		Value: &WebSocket,
+	}
+
+	SigningKeyFlag = Flag{
+		Name:  "signing_key",
+		Usage: "The path of the PEM file holding the Ed25519 private key the server signs the checksum of every sequence with. Leave unset to not sign checksums.",
+		Value: &SigningKey,
+	}
+
+	VerifyKeyFlag = Flag{
+		Name:  "verify_key",
+		Usage: "The path of the PEM file holding the Ed25519 public key of the server, which the signature of the checksum must be valid for. Leave unset to not require signed checksums.",
+		Value: &VerifyKey,
+	}
+
+	BenchClientsFlag = Flag{
+		Name:     "bench_clients",
+		Usage:    "The number of concurrent clients the benchmark runs.",
+		Value:    &BenchClients,
+		Validate: "gte=1",
+	}
+
+	BenchLengthsFlag = Flag{
+		Name:     "bench_lengths",
+		Usage:    "The lengths of the sequences the benchmark transfers, which are spread evenly across the clients.",
+		Value:    &BenchLengths,
+		Validate: "min=1,dive,numeric",
+	}
+
+	BenchRampUpFlag = Flag{
+		Name:     "bench_ramp_up",
+		Usage:    "The time over which the start of the clients is spread evenly, e.g. 10s. Leave unset to start them all at once.",
+		Value:    &BenchRampUp,
+		Validate: "gte=0",
+	}
+
+	BenchDurationFlag = Flag{
+		Name:     "bench_duration",
+		Usage:    "The time during which each client starts a new transfer as soon as the last one ends, e.g. 1m. Leave unset for a single transfer per client.",
+		Value:    &BenchDuration,
+		Validate: "gte=0",
+	}
+
+	BenchReportFlag = Flag{
+		Name:     "bench_report",
+		Usage:    "The format of the benchmark report and should be one of: text, json.",
+		Value:    &BenchReport,
+		Validate: "oneof=text json",
+	}
+
+	ConformanceTimeoutFlag = Flag{
+		Name:     "conformance_timeout",
+		Usage:    "The time to wait for each expected message from the server, and for which the server must stay silent when none is expected, e.g. 5s. It should be several times the server ticker.",
+		Value:    &ConformanceTimeout,
+		Validate: "gt=0",
+	}
+
+	InspectFormatFlag = Flag{
+		Name:     "inspect_format",
+		Usage:    "The format of the transcript timelines and should be one of: text, json.",
+		Value:    &InspectFormat,
+		Validate: "oneof=text json",
 	}
 )
 
 // Application configuration variables.
 var (
-	Env      string
-	LogLevel string
+	Config        string
+	Env           string
+	LogLevel      string
+	LogFormat     string
+	LogSample     int
+	LogFile       string
+	LogMaxSizeMB  int
+	LogMaxBackups int
+
+	TraceExporter string
+	TraceFile     string
+	TraceEndpoint string
 
 	HealthPort int
 	Port       int
 
 	MaxGoroutines int
 
-	ClientTickerMS     int
-	ClientKillswitchMS int
-	ServerTickerMS     int
+	HeartbeatInterval time.Duration
+	IdleTimeout       time.Duration
+
+	ServerAddrs []string
+
+	ClientTicker     time.Duration
+	ClientKillswitch time.Duration
+	RetryMaxAttempts int
+	RetryMaxElapsed  time.Duration
+	StateFile        string
+	Parallelism      int
+	Live             bool
+	Output           string
+	Format           string
+	ServerTicker     time.Duration
+	DataDir          string
+	LiveInterval     time.Duration
+	LiveLimit        int
+	ReplayBuffer     int
+	Record           string
+	Encodings        []string
+	SigningKey       string
+	HTTPPort         int
+	WSPort           int
+	SessionTTL       time.Duration
+	AdminPort        int
+	WebSocket        bool
+	VerifyKey        string
+
+	BenchClients  int
+	BenchLengths  []string
+	BenchRampUp   time.Duration
+	BenchDuration time.Duration
+	BenchReport   string
+
+	ConformanceTimeout time.Duration
+
+	InspectFormat string
 )
 
 // setDefault sets the default value of the flag to the given value iff
@@ -122,6 +472,11 @@ func setDefault(flag *Flag, value interface{}) {
 		flag.defaultValue = viper.GetBool(flag.Name)
 		valueVar := flag.Value.(*bool)
 		*valueVar = viper.GetBool(flag.Name)
+	case time.Duration:
+		viper.SetDefault(flag.Name, v)
+		flag.defaultValue = viper.GetDuration(flag.Name)
+		valueVar := flag.Value.(*time.Duration)
+		*valueVar = viper.GetDuration(flag.Name)
 	default:
 		panic(errors.Wrap(fmt.Errorf("unsupported flag type %T for flag %s", v, spew.Sdump(flag)), "set default failed"))
 	}
@@ -130,67 +485,412 @@ func setDefault(flag *Flag, value interface{}) {
 func init() {
 	viper.AutomaticEnv()
 
+	setDefault(&ConfigFlag, "")
 	setDefault(&EnvFlag, "local")
 	setDefault(&LogLevelFlag, "debug")
+	setDefault(&LogFormatFlag, "text")
+	setDefault(&LogSampleFlag, 1)
+	setDefault(&LogFileFlag, "")
+	setDefault(&LogMaxSizeMBFlag, 100)
+	setDefault(&LogMaxBackupsFlag, 3)
+	setDefault(&TraceExporterFlag, "none")
+	setDefault(&TraceFileFlag, "trace.json")
+	setDefault(&TraceEndpointFlag, "localhost:4317")
 
 	setDefault(&HealthPortFlag, 8080)
 	setDefault(&PortFlag, 8081)
 
 	setDefault(&MaxGoroutinesFlag, 200)
 
-	setDefault(&ClientTickerMSFlag, 2000)
-	setDefault(&ClientKillswitchMSFlag, 0)
-	setDefault(&ServerTickerMSFlag, 1000)
+	setDefault(&HeartbeatIntervalFlag, 5*time.Second)
+	setDefault(&IdleTimeoutFlag, 30*time.Second)
+
+	setDefault(&ServerAddrFlag, []string{})
+
+	setDefault(&ClientTickerFlag, 2*time.Second)
+	setDefault(&ClientKillswitchFlag, time.Duration(0))
+	setDefault(&RetryMaxAttemptsFlag, 100)
+	setDefault(&RetryMaxElapsedFlag, 10*time.Minute)
+	setDefault(&StateFileFlag, "")
+	setDefault(&ParallelismFlag, 1)
+	setDefault(&LiveFlag, false)
+	setDefault(&OutputFlag, "")
+	setDefault(&FormatFlag, "text")
+	setDefault(&ServerTickerFlag, time.Second)
+	setDefault(&DataDirFlag, "")
+	setDefault(&LiveIntervalFlag, 100*time.Millisecond)
+	setDefault(&LiveLimitFlag, 0)
+	setDefault(&ReplayBufferFlag, 4096)
+	setDefault(&RecordFlag, "")
+	setDefault(&EncodingsFlag, []string{})
+	setDefault(&SigningKeyFlag, "")
+	setDefault(&HTTPPortFlag, 0)
+	setDefault(&WSPortFlag, 0)
+	setDefault(&SessionTTLFlag, time.Duration(0))
+	setDefault(&AdminPortFlag, 0)
+	setDefault(&WebSocketFlag, false)
+	setDefault(&VerifyKeyFlag, "")
+
+	setDefault(&BenchClientsFlag, 10)
+	setDefault(&BenchLengthsFlag, []string{"100"})
+	setDefault(&BenchRampUpFlag, time.Duration(0))
+	setDefault(&BenchDurationFlag, time.Duration(0))
+	setDefault(&BenchReportFlag, "text")
+
+	setDefault(&ConformanceTimeoutFlag, 5*time.Second)
+
+	setDefault(&InspectFormatFlag, "text")
 }
 
 // RegisterCommandFlags registers the given flags with cobra.
 func RegisterCommandFlags(cmd *cobra.Command, flags []*Flag) error {
 	for _, flag := range flags {
-		switch defaultVal := flag.defaultValue.(type) {
-		case string:
-			val := flag.Value.(*string)
-			cmd.PersistentFlags().StringVar(val, flag.Name, defaultVal, flag.Usage)
-		case []string:
-			val := flag.Value.(*[]string)
-			cmd.PersistentFlags().StringSliceVar(val, flag.Name, defaultVal, flag.Usage)
-		case int:
-			val := flag.Value.(*int)
-			cmd.PersistentFlags().IntVar(val, flag.Name, defaultVal, flag.Usage)
-		case bool:
-			val := flag.Value.(*bool)
-			cmd.PersistentFlags().BoolVar(val, flag.Name, defaultVal, flag.Usage)
-		default:
-			return fmt.Errorf("unsupported flag type %T for flag %s", defaultVal, spew.Sdump(flag))
+		if err := addFlag(cmd.PersistentFlags(), flag, flag.defaultValue); err != nil {
+			return err
+		}
+		if !registered(flag) {
+			registry = append(registry, flag)
 		}
 	}
 	return nil
 }
 
+// registered reports whether the flag has been registered with any command.
+func registered(flag *Flag) bool {
+	for _, f := range registry {
+		if f == flag {
+			return true
+		}
+	}
+	return false
+}
+
+// addFlag adds the flag to the flag set, with the given default value.
+func addFlag(fs *pflag.FlagSet, flag *Flag, defaultValue interface{}) error {
+	switch defaultVal := defaultValue.(type) {
+	case string:
+		val := flag.Value.(*string)
+		fs.StringVar(val, flag.Name, defaultVal, flag.Usage)
+	case []string:
+		val := flag.Value.(*[]string)
+		fs.StringSliceVar(val, flag.Name, defaultVal, flag.Usage)
+	case int:
+		val := flag.Value.(*int)
+		fs.IntVar(val, flag.Name, defaultVal, flag.Usage)
+	case bool:
+		val := flag.Value.(*bool)
+		fs.BoolVar(val, flag.Name, defaultVal, flag.Usage)
+	case time.Duration:
+		val := flag.Value.(*time.Duration)
+		fs.DurationVar(val, flag.Name, defaultVal, flag.Usage)
+	default:
+		return fmt.Errorf("unsupported flag type %T for flag %s", defaultVal, spew.Sdump(flag))
+	}
+	return nil
+}
+
+// value returns the current value of the flag.
+func (flag *Flag) value() interface{} {
+	return reflect.ValueOf(flag.Value).Elem().Interface()
+}
+
+// formatValue formats a configuration value in the form it is given on the command line.
+func formatValue(v interface{}) string {
+	switch v := v.(type) {
+	case []string:
+		return strings.Join(v, ",")
+	case []interface{}:
+		items := make([]string, len(v))
+		for i := range v {
+			items[i] = fmt.Sprint(v[i])
+		}
+		return strings.Join(items, ",")
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// lookup returns the value of the flag from the environment or the config file, and its source,
+// or false if it is set in neither. A flag that replaced an integer milliseconds flag is also read
+// from the environment variable or config file key of the old flag, with a deprecation warning.
+func lookup(flag *Flag) (string, string, bool) {
+	if v, source, ok := lookupKey(flag.Name); ok {
+		return v, source, true
+	}
+	if flag.deprecatedMS == "" {
+		return "", "", false
+	}
+	v, source, ok := lookupKey(flag.deprecatedMS)
+	if !ok {
+		return "", "", false
+	}
+	logrus.Warningf("%s from %s is deprecated, set %s to a duration such as %sms instead", flag.deprecatedMS, source, flag.Name, v)
+	return v + "ms", source, true
+}
+
+// lookupKey returns the value of the key from the environment or the config file, and its source,
+// or false if it is set in neither.
+func lookupKey(key string) (string, string, bool) {
+	// an empty environment variable is treated as unset, as by viper
+	if v, ok := os.LookupEnv(strings.ToUpper(key)); ok && v != "" {
+		return v, SourceEnv, true
+	}
+	if viper.InConfig(key) {
+		return formatValue(viper.Get(key)), SourceConfig, true
+	}
+	return "", "", false
+}
+
+// stagedValue is the value of a flag that has been loaded, but not yet set.
+type stagedValue struct {
+	value  interface{}
+	source string
+}
+
+// configMu serialises the loading of the configuration, so that the values of the flags are set by one load at a time.
+var configMu sync.Mutex
+
+// LoadConfig reads the config file, if one is given, and sets every registered flag that is not set on the
+// command line from the environment or the config file, recording where each value was set from.
+// Values are parsed as the type of their flag, so that a malformed value is an error rather than a zero value.
+// Nothing is set unless every value can be parsed.
+func LoadConfig(cmd *cobra.Command) error {
+	configMu.Lock()
+	defer configMu.Unlock()
+	values, err := stage(cmd)
+	if err != nil {
+		return err
+	}
+	apply(values)
+	loaded = cmd
+	return nil
+}
+
+// stage reads the config file, if one is given, and returns the value of every registered flag for the command,
+// without setting any of them.
+func stage(cmd *cobra.Command) (map[*Flag]stagedValue, error) {
+	values := make(map[*Flag]stagedValue, len(registry))
+	// the values are parsed by a flag set of their own, into variables of their own
+	fs := pflag.NewFlagSet("config", pflag.ContinueOnError)
+	load := func(flag *Flag) error {
+		if f := cmd.Flags().Lookup(flag.Name); f != nil && f.Changed {
+			values[flag] = stagedValue{value: flag.value(), source: SourceFlag}
+			return nil
+		}
+		v, source, ok := lookup(flag)
+		if !ok {
+			// a value removed from the config file since it was last loaded returns to its default
+			values[flag] = stagedValue{value: flag.defaultValue, source: SourceDefault}
+			return nil
+		}
+		parsed := *flag
+		parsed.Value = reflect.New(reflect.TypeOf(flag.Value).Elem()).Interface()
+		if err := addFlag(fs, &parsed, flag.defaultValue); err != nil {
+			return err
+		}
+		if err := fs.Set(flag.Name, v); err != nil {
+			return errors.Wrapf(err, "invalid value %q for %s from %s", v, flag.Name, source)
+		}
+		values[flag] = stagedValue{value: parsed.value(), source: source}
+		return nil
+	}
+	// the path of the config file can only be set by a flag or in the environment
+	if err := load(&ConfigFlag); err != nil {
+		return nil, err
+	}
+	if config := values[&ConfigFlag].value.(string); config != "" {
+		viper.SetConfigFile(config)
+		if err := viper.ReadInConfig(); err != nil {
+			return nil, errors.Wrapf(err, "read config file %s failed", config)
+		}
+		for _, key := range viper.AllKeys() {
+			if viper.InConfig(key) && !knownKey(key) {
+				return nil, errors.Errorf("unknown key %q in config file %s", key, config)
+			}
+		}
+	}
+	for _, flag := range registry {
+		if flag == &ConfigFlag {
+			continue
+		}
+		if err := load(flag); err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
+// apply sets the flags to the staged values.
+func apply(values map[*Flag]stagedValue) {
+	for flag, v := range values {
+		reflect.ValueOf(flag.Value).Elem().Set(reflect.ValueOf(v.value))
+		flag.source = v.source
+	}
+}
+
+// Reload loads the configuration again for the command it was last loaded for, so that changes to the config
+// file take effect, and validates it. The values set on the command line are kept. Nothing is set unless the
+// whole configuration is valid, so the values in effect are kept if the reload fails.
+func Reload() error {
+	configMu.Lock()
+	defer configMu.Unlock()
+	if loaded == nil {
+		return errors.New("no configuration loaded")
+	}
+	values, err := stage(loaded)
+	if err != nil {
+		return err
+	}
+	if env, ok := values[&EnvFlag]; ok {
+		if env.value, err = normaliseEnvString(env.value.(string)); err != nil {
+			return errors.Wrap(err, "normalise env string failed")
+		}
+		values[&EnvFlag] = env
+	}
+	for _, flag := range registry {
+		if v, ok := values[flag]; ok && inScope(flag) {
+			if err := validateValue(flag, v.value); err != nil {
+				return err
+			}
+		}
+	}
+	apply(values)
+	return nil
+}
+
+// configSettleTime is how long the config file must go unchanged before a change is notified.
+const configSettleTime = 100 * time.Millisecond
+
+// WatchConfig notifies on the returned channel when the config file changes, until the context is done.
+// The channel is nil if there is no config file to watch.
+func WatchConfig(ctx context.Context) (<-chan struct{}, error) {
+	if Config == "" {
+		return nil, nil
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, errors.Wrap(err, "create config watcher failed")
+	}
+	// the directory is watched, since editors often replace the file rather than write to it
+	path := filepath.Clean(Config)
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		_ = watcher.Close()
+		return nil, errors.Wrap(err, "watch config file failed")
+	}
+	changed := make(chan struct{}, 1)
+	go func() {
+		defer watcher.Close() // nolint: errcheck // nothing is left to watch
+		// a change is notified once the file has been quiet for a while, so that a file that is being written
+		// is not read before it is complete
+		settle := time.NewTimer(0)
+		<-settle.C
+		defer settle.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(event.Name) == path && event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) != 0 {
+					settle.Reset(configSettleTime)
+				}
+			case <-settle.C:
+				// changes are coalesced until the last one is handled
+				select {
+				case changed <- struct{}{}:
+				default:
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				logrus.Warning(errors.Wrap(err, "watch config file failed"))
+			}
+		}
+	}()
+	return changed, nil
+}
+
+// knownKey reports whether the key of the config file is the name of a registered flag.
+func knownKey(key string) bool {
+	for _, flag := range registry {
+		if (flag.Name == key || flag.deprecatedMS == key) && flag != &ConfigFlag {
+			return true
+		}
+	}
+	return false
+}
+
+// Settings returns the effective value of every registered flag and where it was set from, sorted by name.
+func Settings() []Setting {
+	settings := make([]Setting, 0, len(registry))
+	for _, flag := range registry {
+		source := flag.source
+		if source == "" {
+			source = SourceDefault
+		}
+		settings = append(settings, Setting{
+			Name:   flag.Name,
+			Value:  formatValue(flag.value()),
+			Source: source,
+		})
+	}
+	sort.Slice(settings, func(i, j int) bool {
+		return settings[i].Name < settings[j].Name
+	})
+	return settings
+}
+
 func normaliseEnvString(env string) (string, error) {
 	normalised := strings.ToLower(env)
 	// permit long form spellings
-	if normalised == "Development" {
+	if normalised == "development" {
 		normalised = DevEnv
-	} else if normalised == "Production" {
+	} else if normalised == "production" {
 		normalised = ProdEnv
 	}
 	// ensure env is a valid value
 	if normalised != TestEnv && normalised != LocalEnv && normalised != DevEnv && normalised != ProdEnv {
-		return "", errors.New("Invalid environment: " + normalised)
+		return "", errors.New("invalid environment: " + env)
 	}
 	return normalised, nil
 }
 
 // ValidateEnv ensures the environment is valid, fixing any problems where possible,
-// and returns any error encountered.
+// and validates the value of every flag of the command the configuration was loaded for,
+// returning any error encountered.
 func ValidateEnv() error {
 	norm, err := normaliseEnvString(Env)
 	if err != nil {
 		return errors.Wrap(err, "normalise env string failed")
 	}
-	viper.Set(Env, norm)
-	if err != nil {
-		return err
+	Env = norm
+	for _, flag := range registry {
+		if !inScope(flag) {
+			continue
+		}
+		if err := validateValue(flag, flag.value()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// inScope reports whether the flag is a flag of the command the configuration was loaded for.
+// The flags of the other commands are not used, so are not validated.
+func inScope(flag *Flag) bool {
+	return loaded == nil || loaded.Flag(flag.Name) != nil
+}
+
+// validateValue validates the value for the flag.
+func validateValue(flag *Flag, v interface{}) error {
+	if flag.Validate == "" {
+		return nil
+	}
+	if err := validate.Validate().Var(v, flag.Validate); err != nil {
+		return errors.Errorf("invalid value %q for %s: must satisfy %q", formatValue(v), flag.Name, flag.Validate)
 	}
 	return nil
 }
@@ -0,0 +1,170 @@
+package internal
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestCommand creates a command with the given flags, parsing the given arguments.
+func newTestCommand(t *testing.T, flags []*Flag, args ...string) *cobra.Command {
+	t.Helper()
+	cmd := &cobra.Command{}
+	require.NoError(t, RegisterCommandFlags(cmd, flags))
+	require.NoError(t, cmd.ParseFlags(args))
+	return cmd
+}
+
+// TestLoadConfig is not parallel, since the configuration is global.
+func TestLoadConfig(t *testing.T) {
+	t.Cleanup(viper.Reset)
+	path := filepath.Join(t.TempDir(), "risp.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("port: 9000\nhealth_port: 9001\nidle_timeout: 1m\nserver_addr: [a:1, b:2]\n"), 0o600))
+	t.Setenv("HEALTH_PORT", "9002")
+	t.Setenv("LOG_FORMAT", "")
+
+	cmd := newTestCommand(t, []*Flag{&ConfigFlag, &PortFlag, &HealthPortFlag, &IdleTimeoutFlag, &ServerAddrFlag, &LogFormatFlag, &LiveLimitFlag},
+		"--config", path, "--live_limit", "7")
+	require.NoError(t, LoadConfig(cmd))
+	require.Equal(t, 9000, Port)
+	require.Equal(t, 9002, HealthPort)
+	require.Equal(t, time.Minute, IdleTimeout)
+	require.Equal(t, []string{"a:1", "b:2"}, ServerAddrs)
+	require.Equal(t, 7, LiveLimit)
+
+	sources := make(map[string]string)
+	for _, s := range Settings() {
+		sources[s.Name] = s.Source
+	}
+	require.Equal(t, SourceFlag, sources["config"])
+	require.Equal(t, SourceConfig, sources["port"])
+	require.Equal(t, SourceEnv, sources["health_port"])
+	require.Equal(t, SourceConfig, sources["server_addr"])
+	require.Equal(t, SourceDefault, sources["log_format"])
+	require.Equal(t, SourceFlag, sources["live_limit"])
+	require.NoError(t, ValidateEnv())
+
+	t.Setenv("PORT", "nonsense")
+	require.Error(t, LoadConfig(cmd))
+	t.Setenv("PORT", "")
+
+	require.NoError(t, os.WriteFile(path, []byte("prt: 9000\n"), 0o600))
+	require.Error(t, LoadConfig(cmd))
+
+	require.NoError(t, os.WriteFile(path, []byte("idle_timeout: 30\n"), 0o600))
+	require.Error(t, LoadConfig(cmd))
+
+	require.NoError(t, os.WriteFile(path, []byte("port: 70000\n"), 0o600))
+	require.NoError(t, LoadConfig(cmd))
+	require.Error(t, ValidateEnv())
+}
+
+// TestReload is not parallel, since the configuration is global.
+func TestReload(t *testing.T) {
+	t.Cleanup(viper.Reset)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	path := filepath.Join(t.TempDir(), "risp.toml")
+	require.NoError(t, os.WriteFile(path, []byte("server_ticker = \"5ms\"\n"), 0o600))
+
+	cmd := newTestCommand(t, []*Flag{&ConfigFlag, &EnvFlag, &ServerTickerFlag, &LiveLimitFlag}, "--config", path)
+	require.NoError(t, LoadConfig(cmd))
+	require.Equal(t, 5*time.Millisecond, ServerTicker)
+	changed, err := WatchConfig(ctx)
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(path, []byte("live_limit = 10\n"), 0o600))
+	select {
+	case <-changed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("config change not noticed")
+	}
+	require.NoError(t, Reload())
+	require.Equal(t, 10, LiveLimit)
+	// the value removed from the config file returns to its default
+	require.Equal(t, time.Second, ServerTicker)
+
+	// a rejected reload keeps the values in effect, including those that were valid
+	require.NoError(t, os.WriteFile(path, []byte("live_limit = -1\nserver_ticker = \"7ms\"\n"), 0o600))
+	require.Error(t, Reload())
+	require.Equal(t, 10, LiveLimit)
+	require.Equal(t, time.Second, ServerTicker)
+	sources := make(map[string]string)
+	for _, s := range Settings() {
+		sources[s.Name] = s.Source
+	}
+	require.Equal(t, SourceConfig, sources["live_limit"])
+	require.Equal(t, SourceDefault, sources["server_ticker"])
+}
+
+// TestDeprecatedMS is not parallel, since the configuration is global.
+func TestDeprecatedMS(t *testing.T) {
+	t.Cleanup(viper.Reset)
+	path := filepath.Join(t.TempDir(), "risp.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("client_killswitch_ms: 3000\n"), 0o600))
+	t.Setenv("SERVER_TICKER_MS", "10")
+	t.Setenv("CLIENT_TICKER_MS", "500")
+	t.Setenv("CLIENT_TICKER", "")
+
+	cmd := newTestCommand(t, []*Flag{&ConfigFlag, &ClientTickerFlag, &ClientKillswitchFlag, &ServerTickerFlag}, "--config", path)
+	require.NoError(t, LoadConfig(cmd))
+	require.Equal(t, 10*time.Millisecond, ServerTicker)
+	require.Equal(t, 500*time.Millisecond, ClientTicker)
+	require.Equal(t, 3*time.Second, ClientKillswitch)
+
+	// the new flag takes precedence over the deprecated one
+	t.Setenv("CLIENT_TICKER", "1s")
+	require.NoError(t, LoadConfig(cmd))
+	require.Equal(t, time.Second, ClientTicker)
+
+	t.Setenv("SERVER_TICKER_MS", "nonsense")
+	require.Error(t, LoadConfig(cmd))
+}
+
+// TestValidateEnvScope is not parallel, since the configuration is global.
+func TestValidateEnvScope(t *testing.T) {
+	t.Cleanup(viper.Reset)
+	t.Setenv("LIVE_LIMIT", "-1")
+
+	// the flags of another command are not validated
+	cmd := newTestCommand(t, []*Flag{&PortFlag})
+	other := newTestCommand(t, []*Flag{&LiveLimitFlag})
+	require.NoError(t, LoadConfig(cmd))
+	require.NoError(t, ValidateEnv())
+
+	require.NoError(t, LoadConfig(other))
+	require.Error(t, ValidateEnv())
+}
+
+func TestNormaliseEnvString(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		env  string
+		want string
+	}{
+		{"local", LocalEnv},
+		{"Test", TestEnv},
+		{"Development", DevEnv},
+		{"PRODUCTION", ProdEnv},
+		{"staging", ""},
+	}
+	for i := range tests {
+		tt := tests[i]
+		t.Run(tt.env, func(t *testing.T) {
+			t.Parallel()
+			got, err := normaliseEnvString(tt.env)
+			if tt.want == "" {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
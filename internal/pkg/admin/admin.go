@@ -0,0 +1,123 @@
+// Package admin serves the administration interface of a running server over HTTP.
+//
+// The interface reports the outcome of the last configuration reload, whatever triggered it, and can trigger
+// a reload itself:
+//
+//   - GET /v1/reload returns the outcome of the last reload, or 404 if there was none.
+//   - POST /v1/reload reloads the configuration, and returns the outcome, with 422 if the reload failed.
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+var logger logrus.FieldLogger = logrus.StandardLogger()
+
+// ReloadPath is the path at which the outcome of the last reload is reported.
+const ReloadPath = "/v1/reload"
+
+// The triggers of a reload.
+const (
+	// TriggerSignal indicates that the server received SIGHUP.
+	TriggerSignal = "sighup"
+	// TriggerConfig indicates that the config file changed.
+	TriggerConfig = "config"
+	// TriggerAdmin indicates that the reload was requested through the admin interface.
+	TriggerAdmin = "admin"
+)
+
+// Reloader reloads the configuration, and returns the settings in effect afterwards.
+// Nothing is applied unless the whole configuration is valid.
+type Reloader func() (map[string]interface{}, error)
+
+// Outcome is the outcome of a reload.
+type Outcome struct {
+	Time     time.Time              `json:"time"`
+	Trigger  string                 `json:"trigger"`
+	Error    string                 `json:"error,omitempty"`
+	Settings map[string]interface{} `json:"settings,omitempty"` // the settings in effect after a successful reload
+}
+
+// ErrorResponse is the response of the admin interface to a request that failed.
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
+// Admin reloads the configuration of a server, and keeps track of the outcome of the last reload.
+type Admin struct {
+	reload Reloader
+
+	mu   sync.Mutex // serialises reloads
+	last *Outcome
+}
+
+// New creates a new Admin that reloads the configuration with the given reloader.
+func New(reload Reloader) *Admin {
+	return &Admin{reload: reload}
+}
+
+// Reload reloads the configuration, logs and records the outcome, and returns it.
+func (a *Admin) Reload(trigger string) Outcome {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	outcome := Outcome{Time: time.Now().UTC(), Trigger: trigger}
+	settings, err := a.reload()
+	if err != nil {
+		outcome.Error = err.Error()
+		logger.WithField("trigger", trigger).WithError(err).Error("reload failed, keeping the current configuration")
+	} else {
+		outcome.Settings = settings
+		logger.WithField("trigger", trigger).WithFields(settings).Info("reloaded configuration")
+	}
+	a.last = &outcome
+	return outcome
+}
+
+// Last returns the outcome of the last reload, if there was one.
+func (a *Admin) Last() (Outcome, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.last == nil {
+		return Outcome{}, false
+	}
+	return *a.last, true
+}
+
+// ServeHTTP implements http.Handler.
+func (a *Admin) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != ReloadPath {
+		writeJSON(w, http.StatusNotFound, &ErrorResponse{Error: "no such path " + r.URL.Path})
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		outcome, ok := a.Last()
+		if !ok {
+			writeJSON(w, http.StatusNotFound, &ErrorResponse{Error: "the configuration was not reloaded yet"})
+			return
+		}
+		writeJSON(w, http.StatusOK, &outcome)
+	case http.MethodPost:
+		outcome := a.Reload(TriggerAdmin)
+		if outcome.Error != "" {
+			writeJSON(w, http.StatusUnprocessableEntity, &outcome)
+			return
+		}
+		writeJSON(w, http.StatusOK, &outcome)
+	default:
+		w.Header().Set("Allow", http.MethodGet+", "+http.MethodPost)
+		writeJSON(w, http.StatusMethodNotAllowed, &ErrorResponse{Error: "method not allowed"})
+	}
+}
+
+// writeJSON writes the response as JSON with the given status.
+func writeJSON(w http.ResponseWriter, code int, res interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(res)
+}
@@ -0,0 +1,61 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdmin(t *testing.T) {
+	t.Parallel()
+	var fail bool
+	a := New(func() (map[string]interface{}, error) {
+		if fail {
+			return nil, errors.New("invalid config")
+		}
+		return map[string]interface{}{"session_ttl": "1m0s"}, nil
+	})
+	ts := httptest.NewServer(a)
+	t.Cleanup(ts.Close)
+	do := func(method, path string) (int, Outcome) {
+		req, err := http.NewRequest(method, ts.URL+path, nil)
+		require.NoError(t, err)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var outcome Outcome
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&outcome))
+		return resp.StatusCode, outcome
+	}
+
+	code, _ := do(http.MethodGet, ReloadPath)
+	require.Equal(t, http.StatusNotFound, code)
+
+	// a reload by any trigger is reported
+	a.Reload(TriggerSignal)
+	code, outcome := do(http.MethodGet, ReloadPath)
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, TriggerSignal, outcome.Trigger)
+	require.Empty(t, outcome.Error)
+	require.Equal(t, "1m0s", outcome.Settings["session_ttl"])
+
+	// including one requested through the admin interface that failed
+	fail = true
+	code, outcome = do(http.MethodPost, ReloadPath)
+	require.Equal(t, http.StatusUnprocessableEntity, code)
+	require.Equal(t, "invalid config", outcome.Error)
+	code, outcome = do(http.MethodGet, ReloadPath)
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, TriggerAdmin, outcome.Trigger)
+	require.Equal(t, "invalid config", outcome.Error)
+	require.Empty(t, outcome.Settings)
+
+	code, _ = do(http.MethodDelete, ReloadPath)
+	require.Equal(t, http.StatusMethodNotAllowed, code)
+	code, _ = do(http.MethodGet, "/v1/other")
+	require.Equal(t, http.StatusNotFound, code)
+}
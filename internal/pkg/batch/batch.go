@@ -0,0 +1,188 @@
+// Package batch implements the encodings of the batches of items sent by a RISP server.
+//
+// By default the server sends each item in the payload of its own message. If the client lists the encodings
+// it accepts in the handshake, the server picks the first of them that it supports, confirms it in the handshake
+// reply, and then sends the items of each window in a single message, as a block of bytes in that encoding.
+// The encoding is negotiated per session, so that the transfers multiplexed over a stream can use different ones.
+//
+// The encodings trade CPU for bytes on the wire:
+//
+//   - PACKED encodes each item as a varint, which is smaller than a message per item whatever the items.
+//   - DELTA encodes each item as the zigzag varint of its difference from the item before it, which is a
+//     byte or two for near-monotonic sequences, such as counters or timestamps.
+//   - GZIP compresses the packed items, which pays off for sequences with repeated patterns.
+//   - ZSTD compresses the packed items like GZIP, but is faster to compress and decompress at a similar ratio.
+package batch
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"io"
+	"math"
+
+	risppb "risp/api/proto/gen/pb-go/github.com/mschristensen/risp/api/build/go"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pkg/errors"
+)
+
+// ErrCorrupt indicates that a batch could not be decoded.
+var ErrCorrupt = errors.New("corrupt batch")
+
+// maxPacked is the size of the largest batch of packed items, which takes at most the largest varint for each item
+// of the largest window.
+const maxPacked = math.MaxUint16 * binary.MaxVarintLen32
+
+// The zstd encoder and decoder are safe to share between the sessions, as they only encode and decode whole batches.
+// The decoder does not inflate a batch beyond the largest batch of packed items, whatever the batch claims.
+var (
+	zstdEncoder, _ = zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+	zstdDecoder, _ = zstd.NewReader(nil, zstd.WithDecoderConcurrency(1), zstd.WithDecoderMaxMemory(maxPacked))
+)
+
+// Supported are the encodings supported by this implementation.
+var Supported = []risppb.Encoding{
+	risppb.Encoding_PACKED,
+	risppb.Encoding_DELTA,
+	risppb.Encoding_GZIP,
+	risppb.Encoding_ZSTD,
+}
+
+// IsSupported reports whether the encoding is supported.
+func IsSupported(encoding risppb.Encoding) bool {
+	for _, supported := range Supported {
+		if encoding == supported {
+			return true
+		}
+	}
+	return false
+}
+
+// Negotiate returns the first of the encodings accepted by the client that is supported,
+// or NONE if there is none, in which case the items are not batched.
+func Negotiate(accepted []risppb.Encoding) risppb.Encoding {
+	for _, encoding := range accepted {
+		if IsSupported(encoding) {
+			return encoding
+		}
+	}
+	return risppb.Encoding_NONE
+}
+
+// Encode encodes the items as a batch.
+func Encode(encoding risppb.Encoding, items []uint32) ([]byte, error) {
+	switch encoding {
+	case risppb.Encoding_PACKED:
+		return packed(items), nil
+	case risppb.Encoding_DELTA:
+		return delta(items), nil
+	case risppb.Encoding_GZIP:
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		if _, err := w.Write(packed(items)); err != nil {
+			return nil, errors.Wrap(err, "compress batch failed")
+		}
+		if err := w.Close(); err != nil {
+			return nil, errors.Wrap(err, "compress batch failed")
+		}
+		return buf.Bytes(), nil
+	case risppb.Encoding_ZSTD:
+		return zstdEncoder.EncodeAll(packed(items), nil), nil
+	}
+	return nil, errors.Errorf("cannot encode a batch as %s", encoding)
+}
+
+// Decode decodes a batch of the given number of items.
+func Decode(encoding risppb.Encoding, b []byte, count uint32) ([]uint32, error) {
+	switch encoding {
+	case risppb.Encoding_PACKED:
+		return unpack(b, count)
+	case risppb.Encoding_DELTA:
+		return undelta(b, count)
+	case risppb.Encoding_GZIP:
+		r, err := gzip.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return nil, errors.Wrapf(ErrCorrupt, "decompress: %s", err)
+		}
+		// the packed items take at most the largest varint each, which bounds what a hostile batch can inflate to
+		unpacked, err := io.ReadAll(io.LimitReader(r, int64(count)*binary.MaxVarintLen32+1))
+		if err != nil {
+			return nil, errors.Wrapf(ErrCorrupt, "decompress: %s", err)
+		}
+		return unpack(unpacked, count)
+	case risppb.Encoding_ZSTD:
+		unpacked, err := zstdDecoder.DecodeAll(b, nil)
+		if err != nil {
+			return nil, errors.Wrapf(ErrCorrupt, "decompress: %s", err)
+		}
+		return unpack(unpacked, count)
+	}
+	return nil, errors.Errorf("cannot decode a batch as %s", encoding)
+}
+
+// packed encodes each item as a varint.
+func packed(items []uint32) []byte {
+	b := make([]byte, len(items)*binary.MaxVarintLen32)
+	n := 0
+	for _, item := range items {
+		n += binary.PutUvarint(b[n:], uint64(item))
+	}
+	return b[:n]
+}
+
+// unpack decodes the given number of varints, which must be all of b.
+func unpack(b []byte, count uint32) ([]uint32, error) {
+	return decode(b, count, func(v uint64, _ uint32) (uint32, bool) {
+		return uint32(v), v <= math.MaxUint32
+	})
+}
+
+// delta encodes each item as the zigzag varint of its difference from the item before it, where the item
+// before the first is zero.
+func delta(items []uint32) []byte {
+	b := make([]byte, len(items)*binary.MaxVarintLen64)
+	n := 0
+	var prev uint32
+	for _, item := range items {
+		d := int64(item) - int64(prev)
+		n += binary.PutUvarint(b[n:], uint64((d<<1)^(d>>63)))
+		prev = item
+	}
+	return b[:n]
+}
+
+// undelta decodes the given number of zigzag varint differences, which must be all of b.
+func undelta(b []byte, count uint32) ([]uint32, error) {
+	return decode(b, count, func(v uint64, prev uint32) (uint32, bool) {
+		item := int64(prev) + (int64(v>>1) ^ -int64(v&1))
+		return uint32(item), item >= 0 && item <= math.MaxUint32
+	})
+}
+
+// decode decodes the given number of varints, which must be all of b, into items using the given function,
+// which is passed each varint and the item before it, and reports whether the item is valid.
+func decode(b []byte, count uint32, item func(v uint64, prev uint32) (uint32, bool)) ([]uint32, error) {
+	// every varint takes at least a byte, so a batch claiming more items than bytes is corrupt
+	if uint64(count) > uint64(len(b)) {
+		return nil, errors.Wrapf(ErrCorrupt, "%d items in %d bytes", count, len(b))
+	}
+	items := make([]uint32, count)
+	var prev uint32
+	for i := range items {
+		v, n := binary.Uvarint(b)
+		if n <= 0 {
+			return nil, errors.Wrapf(ErrCorrupt, "item %d: invalid varint", i)
+		}
+		b = b[n:]
+		var ok bool
+		if items[i], ok = item(v, prev); !ok {
+			return nil, errors.Wrapf(ErrCorrupt, "item %d: out of range", i)
+		}
+		prev = items[i]
+	}
+	if len(b) > 0 {
+		return nil, errors.Wrapf(ErrCorrupt, "%d trailing bytes", len(b))
+	}
+	return items, nil
+}
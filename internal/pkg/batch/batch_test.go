@@ -0,0 +1,126 @@
+package batch
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+
+	risppb "risp/api/proto/gen/pb-go/github.com/mschristensen/risp/api/build/go"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+// sequences are the shapes of sequence the encodings are tested and benchmarked with.
+var sequences = map[string]func(n int) []uint32{
+	"random": func(n int) []uint32 {
+		items := make([]uint32, n)
+		for i := range items {
+			items[i] = rand.Uint32() // nolint: gosec // we don't need high security here
+		}
+		return items
+	},
+	"monotonic": func(n int) []uint32 {
+		items := make([]uint32, n)
+		v := uint32(1_600_000_000)
+		for i := range items {
+			v += uint32(rand.Intn(100)) // nolint: gosec // we don't need high security here
+			items[i] = v
+		}
+		return items
+	},
+	"repeated": func(n int) []uint32 {
+		items := make([]uint32, n)
+		for i := range items {
+			items[i] = uint32(i%8) * 1000
+		}
+		return items
+	},
+}
+
+func TestEncode(t *testing.T) {
+	t.Parallel()
+	edges := []uint32{0, math.MaxUint32, 0, 1, math.MaxUint32 - 1, math.MaxUint32}
+	for _, encoding := range Supported {
+		for name, sequence := range sequences {
+			items := sequence(256)
+			b, err := Encode(encoding, items)
+			require.NoError(t, err)
+			decoded, err := Decode(encoding, b, uint32(len(items)))
+			require.NoError(t, err, "%s %s", encoding, name)
+			require.Equal(t, items, decoded, "%s %s", encoding, name)
+		}
+		b, err := Encode(encoding, edges)
+		require.NoError(t, err)
+		decoded, err := Decode(encoding, b, uint32(len(edges)))
+		require.NoError(t, err)
+		require.Equal(t, edges, decoded, encoding.String())
+
+		// a batch with fewer or more items than it claims is corrupt
+		_, err = Decode(encoding, b, uint32(len(edges)+1))
+		require.True(t, errors.Is(err, ErrCorrupt), encoding.String())
+		_, err = Decode(encoding, b, uint32(len(edges)-1))
+		require.True(t, errors.Is(err, ErrCorrupt), encoding.String())
+	}
+
+	_, err := Decode(risppb.Encoding_DELTA, []byte{0x80}, 1)
+	require.True(t, errors.Is(err, ErrCorrupt))
+	_, err = Decode(risppb.Encoding_GZIP, []byte{1, 2, 3}, 1)
+	require.True(t, errors.Is(err, ErrCorrupt))
+	_, err = Decode(risppb.Encoding_ZSTD, []byte{1, 2, 3}, 1)
+	require.True(t, errors.Is(err, ErrCorrupt))
+	// a batch inflating beyond the largest batch of packed items is corrupt, however few items it claims
+	_, err = Decode(risppb.Encoding_ZSTD, zstdEncoder.EncodeAll(make([]byte, 2*maxPacked), nil), 1)
+	require.True(t, errors.Is(err, ErrCorrupt))
+	// a difference taking the item below zero is corrupt
+	_, err = Decode(risppb.Encoding_DELTA, []byte{1}, 1)
+	require.True(t, errors.Is(err, ErrCorrupt))
+	_, err = Encode(risppb.Encoding_NONE, edges)
+	require.Error(t, err)
+}
+
+func TestNegotiate(t *testing.T) {
+	t.Parallel()
+	require.Equal(t, risppb.Encoding_NONE, Negotiate(nil))
+	require.Equal(t, risppb.Encoding_NONE, Negotiate([]risppb.Encoding{risppb.Encoding_NONE, 42}))
+	require.Equal(t, risppb.Encoding_GZIP, Negotiate([]risppb.Encoding{42, risppb.Encoding_GZIP, risppb.Encoding_DELTA}))
+}
+
+// BenchmarkEncode reports the bytes on the wire per item of a window of the largest size sent in a batch with each
+// encoding, against the bytes per item of the messages that carry the same items one by one.
+func BenchmarkEncode(b *testing.B) {
+	const window = 256
+	for _, name := range []string{"random", "monotonic", "repeated"} {
+		items := sequences[name](window)
+		b.Run(fmt.Sprintf("%s/%s", name, risppb.Encoding_NONE), func(b *testing.B) {
+			var size int
+			for i := 0; i < b.N; i++ {
+				size = 0
+				for j, item := range items {
+					size += proto.Size(&risppb.ServerMessage{State: risppb.ConnectionState_CONNECTED, Index: uint32(1000 + j), Payload: item})
+				}
+			}
+			b.ReportMetric(float64(size)/window, "wire-bytes/item")
+		})
+		for _, encoding := range Supported {
+			encoding := encoding
+			b.Run(fmt.Sprintf("%s/%s", name, encoding), func(b *testing.B) {
+				var size int
+				for i := 0; i < b.N; i++ {
+					encoded, err := Encode(encoding, items)
+					if err != nil {
+						b.Fatal(err)
+					}
+					msg := &risppb.ServerMessage{State: risppb.ConnectionState_CONNECTED, Index: 1000, Items: encoded, Count: window}
+					size = proto.Size(msg)
+					if _, err := Decode(encoding, msg.Items, msg.Count); err != nil {
+						b.Fatal(err)
+					}
+				}
+				b.ReportMetric(float64(size)/window, "wire-bytes/item")
+			})
+		}
+	}
+}
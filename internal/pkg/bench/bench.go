@@ -0,0 +1,132 @@
+// Package bench records the results of the transfers of a load test, and reports their throughput and latency.
+package bench
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Result is the outcome of a single transfer.
+type Result struct {
+	Len      uint16        // the length of the sequence transferred
+	Duration time.Duration // the time from the start of the transfer until it completed or failed
+	Failure  string        // the type of the error the transfer failed with, or empty if it completed
+}
+
+// Recorder collects the results of concurrent transfers.
+type Recorder struct {
+	mu      sync.Mutex
+	results []Result
+}
+
+// NewRecorder creates a new Recorder.
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
+
+// Record records the result of a transfer.
+func (r *Recorder) Record(result Result) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.results = append(r.results, result)
+}
+
+// Report summarises the results of a load test.
+// Latencies are the time to complete the transfers that completed, in milliseconds.
+type Report struct {
+	Clients     int            `json:"clients"`
+	Elapsed     float64        `json:"elapsed_seconds"`
+	Completions int            `json:"completions"`
+	Failures    map[string]int `json:"failures"`
+	Items       uint64         `json:"items"`
+	ItemsPerSec float64        `json:"items_per_sec"`
+	P50         float64        `json:"p50_ms"`
+	P90         float64        `json:"p90_ms"`
+	P99         float64        `json:"p99_ms"`
+	Max         float64        `json:"max_ms"`
+}
+
+// Report summarises the results recorded by the given number of clients over the elapsed time.
+// Only the items of the transfers that completed count towards the throughput.
+func (r *Recorder) Report(clients int, elapsed time.Duration) Report {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	report := Report{
+		Clients:  clients,
+		Elapsed:  elapsed.Seconds(),
+		Failures: make(map[string]int),
+	}
+	var latencies []time.Duration
+	for _, result := range r.results {
+		if result.Failure != "" {
+			report.Failures[result.Failure]++
+			continue
+		}
+		report.Completions++
+		report.Items += uint64(result.Len)
+		latencies = append(latencies, result.Duration)
+	}
+	if elapsed > 0 {
+		report.ItemsPerSec = float64(report.Items) / elapsed.Seconds()
+	}
+	sort.Slice(latencies, func(i, j int) bool {
+		return latencies[i] < latencies[j]
+	})
+	report.P50 = percentile(latencies, 50)
+	report.P90 = percentile(latencies, 90)
+	report.P99 = percentile(latencies, 99)
+	report.Max = percentile(latencies, 100)
+	return report
+}
+
+// percentile returns the p-th percentile of the sorted latencies in milliseconds, using the nearest rank.
+func percentile(sorted []time.Duration, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return float64(sorted[rank-1]) / float64(time.Millisecond)
+}
+
+// WriteText writes the report as a human-readable table.
+func (r Report) WriteText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "clients\t%d\n", r.Clients)
+	fmt.Fprintf(tw, "elapsed\t%.2fs\n", r.Elapsed)
+	fmt.Fprintf(tw, "completions\t%d\n", r.Completions)
+	failures := make([]string, 0, len(r.Failures))
+	for failure := range r.Failures {
+		failures = append(failures, failure)
+	}
+	sort.Strings(failures)
+	total := 0
+	for _, failure := range failures {
+		total += r.Failures[failure]
+	}
+	fmt.Fprintf(tw, "failures\t%d\n", total)
+	for _, failure := range failures {
+		fmt.Fprintf(tw, "  %s\t%d\n", failure, r.Failures[failure])
+	}
+	fmt.Fprintf(tw, "items\t%d\n", r.Items)
+	fmt.Fprintf(tw, "items/sec\t%.1f\n", r.ItemsPerSec)
+	fmt.Fprintf(tw, "time to complete\tp50 %.1fms  p90 %.1fms  p99 %.1fms  max %.1fms\n", r.P50, r.P90, r.P99, r.Max)
+	return errors.Wrap(tw.Flush(), "write report failed")
+}
+
+// WriteJSON writes the report as JSON.
+func (r Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return errors.Wrap(enc.Encode(r), "write report failed")
+}
@@ -0,0 +1,51 @@
+package bench
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReport(t *testing.T) {
+	t.Parallel()
+	r := NewRecorder()
+	for i := 1; i <= 100; i++ {
+		r.Record(Result{Len: 10, Duration: time.Duration(i) * time.Millisecond})
+	}
+	r.Record(Result{Len: 10, Duration: time.Second, Failure: "max attempts reached"})
+	r.Record(Result{Len: 10, Duration: time.Second, Failure: "max attempts reached"})
+	r.Record(Result{Len: 10, Duration: time.Second, Failure: "checksum mismatch"})
+
+	report := r.Report(4, 2*time.Second)
+	require.Equal(t, 4, report.Clients)
+	require.Equal(t, 100, report.Completions)
+	require.Equal(t, map[string]int{"max attempts reached": 2, "checksum mismatch": 1}, report.Failures)
+	require.Equal(t, uint64(1000), report.Items)
+	require.Equal(t, 500.0, report.ItemsPerSec)
+	require.Equal(t, 50.0, report.P50)
+	require.Equal(t, 90.0, report.P90)
+	require.Equal(t, 99.0, report.P99)
+	require.Equal(t, 100.0, report.Max)
+
+	var buf bytes.Buffer
+	require.NoError(t, report.WriteJSON(&buf))
+	var decoded Report
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &decoded))
+	require.Equal(t, report, decoded)
+
+	buf.Reset()
+	require.NoError(t, report.WriteText(&buf))
+	require.Regexp(t, `failures +3\n  checksum mismatch +1\n  max attempts reached +2\n`, buf.String())
+	require.Contains(t, buf.String(), "p50 50.0ms")
+}
+
+func TestReportEmpty(t *testing.T) {
+	t.Parallel()
+	report := NewRecorder().Report(1, 0)
+	require.Zero(t, report.Completions)
+	require.Zero(t, report.ItemsPerSec)
+	require.Zero(t, report.Max)
+}
@@ -0,0 +1,270 @@
+// Package catalog implements a catalog of named sequences loaded from data files.
+//
+// The catalog is loaded from the files in a directory, which are read according to their extension:
+//	- .txt files contain one integer per line. Empty lines and lines starting with # are ignored.
+//	- .csv files contain one sequence per column. If the first row is not made of integers, it is a header
+//	  naming the columns, otherwise the columns are named by their position, starting from 0.
+//	- .bin files contain the items as raw little-endian uint32 values.
+//
+// A sequence is named after its file without the extension, and the sequences of a CSV file are named
+// <file>/<column>. Names must not start with a digit, so that they cannot be mistaken for a sequence length.
+package catalog
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/csv"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"risp/internal/pkg/session"
+	"risp/pkg/checksum"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+var logger logrus.FieldLogger = logrus.StandardLogger()
+
+// ErrInvalidFile indicates that a data file could not be loaded.
+var ErrInvalidFile = errors.New("invalid data file")
+
+// Entry describes a sequence in the catalog.
+type Entry struct {
+	Name     string
+	Len      uint16
+	Checksum uint64
+}
+
+// Catalog is a set of named sequences. It is immutable once loaded, and so safe for concurrent use.
+type Catalog struct {
+	sequences map[string]session.Sequence
+	entries   []Entry
+}
+
+// Load loads the catalog from the data files in the given directory.
+// Files with an unknown extension are ignored.
+func Load(dir string) (*Catalog, error) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, errors.Wrap(err, "read data directory failed")
+	}
+	c := &Catalog{
+		sequences: make(map[string]session.Sequence),
+	}
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+		path := filepath.Join(dir, file.Name())
+		if err := c.loadFile(path); err != nil {
+			return nil, errors.Wrapf(err, "load %s failed", path)
+		}
+	}
+	c.sort()
+	return c, nil
+}
+
+// New creates a catalog of the given named sequences, which are subject to the same rules as those loaded from files.
+func New(sequences map[string][]uint32) (*Catalog, error) {
+	c := &Catalog{
+		sequences: make(map[string]session.Sequence),
+	}
+	for name, items := range sequences {
+		if err := c.add(name, items); err != nil {
+			return nil, err
+		}
+	}
+	c.sort()
+	return c, nil
+}
+
+// sort sorts the entries of the catalog by name.
+func (c *Catalog) sort() {
+	sort.Slice(c.entries, func(i, j int) bool {
+		return c.entries[i].Name < c.entries[j].Name
+	})
+}
+
+// loadFile adds the sequences in the data file to the catalog.
+func (c *Catalog) loadFile(path string) error {
+	ext := filepath.Ext(path)
+	name := strings.TrimSuffix(filepath.Base(path), ext)
+	var load func(io.Reader) (map[string][]uint32, error)
+	switch ext {
+	case ".txt":
+		load = readText
+	case ".csv":
+		load = readCSV
+	case ".bin":
+		load = readRaw
+	default:
+		logger.WithField("path", path).Warning("ignoring data file with unknown extension")
+		return nil
+	}
+	f, err := os.Open(path) // nolint: gosec // the data directory is trusted configuration
+	if err != nil {
+		return errors.Wrap(err, "open failed")
+	}
+	defer f.Close() // nolint: errcheck // the file is only read
+	columns, err := load(bufio.NewReader(f))
+	if err != nil {
+		return err
+	}
+	for column, items := range columns {
+		if column != "" {
+			if err := c.add(name+"/"+column, items); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := c.add(name, items); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// add adds the named sequence to the catalog.
+func (c *Catalog) add(name string, items []uint32) error {
+	if name == "" || unicode.IsDigit(rune(name[0])) {
+		return errors.Wrapf(ErrInvalidFile, "sequence name %q must not start with a digit", name)
+	}
+	if _, ok := c.sequences[name]; ok {
+		return errors.Wrapf(ErrInvalidFile, "duplicate sequence name %q", name)
+	}
+	if len(items) == 0 || len(items) > math.MaxUint16 {
+		return errors.Wrapf(ErrInvalidFile, "sequence %q has %d items, must have between 1 and %d", name, len(items), math.MaxUint16)
+	}
+	sequence := session.Uint32SliceToSequence(items)
+	sum, err := checksum.Sum(sequence...)
+	if err != nil {
+		return errors.Wrap(err, "checksum failed")
+	}
+	c.sequences[name] = sequence
+	c.entries = append(c.entries, Entry{Name: name, Len: uint16(len(items)), Checksum: sum})
+	return nil
+}
+
+// readText reads a sequence with one integer per line.
+func readText(r io.Reader) (map[string][]uint32, error) {
+	var items []uint32
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		x, err := strconv.ParseUint(text, 10, 32)
+		if err != nil {
+			return nil, errors.Wrapf(ErrInvalidFile, "line %d: %s", line, err)
+		}
+		items = append(items, uint32(x))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrap(err, "read failed")
+	}
+	return map[string][]uint32{"": items}, nil
+}
+
+// readCSV reads a sequence from each column.
+func readCSV(r io.Reader) (map[string][]uint32, error) {
+	cr := csv.NewReader(r)
+	var header []string
+	columns := make(map[string][]uint32)
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrapf(ErrInvalidFile, "%s", err)
+		}
+		if header == nil {
+			var named bool
+			if header, named, err = csvHeader(record); err != nil {
+				return nil, err
+			}
+			if named {
+				continue
+			}
+		}
+		for i, field := range record {
+			x, err := strconv.ParseUint(strings.TrimSpace(field), 10, 32)
+			if err != nil {
+				line, _ := cr.FieldPos(i)
+				return nil, errors.Wrapf(ErrInvalidFile, "line %d, column %s: %s", line, header[i], err)
+			}
+			columns[header[i]] = append(columns[header[i]], uint32(x))
+		}
+	}
+	if header == nil {
+		return nil, errors.Wrap(ErrInvalidFile, "no rows")
+	}
+	return columns, nil
+}
+
+// csvHeader returns the names of the columns of a CSV file from its first record, and whether the record is a
+// header row. The columns are named by their index unless the record has a field that is not an integer, in which
+// case it names the columns, and every name must be unique and not blank.
+func csvHeader(record []string) ([]string, bool, error) {
+	header := make([]string, len(record))
+	named := false
+	for i, field := range record {
+		header[i] = strings.TrimSpace(field)
+		if _, err := strconv.ParseUint(header[i], 10, 32); err != nil {
+			named = true
+		}
+	}
+	if !named {
+		for i := range header {
+			header[i] = strconv.Itoa(i)
+		}
+		return header, false, nil
+	}
+	seen := make(map[string]bool, len(header))
+	for i, name := range header {
+		if name == "" {
+			return nil, false, errors.Wrapf(ErrInvalidFile, "column %d has no name", i)
+		}
+		if seen[name] {
+			return nil, false, errors.Wrapf(ErrInvalidFile, "duplicate column name %q", name)
+		}
+		seen[name] = true
+	}
+	return header, true, nil
+}
+
+// readRaw reads a sequence of raw little-endian uint32 values.
+func readRaw(r io.Reader) (map[string][]uint32, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "read failed")
+	}
+	if len(data)%4 != 0 {
+		return nil, errors.Wrapf(ErrInvalidFile, "size %d is not a multiple of 4 bytes", len(data))
+	}
+	items := make([]uint32, len(data)/4)
+	for i := range items {
+		items[i] = binary.LittleEndian.Uint32(data[4*i:])
+	}
+	return map[string][]uint32{"": items}, nil
+}
+
+// Get returns the named sequence, or false if the catalog has no such sequence.
+// The sequence is shared by the sessions of every client requesting it, and must not be modified.
+func (c *Catalog) Get(name string) (session.Sequence, bool) {
+	sequence, ok := c.sequences[name]
+	return sequence, ok
+}
+
+// List returns the entries of the catalog, sorted by name.
+func (c *Catalog) List() []Entry {
+	return c.entries
+}
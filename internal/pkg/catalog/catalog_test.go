@@ -0,0 +1,96 @@
+package catalog
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"risp/pkg/checksum"
+
+	"github.com/stretchr/testify/require"
+)
+
+// write writes the data files to a new directory and returns its path.
+func write(t *testing.T, files map[string][]byte) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, data := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), data, 0o600))
+	}
+	return dir
+}
+
+func TestLoad(t *testing.T) {
+	t.Parallel()
+	raw := make([]byte, 8)
+	binary.LittleEndian.PutUint32(raw, 7)
+	binary.LittleEndian.PutUint32(raw[4:], 4294967295)
+	dir := write(t, map[string][]byte{
+		"primes.txt":  []byte("# the first primes\n2\n3\n\n5\n7\n"),
+		"prices.csv":  []byte("open,close\n1,2\n3,4\n"),
+		"columns.csv": []byte("1,2\n3,4\n5,6\n"),
+		"raw.bin":     raw,
+		"README.md":   []byte("ignored"),
+	})
+
+	c, err := Load(dir)
+	require.NoError(t, err)
+	expected := map[string][]uint32{
+		"columns/0":    {1, 3, 5},
+		"columns/1":    {2, 4, 6},
+		"prices/close": {2, 4},
+		"prices/open":  {1, 3},
+		"primes":       {2, 3, 5, 7},
+		"raw":          {7, 4294967295},
+	}
+	entries := c.List()
+	require.Len(t, entries, len(expected))
+	for i, entry := range entries {
+		if i > 0 {
+			require.Less(t, entries[i-1].Name, entry.Name)
+		}
+		items, ok := expected[entry.Name]
+		require.True(t, ok, entry.Name)
+		sequence, ok := c.Get(entry.Name)
+		require.True(t, ok)
+		require.Equal(t, items, sequence.ToUint32Slice())
+		require.Equal(t, uint16(len(items)), entry.Len)
+		sum, err := checksum.Sum(sequence...)
+		require.NoError(t, err)
+		require.Equal(t, sum, entry.Checksum)
+	}
+	_, ok := c.Get("missing")
+	require.False(t, ok)
+}
+
+func TestLoadInvalid(t *testing.T) {
+	t.Parallel()
+	tests := map[string]map[string][]byte{
+		"not an integer":   {"a.txt": []byte("1\nx\n")},
+		"out of range":     {"a.txt": []byte("4294967296\n")},
+		"empty":            {"a.txt": []byte("# nothing\n")},
+		"name is a length": {"1.txt": []byte("1\n")},
+		"duplicate name":   {"a.txt": []byte("1\n"), "a.bin": make([]byte, 4)},
+		"partial item":     {"a.bin": make([]byte, 6)},
+		"ragged csv":       {"a.csv": []byte("1,2\n3\n")},
+		"duplicate column": {"a.csv": []byte("open,open\n1,2\n")},
+		"blank column":     {"a.csv": []byte("open,\n1,2\n")},
+	}
+	for name, files := range tests {
+		files := files
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			_, err := Load(write(t, files))
+			require.ErrorIs(t, err, ErrInvalidFile)
+		})
+	}
+}
+
+func TestLoadInvalidLine(t *testing.T) {
+	t.Parallel()
+	// the line is counted from the start of the file, including the header row
+	_, err := Load(write(t, map[string][]byte{"a.csv": []byte("open,close\n1,2\n3,x\n")}))
+	require.ErrorIs(t, err, ErrInvalidFile)
+	require.Contains(t, err.Error(), "line 3, column close")
+}
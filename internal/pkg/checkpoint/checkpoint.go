@@ -0,0 +1,206 @@
+// Package checkpoint implements crash-safe persistence of the client transfer state,
+// so that a transfer can resume where it stopped after the client process restarts.
+//
+// A checkpoint is an append-only text file. The first line records the client UUID and
+// the sequence length, and each subsequent line records either a received item or an ack:
+//
+//	risp-checkpoint 1 <uuid> <len>
+//	i <index> <value>
+//	a <ack>
+//
+// Each record is written to the file as soon as it is known, so that it survives the process
+// dying, and the file is synced to disk on every ack. A partially written trailing record is
+// discarded when the checkpoint is opened.
+package checkpoint
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"risp/internal/pkg/session"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+const header = "risp-checkpoint"
+
+const version = 1
+
+// ErrCorrupt indicates that the checkpoint file could not be parsed.
+var ErrCorrupt = errors.New("corrupt checkpoint")
+
+// State is the transfer state recorded in a checkpoint.
+type State struct {
+	UUID     uuid.UUID
+	Sequence session.Sequence
+	Ack      uint16
+}
+
+// File is an open checkpoint file.
+// It is safe for concurrent use, so that the ranges of a transfer fetched in parallel can share it.
+type File struct {
+	path string
+	f    *os.File
+	mu   sync.Mutex
+}
+
+// Open opens the checkpoint file at the given path, creating it if it does not exist.
+// If the file already records a transfer, its state is returned, otherwise the returned
+// state is nil and the caller must record the transfer using Begin.
+func Open(path string) (*File, *State, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "open checkpoint file failed")
+	}
+	state, offset, err := read(f)
+	if err != nil {
+		_ = f.Close()
+		return nil, nil, errors.Wrapf(err, "read checkpoint %s failed", path)
+	}
+	// discard any partially written trailing record, and append after the last complete one
+	if err := f.Truncate(offset); err != nil {
+		_ = f.Close()
+		return nil, nil, errors.Wrap(err, "truncate checkpoint file failed")
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		_ = f.Close()
+		return nil, nil, errors.Wrap(err, "seek checkpoint file failed")
+	}
+	return &File{path: path, f: f}, state, nil
+}
+
+// read parses the checkpoint records, returning the recorded state and the offset
+// immediately after the last complete record.
+func read(r io.Reader) (*State, int64, error) {
+	var state *State
+	var offset int64
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			// a record without a trailing newline was not completely written
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, 0, errors.Wrap(err, "read record failed")
+		}
+		fields := strings.Fields(line)
+		if state == nil {
+			state, err = parseHeader(fields)
+			if err != nil {
+				return nil, 0, err
+			}
+		} else if err := state.apply(fields); err != nil {
+			return nil, 0, err
+		}
+		offset += int64(len(line))
+	}
+	if state != nil {
+		state.Ack = firstMissing(state.Sequence)
+	}
+	return state, offset, nil
+}
+
+// parseHeader parses the header record.
+func parseHeader(fields []string) (*State, error) {
+	if len(fields) != 4 || fields[0] != header || fields[1] != strconv.Itoa(version) {
+		return nil, errors.Wrap(ErrCorrupt, "invalid header")
+	}
+	id, err := uuid.Parse(fields[2])
+	if err != nil {
+		return nil, errors.Wrap(ErrCorrupt, "invalid uuid")
+	}
+	length, err := strconv.ParseUint(fields[3], 10, 16)
+	if err != nil {
+		return nil, errors.Wrap(ErrCorrupt, "invalid sequence length")
+	}
+	return &State{
+		UUID:     id,
+		Sequence: make(session.Sequence, length),
+	}, nil
+}
+
+// apply applies an item or ack record to the state.
+func (s *State) apply(fields []string) error {
+	switch {
+	case len(fields) == 3 && fields[0] == "i":
+		index, err := strconv.ParseUint(fields[1], 10, 16)
+		if err != nil || int(index) >= len(s.Sequence) {
+			return errors.Wrap(ErrCorrupt, "invalid item index")
+		}
+		value, err := strconv.ParseUint(fields[2], 10, 32)
+		if err != nil {
+			return errors.Wrap(ErrCorrupt, "invalid item value")
+		}
+		x := uint32(value)
+		s.Sequence[index] = &x
+		return nil
+	case len(fields) == 2 && fields[0] == "a":
+		// the ack is derived from the received items, so the record only marks a sync point
+		if _, err := strconv.ParseUint(fields[1], 10, 16); err != nil {
+			return errors.Wrap(ErrCorrupt, "invalid ack")
+		}
+		return nil
+	}
+	return errors.Wrap(ErrCorrupt, "unknown record")
+}
+
+// firstMissing returns the index of the first missing item in the sequence.
+func firstMissing(seq session.Sequence) uint16 {
+	for i := range seq {
+		if seq[i] == nil {
+			return uint16(i)
+		}
+	}
+	return uint16(len(seq))
+}
+
+// write appends a record to the checkpoint.
+func (f *File) write(format string, args ...interface{}) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, err := fmt.Fprintf(f.f, format+"\n", args...); err != nil {
+		return errors.Wrap(err, "write checkpoint record failed")
+	}
+	return nil
+}
+
+// Begin records a new transfer for the given client UUID and sequence length.
+func (f *File) Begin(clientUUID uuid.UUID, sequenceLength uint16) error {
+	if err := f.write("%s %d %s %d", header, version, clientUUID, sequenceLength); err != nil {
+		return err
+	}
+	return errors.Wrap(f.f.Sync(), "sync checkpoint failed")
+}
+
+// Item records a received item.
+func (f *File) Item(index uint16, value uint32) error {
+	return f.write("i %d %d", index, value)
+}
+
+// Ack records the ack and syncs the checkpoint to disk.
+func (f *File) Ack(ack uint16) error {
+	if err := f.write("a %d", ack); err != nil {
+		return err
+	}
+	return errors.Wrap(f.f.Sync(), "sync checkpoint failed")
+}
+
+// Close closes the checkpoint file, leaving it in place for a later resume.
+func (f *File) Close() error {
+	return errors.Wrap(f.f.Close(), "close checkpoint file failed")
+}
+
+// Remove closes and deletes the checkpoint file once the transfer is complete.
+func (f *File) Remove() error {
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return errors.Wrap(os.Remove(f.path), "remove checkpoint file failed")
+}
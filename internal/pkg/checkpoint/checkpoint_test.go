@@ -0,0 +1,45 @@
+package checkpoint
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResume(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "state")
+	id := uuid.New()
+
+	f, state, err := Open(path)
+	require.NoError(t, err)
+	require.Nil(t, state)
+	require.NoError(t, f.Begin(id, 4))
+	require.NoError(t, f.Item(0, 10))
+	require.NoError(t, f.Item(2, 30))
+	require.NoError(t, f.Ack(1))
+	require.NoError(t, f.Close())
+
+	// simulate the process dying part way through writing a record
+	raw, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o600)
+	require.NoError(t, err)
+	_, err = raw.WriteString("i 1 2")
+	require.NoError(t, err)
+	require.NoError(t, raw.Close())
+
+	f, state, err = Open(path)
+	require.NoError(t, err)
+	require.Equal(t, id, state.UUID)
+	require.Equal(t, uint16(1), state.Ack)
+	require.Equal(t, []uint32{10, 0, 30, 0}, state.Sequence.ToUint32Slice())
+
+	require.NoError(t, f.Item(1, 20))
+	require.NoError(t, f.Close())
+	_, state, err = Open(path)
+	require.NoError(t, err)
+	require.Equal(t, uint16(3), state.Ack)
+	require.Equal(t, []uint32{10, 20, 30, 0}, state.Sequence.ToUint32Slice())
+}
@@ -0,0 +1,98 @@
+package client
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	risppb "risp/api/proto/gen/pb-go/github.com/mschristensen/risp/api/build/go"
+	"risp/api/proto/gen/pb-go/github.com/mschristensen/risp/api/build/go/mocks"
+	"risp/internal/pkg/batch"
+	"risp/internal/pkg/protocol"
+	"risp/internal/pkg/server"
+	"risp/internal/pkg/transcript"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatch(t *testing.T) {
+	t.Parallel()
+	for _, encoding := range batch.Supported {
+		encoding := encoding
+		t.Run(encoding.String(), func(t *testing.T) {
+			t.Parallel()
+			dir := t.TempDir()
+			serverRecorder, err := transcript.Create(filepath.Join(dir, "server"))
+			require.NoError(t, err)
+			clientRecorder, err := transcript.Create(filepath.Join(dir, "client"))
+			require.NoError(t, err)
+			addr := serve(t, server.WithRecorder(serverRecorder))
+
+			// the server picks the first encoding it supports
+			ctx := context.Background()
+			c, err := NewClient(WithServerAddrs(addr), WithSequenceLength(300), WithEncodings(encoding, risppb.Encoding_PACKED), WithRecorder(clientRecorder))
+			require.NoError(t, err)
+			require.NoError(t, c.Connect(ctx))
+			require.NoError(t, c.Run(ctx))
+			require.NoError(t, c.Finish())
+
+			require.NoError(t, clientRecorder.Close())
+			require.NoError(t, serverRecorder.Close())
+			clientEntries, err := transcript.Load(filepath.Join(dir, "client"))
+			require.NoError(t, err)
+			serverEntries, err := transcript.Load(filepath.Join(dir, "server"))
+			require.NoError(t, err)
+			var batches int
+			for _, entry := range clientEntries {
+				if msg := entry.GetServer(); msg != nil && msg.Count > 0 {
+					require.Equal(t, encoding, msg.Encoding)
+					batches++
+				}
+			}
+			// the windows double from 4 items up to the largest window
+			require.Equal(t, 7, batches)
+			require.NoError(t, Replay(clientEntries))
+			require.NoError(t, server.Replay(serverEntries))
+		})
+	}
+}
+
+func TestBatchRanges(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	c, err := NewClient(WithServerAddrs(serve(t)), WithSequenceLength(100), WithEncodings(risppb.Encoding_DELTA))
+	require.NoError(t, err)
+	ranges, err := c.Split(3)
+	require.NoError(t, err)
+	for _, r := range ranges {
+		require.NoError(t, r.Connect(ctx))
+		require.NoError(t, r.Run(ctx))
+		require.NoError(t, r.Finish())
+	}
+	require.NoError(t, c.Connect(ctx))
+	require.NoError(t, c.Run(ctx))
+	require.NoError(t, c.Finish())
+}
+
+func TestBatchUnaccepted(t *testing.T) {
+	t.Parallel()
+	_, err := NewClient(WithSequenceLength(4), WithEncodings(risppb.Encoding_NONE))
+	require.Error(t, err)
+
+	c, err := NewClient(WithSequenceLength(4), WithEncodings(risppb.Encoding_DELTA))
+	require.NoError(t, err)
+	mockChannel := &mocks.RISP_ConnectClient{}
+	c.channel = mockChannel
+	mockChannel.On("Send", mock.IsType(&risppb.ClientMessage{})).Return(nil)
+	mockChannel.On("Recv").Return(&risppb.ServerMessage{
+		State:    risppb.ConnectionState_CONNECTING,
+		Version:  protocol.Version,
+		Features: uint64(protocol.Supported),
+		Encoding: risppb.Encoding_GZIP,
+	}, nil).Once()
+	mockChannel.On("Recv").Return(nil, nil).Maybe()
+	err = c.Run(context.Background())
+	require.True(t, errors.Is(err, ErrProtocol), err)
+}
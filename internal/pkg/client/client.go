@@ -2,28 +2,46 @@ package client
 
 import (
 	"context"
+	"crypto/ed25519"
 	"fmt"
 	"math"
 	"math/rand"
+	"net"
+	"strings"
 	"time"
 
 	risppb "risp/api/proto/gen/pb-go/github.com/mschristensen/risp/api/build/go"
 	"risp/internal"
+	"risp/internal/pkg/batch"
+	"risp/internal/pkg/checkpoint"
 	"risp/internal/pkg/log"
+	"risp/internal/pkg/protocol"
 	"risp/internal/pkg/session"
+	"risp/internal/pkg/signature"
+	"risp/internal/pkg/tracing"
+	"risp/internal/pkg/transcript"
+	"risp/internal/pkg/ws"
 	"risp/pkg/checksum"
 
 	"github.com/google/uuid"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/resolver/manual"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 )
 
 var logger logrus.FieldLogger = logrus.StandardLogger()
 
+var tracer = otel.Tracer("risp/internal/pkg/client")
+
 // DefaultWindowSize is the default initial window size for the client.
 const DefaultWindowSize = 1 << 2
 
@@ -32,27 +50,77 @@ const MaxWindowSize = 1 << 8
 
 // Client implements the client behaviour of RISP.
 type Client struct {
-	serverAddr string
-	uuid       uuid.UUID
-	session    session.Session
+	serverAddrs  []string
+	failover     bool // whether to fail over to the next server on reconnection
+	uuid         uuid.UUID
+	session      session.Session
+	randomLength bool
+	name         string // the name of the sequence, if it is requested by name
+
+	stateFile  string
+	checkpoint *checkpoint.File
+
+	// the range of the sequence fetched by the client, if rangeEnd is nonzero
+	rangeStart uint16
+	rangeEnd   uint16
+
+	live *live // the state of the live stream, if the client receives one
 
 	started        bool
+	negotiated     bool              // whether the server has confirmed the handshake
+	features       protocol.Features // features negotiated with the server
+	encodings      []risppb.Encoding // the encodings of batches of items the client accepts, in order of preference
+	encoding       risppb.Encoding   // the encoding of batches of items negotiated with the server
 	closing        bool
 	done           bool
 	lastWindowSize uint16
+	window         trace.Span // spans the round-trip of the window requested from the server, until it is used up
 	checksum       *uint64
+	signature      []byte            // the server's signature of the checksum, if any
+	verifyKey      ed25519.PublicKey // the key the signature of the checksum is verified with, if it must be signed
+
+	deliveries   chan Event
+	deliverySize int    // the number of items that may be received but not yet delivered
+	delivered    uint16 // the number of items delivered on the deliveries channel
+	flushed      bool   // whether the deliveries channel is being flushed, after which nothing more is delivered
 
-	conn    *grpc.ClientConn
-	channel risppb.RISP_ConnectClient
+	conn       *grpc.ClientConn
+	websocket  bool             // whether the client connects over a WebSocket instead of a gRPC stream
+	ws         *ws.ClientStream // the stream over a WebSocket, if the client connects over one
+	mux        *Mux             // the stream shared with other clients, if the client is multiplexed
+	transferID uint32           // identifies the client transfer on the shared stream
+	channel    risppb.RISP_ConnectClient
+
+	recorder *transcript.Recorder // records the messages of the client, if it is recorded
+	stream   uint64               // identifies the current stream in the transcript
 }
 
 // Cfg configures a Client.
 type Cfg func(*Client) error
 
-// WithServerPort sets the server port to connect to.
+// WithServerPort sets the port of the server on localhost to connect to.
 func WithServerPort(p uint16) Cfg {
 	return func(c *Client) error {
-		c.serverAddr = fmt.Sprintf("localhost:%d", p)
+		c.serverAddrs = []string{fmt.Sprintf("localhost:%d", p)}
+		return nil
+	}
+}
+
+// WithServerAddrs sets the addresses (host:port) of the servers to connect to.
+// A single address is resolved using DNS, and the client connects to the first healthy
+// resolved address. If multiple addresses are given, the client fails over between them
+// in order when the connection to a server fails.
+func WithServerAddrs(addrs ...string) Cfg {
+	return func(c *Client) error {
+		if len(addrs) == 0 {
+			return errors.New("no server addresses")
+		}
+		for _, addr := range addrs {
+			if _, _, err := net.SplitHostPort(addr); err != nil {
+				return errors.Wrapf(err, "invalid server address %s", addr)
+			}
+		}
+		c.serverAddrs = addrs
 		return nil
 	}
 }
@@ -61,6 +129,7 @@ func WithServerPort(p uint16) Cfg {
 func WithSequenceLength(l uint16) Cfg {
 	return func(c *Client) error {
 		c.session.Sequence = make([]*uint32, l)
+		c.randomLength = false
 		return nil
 	}
 }
@@ -69,6 +138,89 @@ func WithSequenceLength(l uint16) Cfg {
 func WithRandomSequenceLength() Cfg {
 	return func(c *Client) error {
 		c.session.Sequence = make([]*uint32, rand.Intn(math.MaxUint16)+1) // nolint: gosec // we don't need high security here
+		c.randomLength = true
+		return nil
+	}
+}
+
+// WithSequenceName requests the named sequence from the server's catalog.
+// Unless the length is also set using WithSequenceLength, the client learns it from the server in the handshake.
+func WithSequenceName(name string) Cfg {
+	return func(c *Client) error {
+		if name == "" {
+			return errors.New("empty sequence name")
+		}
+		c.name = name
+		c.session.Sequence = nil
+		c.randomLength = false
+		return nil
+	}
+}
+
+// WithStateFile sets the path of the file used to checkpoint the client state.
+// If the file records an unfinished transfer, the client resumes it using the same UUID
+// and the items already received.
+func WithStateFile(path string) Cfg {
+	return func(c *Client) error {
+		c.stateFile = path
+		return nil
+	}
+}
+
+// WithDelivery enables in-order delivery of the sequence on the channel returned by Deliveries.
+// At most size items may be received but not yet consumed before the client stops growing the
+// window, so that a slow consumer applies backpressure to the server.
+func WithDelivery(size int) Cfg {
+	return func(c *Client) error {
+		if size < 1 {
+			return errors.New("delivery buffer size must be positive")
+		}
+		// the channel is unbuffered, so that every item not yet consumed counts towards the size
+		c.deliveries = make(chan Event)
+		c.deliverySize = size
+		return nil
+	}
+}
+
+// WithEncodings asks the server to send the items of each window in a batch, using the first of the given encodings
+// that it supports. If it supports none of them, the server sends each item in its own message, as it does by default.
+func WithEncodings(encodings ...risppb.Encoding) Cfg {
+	return func(c *Client) error {
+		for _, encoding := range encodings {
+			if !batch.IsSupported(encoding) {
+				return errors.Errorf("unsupported encoding %s", encoding)
+			}
+		}
+		c.encodings = encodings
+		return nil
+	}
+}
+
+// WithVerifyKey requires the checksum sent by the server to be signed with the private key matching the given
+// public key, so that a proxy between the client and the server cannot rewrite both the items and the checksum.
+func WithVerifyKey(key ed25519.PublicKey) Cfg {
+	return func(c *Client) error {
+		if len(key) != ed25519.PublicKeySize {
+			return errors.New("invalid Ed25519 public key")
+		}
+		c.verifyKey = key
+		return nil
+	}
+}
+
+// WithWebSocket makes the client connect to the servers over a WebSocket instead of a gRPC stream, for networks
+// whose proxies block the HTTP/2 streams of gRPC. The protocol is the same over either transport.
+func WithWebSocket() Cfg {
+	return func(c *Client) error {
+		c.websocket = true
+		return nil
+	}
+}
+
+// WithRecorder records every message the client sends and receives to the transcript of the recorder.
+func WithRecorder(r *transcript.Recorder) Cfg {
+	return func(c *Client) error {
+		c.recorder = r
 		return nil
 	}
 }
@@ -82,11 +234,121 @@ func NewClient(cfgs ...Cfg) (*Client, error) {
 		}
 	}
 	client.uuid = uuid.New()
-	client.session.Window = DefaultWindowSize
-	client.lastWindowSize = DefaultWindowSize
+	if client.live != nil && client.stateFile != "" {
+		return nil, errors.New("checkpointing is not supported for live streams")
+	}
+	if client.stateFile != "" {
+		if err := client.restore(); err != nil {
+			return nil, errors.Wrap(err, "restore client state failed")
+		}
+	}
+	client.Reset()
 	return client, nil
 }
 
+// restore opens the checkpoint file, resuming the transfer it records if there is one
+// or else recording the new transfer.
+func (c *Client) restore() error {
+	var state *checkpoint.State
+	var err error
+	c.checkpoint, state, err = checkpoint.Open(c.stateFile)
+	if err != nil {
+		return errors.Wrap(err, "open checkpoint failed")
+	}
+	if state == nil {
+		// the transfer of a named sequence of unknown length is recorded once the server tells its length
+		if c.lengthUnknown() {
+			return nil
+		}
+		return errors.Wrap(c.checkpoint.Begin(c.uuid, uint16(len(c.session.Sequence))), "begin checkpoint failed")
+	}
+	if len(state.Sequence) != len(c.session.Sequence) && !c.randomLength && !c.lengthUnknown() {
+		_ = c.checkpoint.Close()
+		return ErrCheckpointMismatch
+	}
+	c.uuid = state.UUID
+	c.session.Sequence = state.Sequence
+	c.session.Ack = state.Ack
+	logger.WithFields(logrus.Fields{
+		"uuid": c.uuid.String(),
+		"ack":  c.session.Ack,
+		"len":  len(c.session.Sequence),
+	}).Info("resuming transfer from checkpoint")
+	return nil
+}
+
+// lengthUnknown reports whether the client requests a named sequence whose length it does not know yet.
+func (c *Client) lengthUnknown() bool {
+	return c.name != "" && c.session.Sequence == nil
+}
+
+// Split divides the items of the sequence that are still missing into at most n ranges of similar size,
+// and returns a client to fetch each range over its own stream. The clients share the UUID, the server
+// addresses and the sequence of the client they were split from, and store the items they receive
+// directly in its sequence. Once every range has been run to completion, the client they were split
+// from completes the transfer, running the closing handshake to verify the whole sequence.
+func (c *Client) Split(n int) ([]*Client, error) {
+	if n < 1 {
+		return nil, errors.New("number of ranges must be positive")
+	}
+	if c.rangeEnd != 0 {
+		return nil, errors.New("cannot split a ranged client")
+	}
+	if c.live != nil {
+		return nil, errors.New("cannot split a live stream")
+	}
+	if c.lengthUnknown() {
+		return nil, errors.New("cannot split a sequence of unknown length")
+	}
+	length := len(c.session.Sequence)
+	start := int(c.ackFrom(c.session.Ack))
+	size := (length - start + n - 1) / n
+	var ranges []*Client
+	for ; start < length; start += size {
+		end := start + size
+		if end > length {
+			end = length
+		}
+		r := &Client{
+			serverAddrs: append([]string{}, c.serverAddrs...),
+			uuid:        c.uuid,
+			name:        c.name,
+			checkpoint:  c.checkpoint,
+			recorder:    c.recorder,
+			encodings:   c.encodings,
+			websocket:   c.websocket,
+			rangeStart:  uint16(start),
+			rangeEnd:    uint16(end),
+		}
+		r.session.Sequence = c.session.Sequence
+		r.session.Ack = r.ackFrom(r.rangeStart)
+		if r.session.Ack == r.rangeEnd {
+			continue // the range was received before a restart
+		}
+		r.Reset()
+		ranges = append(ranges, r)
+	}
+	return ranges, nil
+}
+
+// end returns the index after the last item to fetch from the server.
+func (c *Client) end() uint16 {
+	if c.rangeEnd != 0 {
+		return c.rangeEnd
+	}
+	return uint16(len(c.session.Sequence))
+}
+
+// ackFrom returns the index of the first missing item to fetch, searching from the given index.
+func (c *Client) ackFrom(i uint16) uint16 {
+	for ; i < c.end(); i++ {
+		if c.session.Sequence[i] == nil {
+			return i
+		}
+	}
+	return c.end()
+}
+
 // min returns the minimum of two values.
 func min(a, b uint16) uint16 {
 	if a < b {
@@ -95,30 +357,107 @@ func min(a, b uint16) uint16 {
 	return b
 }
 
+// Deliveries returns the channel on which the sequence is delivered in order, if configured with WithDelivery.
+// The last event on the channel carries the Verification, after which the channel is closed. It is closed by
+// Finish, or by Run if the transfer cannot go on, in which case the Verification carries the error.
+// The consumer must read the channel until it is closed.
+func (c *Client) Deliveries() <-chan Event {
+	return c.deliveries
+}
+
+// nextWindow returns the size of the next window to request from the server.
+func (c *Client) nextWindow() uint16 {
+	window := c.limitWindow(min(2*c.lastWindowSize, MaxWindowSize))
+	if window > 0 {
+		c.lastWindowSize = window
+	}
+	return window
+}
+
+// limitWindow limits the window size to the space left for undelivered items if delivery is enabled,
+// so that the server does not send more items than the consumer is ready for.
+func (c *Client) limitWindow(window uint16) uint16 {
+	if !c.delivering() {
+		return window
+	}
+	pending := c.pending()
+	if pending >= c.deliverySize {
+		return 0
+	}
+	return min(window, uint16(c.deliverySize-pending))
+}
+
+// delivering reports whether delivery is enabled, and the deliveries channel is not flushed yet.
+func (c *Client) delivering() bool {
+	return c.deliveries != nil && !c.flushed
+}
+
+// pending returns the number of items received in order but not yet delivered.
+func (c *Client) pending() int {
+	if c.live != nil {
+		return len(c.live.undelivered)
+	}
+	return int(c.session.Ack - c.delivered)
+}
+
+// nextEvent returns the next item to deliver, if delivery is enabled and there is one.
+func (c *Client) nextEvent() (Event, bool) {
+	if !c.delivering() || c.pending() == 0 {
+		return Event{}, false
+	}
+	if c.live != nil {
+		return Event{Index: c.live.ack - uint32(len(c.live.undelivered)), Value: c.live.undelivered[0]}, true
+	}
+	return Event{Index: uint32(c.delivered), Value: *c.session.Sequence[c.delivered]}, true
+}
+
+// popEvent records that the next item was delivered.
+func (c *Client) popEvent() {
+	if c.live != nil {
+		c.live.undelivered = c.live.undelivered[1:]
+		return
+	}
+	c.delivered++
+}
+
+// complete reports whether the client has received all the items it needs before closing the stream.
+func (c *Client) complete() bool {
+	if c.live != nil {
+		return c.live.complete()
+	}
+	return c.session.Ack == c.end()
+}
+
 // sendRecv sends messages to the server that are received on the inbound channel,
 // and receives messages from the server and sends them on the returned on the outbound channel.
-func (c *Client) sendRecv(in chan *risppb.ClientMessage) (chan *risppb.ServerMessage, <-chan error) {
+// Receiving stops once the stop channel is closed.
+func (c *Client) sendRecv(in chan *risppb.ClientMessage, stop <-chan struct{}) (chan *risppb.ServerMessage, <-chan error) {
 	out := make(chan *risppb.ServerMessage)
-	kill := make(chan error)
+	kill := make(chan error, 2)
 	go func() {
 		defer close(out)
 		for {
 			msg, err := c.channel.Recv()
 			if err != nil {
-				if c.done {
-					return
-				}
 				kill <- errors.Wrap(err, "recv failed")
 				return
 			}
-			out <- msg
+			select {
+			case <-stop:
+				return
+			case out <- msg:
+			}
 		}
 	}()
 	go func() {
+		var failed bool
 		for msg := range in {
+			if failed {
+				continue // discard messages sent after a failure, until the client stops
+			}
 			if err := c.channel.Send(msg); err != nil {
 				kill <- errors.Wrap(err, "send failed")
-				return
+				failed = true
 			}
 		}
 	}()
@@ -127,40 +466,69 @@ func (c *Client) sendRecv(in chan *risppb.ClientMessage) (chan *risppb.ServerMes
 
 // handleMessage updates the client state using the message from the server.
 func (c *Client) handleMessage(_ context.Context, msg *risppb.ServerMessage) error {
+	if msg.State == risppb.ConnectionState_CONNECTING {
+		if err := protocol.CheckVersion(msg.Version); err != nil {
+			return errors.Wrap(ErrProtocol, err.Error())
+		}
+		c.features = protocol.Features(msg.Features) & protocol.Supported
+		if c.live != nil && !c.features.Has(protocol.Live) {
+			return errors.Wrap(ErrProtocol, "server does not support live streams")
+		}
+		if err := c.negotiateEncoding(msg.Encoding); err != nil {
+			return err
+		}
+		if c.name != "" {
+			if err := c.learnLength(msg.Len); err != nil {
+				return err
+			}
+		}
+		c.negotiated = true
+		return nil
+	}
+	if !c.negotiated {
+		return errors.Wrap(ErrProtocol, "received message before handshake reply")
+	}
+	if c.live != nil {
+		return c.handleLiveMessage(msg)
+	}
 	if msg.State == risppb.ConnectionState_CLOSING {
+		if c.rangeEnd != 0 {
+			return errors.Wrap(ErrProtocol, "received closing message on a ranged transfer")
+		}
 		if c.session.Ack != uint16(len(c.session.Sequence)) {
-			return errors.New("received closing message before all items received")
+			return errors.Wrap(ErrProtocol, "received closing message before all items received")
 		}
 		c.closing = true
-		sum, err := checksum.Sum(c.session.Sequence...)
-		if err != nil {
-			return errors.Wrap(err, "calculate checksum failed")
-		}
+		sum := msg.Checksum
 		c.checksum = &sum
+		c.signature = msg.Signature
 		return nil
 	}
 	if msg.State == risppb.ConnectionState_CLOSED {
-		if c.session.Ack != uint16(len(c.session.Sequence)) {
-			return errors.New("received closed message before all items received")
+		if c.session.Ack != c.end() {
+			return errors.Wrap(ErrProtocol, "received closed message before all items received")
 		}
-		if c.checksum == nil {
-			return errors.New("received closed message before checksum received")
+		if c.checksum == nil && c.rangeEnd == 0 {
+			return errors.Wrap(ErrProtocol, "received closed message before checksum received")
 		}
 		c.done = true
 		return nil
 	}
 
+	if msg.Count > 0 {
+		return c.handleBatch(msg)
+	}
+	if msg.Index < uint32(c.rangeStart) || msg.Index >= uint32(c.end()) {
+		return errors.Wrapf(ErrProtocol, "received item %d outside the requested range", msg.Index)
+	}
+
 	// store the item at the correct place in the sequence, as described by the offset
-	c.session.Sequence[msg.Index] = &msg.Payload
+	if err := c.store(uint16(msg.Index), msg.Payload); err != nil {
+		return err
+	}
 
 	// update ack to reflect the index of the first missing value
-	c.session.Ack = uint16(len(c.session.Sequence))
-	for i := range c.session.Sequence {
-		if c.session.Sequence[i] == nil {
-			c.session.Ack = uint16(i)
-			break
-		}
-	}
+	c.session.Ack = c.ackFrom(c.rangeStart)
 
 	// reduce the window size
 	c.session.Window--
@@ -168,22 +536,114 @@ func (c *Client) handleMessage(_ context.Context, msg *risppb.ServerMessage) err
 	return nil
 }
 
+// negotiateEncoding checks the encoding chosen by the server is one of those the client accepts.
+func (c *Client) negotiateEncoding(encoding risppb.Encoding) error {
+	c.encoding = encoding
+	if encoding == risppb.Encoding_NONE {
+		return nil
+	}
+	for _, accepted := range c.encodings {
+		if encoding == accepted {
+			return nil
+		}
+	}
+	return errors.Wrapf(ErrProtocol, "server chose encoding %s which the client does not accept", encoding)
+}
+
+// handleBatch stores the batch of consecutive items in the message, which uses up as much of the window.
+func (c *Client) handleBatch(msg *risppb.ServerMessage) error {
+	if c.encoding == risppb.Encoding_NONE || msg.Encoding != c.encoding {
+		return errors.Wrapf(ErrProtocol, "received a batch encoded as %s, but negotiated %s", msg.Encoding, c.encoding)
+	}
+	if msg.Index < uint32(c.rangeStart) || uint64(msg.Index)+uint64(msg.Count) > uint64(c.end()) {
+		return errors.Wrapf(ErrProtocol, "received items %d-%d outside the requested range", msg.Index, uint64(msg.Index)+uint64(msg.Count)-1)
+	}
+	items, err := batch.Decode(msg.Encoding, msg.Items, msg.Count)
+	if err != nil {
+		return errors.Wrap(ErrProtocol, err.Error())
+	}
+	for i, item := range items {
+		if err := c.store(uint16(msg.Index)+uint16(i), item); err != nil {
+			return err
+		}
+	}
+	c.session.Ack = c.ackFrom(c.rangeStart)
+	c.session.Window -= min(c.session.Window, uint16(msg.Count))
+	return nil
+}
+
+// store stores the item at its index in the sequence, checkpointing it if it is new.
+func (c *Client) store(index uint16, item uint32) error {
+	if c.checkpoint != nil && c.session.Sequence[index] == nil {
+		if err := c.checkpoint.Item(index, item); err != nil {
+			return errors.Wrap(err, "checkpoint item failed")
+		}
+	}
+	c.session.Sequence[index] = &item
+	return nil
+}
+
+// learnLength allocates the sequence once the server tells the length of the named sequence,
+// and records the transfer in the checkpoint.
+func (c *Client) learnLength(length uint32) error {
+	if !c.lengthUnknown() {
+		if int(length) != len(c.session.Sequence) {
+			return errors.Wrapf(ErrProtocol, "server sent length %d for a sequence of length %d", length, len(c.session.Sequence))
+		}
+		return nil
+	}
+	if length == 0 || length > math.MaxUint16 {
+		return errors.Wrapf(ErrProtocol, "server sent invalid length %d", length)
+	}
+	c.session.Sequence = make(session.Sequence, length)
+	if c.checkpoint != nil {
+		if err := c.checkpoint.Begin(c.uuid, uint16(length)); err != nil {
+			return errors.Wrap(err, "begin checkpoint failed")
+		}
+	}
+	return nil
+}
+
 // nextMessage prepares the next message to send to the server based on the current client state.
 func (c *Client) nextMessage() *risppb.ClientMessage {
 	msg := &risppb.ClientMessage{
-		State: risppb.ConnectionState_CONNECTED,
-		Uuid:  c.uuid[:],
-		Len:   uint32(len(c.session.Sequence)),
+		State:      risppb.ConnectionState_CONNECTED,
+		Uuid:       c.uuid[:],
+		Len:        uint32(len(c.session.Sequence)),
+		TransferId: c.transferID,
 	}
 
 	msg.Window = uint32(c.session.Window)
 	msg.Ack = uint32(c.session.Ack)
 
 	if !c.started {
+		// the ranges split from the client may have received items since it last connected
+		c.session.Ack = c.ackFrom(c.session.Ack)
+		msg.Ack = uint32(c.session.Ack)
 		msg.State = risppb.ConnectionState_CONNECTING
+		msg.Version = protocol.Version
+		msg.Features = uint64(protocol.Supported)
+		msg.RangeStart = uint32(c.rangeStart)
+		msg.RangeEnd = uint32(c.rangeEnd)
+		msg.Name = c.name
+		if c.live != nil {
+			msg.Ack = c.live.ack
+			msg.Live = true
+		} else {
+			msg.Encodings = c.encodings
+		}
 		c.started = true
 		return msg
 	}
+	if c.live != nil {
+		msg.Ack = c.live.ack
+		return c.nextLiveMessage(msg)
+	}
+	// a ranged transfer skips the checksum, since the sequence is verified by the client it was split from
+	if c.rangeEnd != 0 && c.session.Ack == c.rangeEnd {
+		msg.State = risppb.ConnectionState_CLOSED
+		return msg
+	}
 	if c.closing && c.checksum != nil {
 		msg.State = risppb.ConnectionState_CLOSED
 		return msg
@@ -195,70 +655,231 @@ func (c *Client) nextMessage() *risppb.ClientMessage {
 	return msg
 }
 
+// dial creates a client connection to the servers at the given addresses.
+// A single address is resolved using DNS, whereas multiple addresses are tried in order.
+func dial(ctx context.Context, addrs []string) (*grpc.ClientConn, error) {
+	opts := []grpc.DialOption{
+		grpc.WithTransportCredentials(insecure.NewCredentials()), // TODO: use TLS
+		grpc.WithDefaultServiceConfig(`{"loadBalancingConfig":[{"pick_first":{}}]}`),
+	}
+	opts = append(opts, tracing.DialOptions()...)
+	target := "dns:///" + addrs[0]
+	if len(addrs) > 1 {
+		r := manual.NewBuilderWithScheme("risp")
+		resolved := make([]resolver.Address, len(addrs))
+		for i := range addrs {
+			resolved[i] = resolver.Address{Addr: addrs[i]}
+		}
+		r.InitialState(resolver.State{Addresses: resolved})
+		target = r.Scheme() + ":///servers"
+		opts = append(opts, grpc.WithResolvers(r))
+	}
+	conn, err := grpc.DialContext(ctx, target, opts...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "connect to %s failed", strings.Join(addrs, ","))
+	}
+	return conn, nil
+}
+
+// dialWebSocket opens a stream over a WebSocket to the first of the servers at the given addresses that accepts it.
+func dialWebSocket(ctx context.Context, addrs []string) (*ws.ClientStream, error) {
+	errs := make([]string, len(addrs))
+	for i, addr := range addrs {
+		s, err := ws.Dial(ctx, addr)
+		if err == nil {
+			return s, nil
+		}
+		errs[i] = err.Error()
+	}
+	return nil, errors.Errorf("open WebSocket failed: %s", strings.Join(errs, "; "))
+}
+
 // Connect establishes the connection to the server.
+// If the last connection failed, the server addresses are rotated so that the next server is tried first.
 func (c *Client) Connect(ctx context.Context) error {
 	if c.conn != nil {
 		if err := c.conn.Close(); err != nil && status.Code(err) != codes.Canceled {
 			return errors.Wrap(err, "close client connection failed")
 		}
 	}
+	if c.ws != nil {
+		_ = c.ws.Close()
+	}
+	if c.failover && len(c.serverAddrs) > 1 {
+		c.serverAddrs = append(c.serverAddrs[1:], c.serverAddrs[0])
+	}
+	c.failover = false
 	var err error
-	c.conn, err = grpc.DialContext(ctx,
-		c.serverAddr,
-		grpc.WithTransportCredentials(insecure.NewCredentials()), // TODO: use TLS
-	)
+	if c.websocket {
+		c.ws, err = dialWebSocket(ctx, c.serverAddrs)
+		if err != nil {
+			c.failover = true
+			return errors.Wrap(err, "dial failed")
+		}
+		logger.WithField("servers", c.serverAddrs).Info("client connecting over WebSocket...")
+		c.channel = c.ws
+		return nil
+	}
+	c.conn, err = dial(ctx, c.serverAddrs)
 	if err != nil {
-		return errors.Wrapf(err, "connect to %s failed", c.serverAddr)
+		c.failover = true
+		return errors.Wrap(err, "dial failed")
 	}
-	logger.Info("client connecting...")
+	logger.WithField("servers", c.serverAddrs).Info("client connecting...")
 	c.channel, err = risppb.NewRISPClient(c.conn).Connect(ctx)
 	if err != nil {
+		c.failover = true
 		return errors.Wrap(err, "call connect failed")
 	}
 	return nil
 }
 
+// ConnectVia attaches the client to a stream shared with other clients, instead of establishing its own connection.
+// The client keeps the same transfer ID each time it is attached to the same Mux.
+func (c *Client) ConnectVia(m *Mux) error {
+	if c.mux != m {
+		c.mux = m
+		c.transferID = m.nextTransferID()
+	}
+	var err error
+	c.channel, err = m.attach(c.transferID)
+	if err != nil {
+		return errors.Wrap(err, "attach to mux failed")
+	}
+	return nil
+}
+
+// Done reports whether the client has received the whole sequence and completed the closing handshake.
+func (c *Client) Done() bool {
+	return c.done
+}
+
 // Run runs client-side RISP protocol to receive the integer stream from the server.
-func (c *Client) Run(ctx context.Context) error {
+// If the transfer cannot go on, because Run fails with an error that is not retryable or the context is done
+// before the sequence is received, the deliveries channel is flushed and closed.
+func (c *Client) Run(ctx context.Context) (err error) {
+	defer func() {
+		if c.done || (ctx.Err() == nil && (err == nil || Retryable(err))) {
+			return
+		}
+		if err != nil {
+			c.flush(err)
+		} else {
+			c.flush(errors.Wrap(ErrNotDone, ctx.Err().Error()))
+		}
+	}()
 	defer c.Reset()
+	defer c.endWindow()
 	out := make(chan *risppb.ClientMessage)
 	defer close(out)
-	in, kill := c.sendRecv(out)
+	stop := make(chan struct{})
+	defer close(stop)
+	in, kill := c.sendRecv(out, stop)
 
 	// The client ticker is longer than the server ticker, so that we don't see duplicate messages.
 	// Increasing this value can simulate what happens when messages arrive late from the server,
 	// causing the client to retry messages.
-	ticker := time.NewTicker(time.Duration(internal.ClientTickerMS) * time.Millisecond)
+	ticker := time.NewTicker(internal.ClientTicker)
 	defer ticker.Stop()
 	killswitch := time.NewTicker(math.MaxInt64) // never ticks (for 290 years at least)
-	if internal.ClientKillswitchMS > 0 {
-		killswitch = time.NewTicker(time.Duration(internal.ClientKillswitchMS) * time.Millisecond)
+	if internal.ClientKillswitch > 0 {
+		killswitch = time.NewTicker(internal.ClientKillswitch)
 	}
 	defer killswitch.Stop()
+	heartbeat := time.NewTicker(internal.HeartbeatInterval)
+	defer heartbeat.Stop()
+	lastRecv := time.Now()
+	if c.recorder != nil {
+		c.stream = c.recorder.NextStream()
+	}
 	for {
+		// deliver the next contiguous item if the consumer is ready for it
+		var deliveries chan<- Event
+		next, ok := c.nextEvent()
+		if ok {
+			deliveries = c.deliveries
+		}
+		// wait for a request to stop the live stream, until the client is stopping
+		var stop <-chan struct{}
+		if c.live != nil && !c.live.stopping {
+			stop = c.live.stop
+		}
 		select {
 		case <-ctx.Done():
 			return nil
+		case deliveries <- next:
+			c.popEvent()
+		case <-stop:
+			c.live.stopping = true
 		case msg, ok := <-in:
 			if !ok || msg == nil {
 				return nil
 			}
-			logger.WithFields(log.ServerMessageToFields(msg)).Info("received message")
+			lastRecv = time.Now()
+			c.record(risppb.Direction_RECEIVED, msg)
+			if msg.Heartbeat {
+				logger.WithFields(log.ServerMessageToFields(msg)).Debug("received heartbeat")
+				continue
+			}
+			if log.Sampled(msg.State) {
+				logger.WithFields(log.ServerMessageToFields(msg)).Debug("received message")
+			}
 			if err := c.handleMessage(ctx, msg); err != nil {
+				c.recordError(risppb.Direction_SENT, err)
 				return errors.Wrap(err, "handle message failed")
 			}
+			if c.session.Window == 0 || c.complete() {
+				c.endWindow()
+			}
 			if c.done {
 				return nil
 			}
 		case <-ticker.C:
-			if !c.started || c.session.Window == 0 || c.session.Ack == uint16(len(c.session.Sequence)) {
+			if c.started && !c.negotiated {
+				continue // wait for the handshake reply
+			}
+			if !c.started || c.session.Window == 0 || c.complete() {
 				if c.session.Window == 0 {
-					c.session.Window = min(2*c.lastWindowSize, MaxWindowSize)
-					c.lastWindowSize = c.session.Window
+					c.session.Window = c.nextWindow()
+				}
+				if c.started && c.session.Window == 0 && !c.complete() {
+					// the consumer is too slow, so wait for it to catch up before requesting more items
+					continue
 				}
 				msg := c.nextMessage()
 				out <- msg
-				logger.WithFields(log.ClientMessageToFields(msg)).Info("sent message")
+				c.record(risppb.Direction_SENT, msg)
+				c.startWindow(ctx, msg)
+				if log.Sampled(msg.State) {
+					logger.WithFields(log.ClientMessageToFields(msg)).Debug("sent message")
+				}
+				if c.checkpoint != nil {
+					if err := c.checkpoint.Ack(c.session.Ack); err != nil {
+						return errors.Wrap(err, "checkpoint ack failed")
+					}
+				}
+			}
+		case <-heartbeat.C:
+			if !c.features.Has(protocol.Heartbeat) {
+				continue
+			}
+			if time.Since(lastRecv) > internal.IdleTimeout {
+				if err := c.channel.CloseSend(); err != nil {
+					logger.Warning(errors.Wrap(err, "failed to close send channel"))
+				}
+				c.failover = true
+				logger.Warning("server idle timeout, disconnecting")
+				return ErrClientDisconnected
+			}
+			if c.negotiated {
+				msg := &risppb.ClientMessage{
+					State:      risppb.ConnectionState_CONNECTED,
+					Uuid:       c.uuid[:],
+					Heartbeat:  true,
+					TransferId: c.transferID,
+				}
+				out <- msg
+				c.record(risppb.Direction_SENT, msg)
 			}
 		case <-killswitch.C:
 			if err := c.channel.CloseSend(); err != nil {
@@ -267,31 +888,158 @@ func (c *Client) Run(ctx context.Context) error {
 			logger.Warning("disconnecting by killswitch")
 			return ErrClientDisconnected
 		case err := <-kill:
+			c.recordError(risppb.Direction_RECEIVED, err)
+			if !Retryable(err) {
+				return errors.Wrap(err, "send recv failed")
+			}
+			c.failover = true
 			logger.Warning(errors.Wrap(err, "send recv killed"))
 			return ErrClientDisconnected
 		}
 	}
 }
 
+// record records the message in the transcript, if the client is recorded.
+func (c *Client) record(direction risppb.Direction, msg proto.Message) {
+	if c.recorder != nil {
+		c.recorder.Record(c.stream, direction, msg)
+	}
+}
+
+// recordError records the error that ended the stream in the transcript, if the client is recorded.
+func (c *Client) recordError(direction risppb.Direction, err error) {
+	if c.recorder != nil {
+		c.recorder.RecordError(c.stream, direction, err)
+	}
+}
+
+// startWindow starts the span of the window requested by the message, unless a window is already in flight
+// or the client is only closing the transfer.
+func (c *Client) startWindow(ctx context.Context, msg *risppb.ClientMessage) {
+	if c.window != nil || msg.Window == 0 || c.complete() {
+		return
+	}
+	_, c.window = tracer.Start(ctx, "client.window", trace.WithAttributes(
+		attribute.Int64("ack", int64(msg.Ack)),
+		attribute.Int64("window", int64(msg.Window)),
+	))
+}
+
+// endWindow ends the span of the window in flight, if there is one, recording how many of its items were not received.
+func (c *Client) endWindow() {
+	if c.window == nil {
+		return
+	}
+	c.window.SetAttributes(attribute.Int64("missing", int64(c.session.Window)))
+	c.window.End()
+	c.window = nil
+}
+
 // Reset prepares the client for reconnection.
 func (c *Client) Reset() {
 	c.started = false
-	c.session.Window = DefaultWindowSize
+	c.negotiated = false
+	c.features = 0
+	c.encoding = risppb.Encoding_NONE
+	c.session.Window = c.limitWindow(DefaultWindowSize)
 	c.lastWindowSize = DefaultWindowSize
 }
 
 // Finish checks the client has correctly received the sequence from the server
-// and logs the result.
+// and logs the result. If delivery is enabled, any remaining items are delivered
+// followed by the verification event, and the deliveries channel is closed.
 func (c *Client) Finish() error {
-	if err := c.conn.Close(); err != nil {
-		return errors.Wrap(err, "close client connection failed")
+	if c.conn != nil {
+		if err := c.conn.Close(); err != nil {
+			return errors.Wrap(err, "close client connection failed")
+		}
+	}
+	if c.ws != nil {
+		_ = c.ws.Close()
+	}
+	if c.mux != nil {
+		if err := c.channel.CloseSend(); err != nil {
+			return errors.Wrap(err, "detach from mux failed")
+		}
+	}
+	// the sequence is verified by the client the range was split from
+	if c.rangeEnd != 0 {
+		if !c.done {
+			return ErrNotDone
+		}
+		return nil
+	}
+	err := c.verify()
+	c.flush(err)
+	if err != nil {
+		if c.checkpoint != nil {
+			_ = c.checkpoint.Close()
+		}
+		return err
+	}
+	if c.checkpoint != nil {
+		if err := c.checkpoint.Remove(); err != nil {
+			return errors.Wrap(err, "remove checkpoint failed")
+		}
+	}
+	fields := logrus.Fields{
+		"uuid":     c.uuid.String(),
+		"checksum": *c.checksum,
 	}
+	if c.live != nil {
+		fields["items"] = c.live.ack
+	} else {
+		fields["sequence"] = c.session.Sequence
+	}
+	logger.WithFields(fields).Info("client completed successfully")
+	return nil
+}
+
+// Sequence returns the received sequence once it has been verified against the server checksum.
+// A live stream has no sequence, so its items must be consumed using WithDelivery instead.
+func (c *Client) Sequence() ([]uint32, error) {
+	if c.live != nil {
+		return nil, errors.New("a live stream has no sequence")
+	}
+	if err := c.verify(); err != nil {
+		return nil, err
+	}
+	return c.session.Sequence.ToUint32Slice(), nil
+}
+
+// verify checks the received sequence against the checksum sent by the server, once the signature of the checksum
+// is verified if the client has a key to verify it with.
+func (c *Client) verify() error {
 	if !c.done {
 		return ErrNotDone
 	}
 	if c.checksum == nil {
 		return ErrMissingChecksum
 	}
+	if c.verifyKey != nil {
+		statement := signature.Statement{
+			UUID:     c.uuid,
+			Name:     c.name,
+			Len:      uint32(len(c.session.Sequence)),
+			Checksum: *c.checksum,
+		}
+		if c.live != nil {
+			statement.Live = true
+			statement.Len = *c.live.end
+		}
+		if len(c.signature) == 0 {
+			return errors.Wrap(ErrSignatureInvalid, "checksum not signed")
+		}
+		if !signature.Verify(c.verifyKey, statement, c.signature) {
+			return ErrSignatureInvalid
+		}
+	}
+	if c.live != nil {
+		if uint64(c.live.sum) != *c.checksum {
+			return ErrChecksumMismatch
+		}
+		return nil
+	}
 	sum, err := checksum.Sum(c.session.Sequence...)
 	if err != nil {
 		return errors.Wrap(err, "checksum failed")
@@ -299,10 +1047,31 @@ func (c *Client) Finish() error {
 	if sum != *c.checksum {
 		return ErrChecksumMismatch
 	}
-	logger.WithFields(logrus.Fields{
-		"uuid":     c.uuid.String(),
-		"sequence": c.session.Sequence,
-		"checksum": *c.checksum,
-	}).Info("client completed successfully")
 	return nil
 }
+
+// flush delivers the remaining items followed by the verification event, and closes the deliveries channel,
+// unless it is already flushed. The events are delivered in the background, so that the client is not held up by
+// the consumer.
+func (c *Client) flush(err error) {
+	if !c.delivering() {
+		return
+	}
+	var events []Event
+	for next, ok := c.nextEvent(); ok; next, ok = c.nextEvent() {
+		events = append(events, next)
+		c.popEvent()
+	}
+	verification := &Verification{Err: err}
+	if c.checksum != nil {
+		verification.Checksum = *c.checksum
+	}
+	events = append(events, Event{Verification: verification})
+	c.flushed = true
+	go func(deliveries chan<- Event) {
+		defer close(deliveries)
+		for _, event := range events {
+			deliveries <- event
+		}
+	}(c.deliveries)
+}
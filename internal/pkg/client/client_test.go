@@ -7,11 +7,15 @@ import (
 
 	risppb "risp/api/proto/gen/pb-go/github.com/mschristensen/risp/api/build/go"
 	"risp/api/proto/gen/pb-go/github.com/mschristensen/risp/api/build/go/mocks"
+	"risp/internal/pkg/protocol"
 	"risp/internal/pkg/session"
 	"risp/pkg/checksum"
 
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 var seqs []session.Sequence = []session.Sequence{
@@ -36,6 +40,11 @@ func TestRun(t *testing.T) {
 			c.channel = mockChannel
 			// TODO should assert expected sequence of messages from client
 			mockChannel.On("Send", mock.IsType(&risppb.ClientMessage{})).Return(nil)
+			mockChannel.On("Recv").Return(&risppb.ServerMessage{
+				State:    risppb.ConnectionState_CONNECTING,
+				Version:  protocol.Version,
+				Features: uint64(protocol.Supported),
+			}, nil).Once()
 			for idx, val := range seqs[j] {
 				mockChannel.On("Recv").Return(&risppb.ServerMessage{
 					State:   risppb.ConnectionState_CONNECTED,
@@ -58,3 +67,132 @@ func TestRun(t *testing.T) {
 		})
 	}
 }
+
+func TestDeliveries(t *testing.T) {
+	t.Parallel()
+	for i := range seqs {
+		j := i
+		t.Run(fmt.Sprintf("test_%d", j), func(t *testing.T) {
+			t.Parallel()
+			c, err := NewClient(
+				WithSequenceLength(uint16(len(seqs[j]))),
+				WithDelivery(2),
+			)
+			require.NoError(t, err)
+			mockChannel := &mocks.RISP_ConnectClient{}
+			c.channel = mockChannel
+			mockChannel.On("Send", mock.IsType(&risppb.ClientMessage{})).Return(nil)
+			mockChannel.On("Recv").Return(&risppb.ServerMessage{
+				State:    risppb.ConnectionState_CONNECTING,
+				Version:  protocol.Version,
+				Features: uint64(protocol.Supported),
+			}, nil).Once()
+			for idx, val := range seqs[j] {
+				mockChannel.On("Recv").Return(&risppb.ServerMessage{
+					State:   risppb.ConnectionState_CONNECTED,
+					Index:   uint32(idx),
+					Payload: *val,
+				}, nil).Once()
+			}
+			sum, err := checksum.Sum(seqs[j]...)
+			require.NoError(t, err)
+			mockChannel.On("Recv").Return(&risppb.ServerMessage{
+				State:    risppb.ConnectionState_CLOSING,
+				Checksum: sum,
+			}, nil).Once()
+			mockChannel.On("Recv").Return(&risppb.ServerMessage{
+				State: risppb.ConnectionState_CLOSED,
+			}, nil).Once()
+			mockChannel.On("Recv").Return(nil, nil).Maybe()
+
+			events := make(chan []Event)
+			go func() {
+				var received []Event
+				for event := range c.Deliveries() {
+					received = append(received, event)
+				}
+				events <- received
+			}()
+			require.NoError(t, c.Run(context.Background()))
+			require.NoError(t, c.Finish())
+
+			received := <-events
+			require.Len(t, received, len(seqs[j])+1)
+			for idx, val := range seqs[j] {
+				require.Equal(t, Event{Index: uint32(idx), Value: *val}, received[idx])
+			}
+			require.Equal(t, &Verification{Checksum: sum}, received[len(seqs[j])].Verification)
+		})
+	}
+}
+
+func TestDeliveryWindow(t *testing.T) {
+	t.Parallel()
+	c, err := NewClient(WithSequenceLength(10), WithDelivery(4))
+	require.NoError(t, err)
+	// no item is buffered in the channel, so that undelivered items are only counted once
+	require.Zero(t, cap(c.Deliveries()))
+	require.Equal(t, uint16(4), c.limitWindow(MaxWindowSize))
+	c.session.Ack = 3
+	require.Equal(t, uint16(1), c.limitWindow(MaxWindowSize))
+	c.session.Ack = 4
+	require.Zero(t, c.limitWindow(MaxWindowSize))
+	c.delivered = 2
+	require.Equal(t, uint16(2), c.limitWindow(MaxWindowSize))
+}
+
+func TestDeliveriesClosed(t *testing.T) {
+	t.Parallel()
+	handshake := &risppb.ServerMessage{
+		State:    risppb.ConnectionState_CONNECTING,
+		Version:  protocol.Version,
+		Features: uint64(protocol.Supported),
+	}
+
+	// Finish does not wait for a consumer that is not reading
+	sequence := seqs[3]
+	c, err := NewClient(WithSequenceLength(uint16(len(sequence))), WithDelivery(2))
+	require.NoError(t, err)
+	mockChannel := &mocks.RISP_ConnectClient{}
+	c.channel = mockChannel
+	mockChannel.On("Send", mock.IsType(&risppb.ClientMessage{})).Return(nil)
+	mockChannel.On("Recv").Return(handshake, nil).Once()
+	for idx, val := range sequence {
+		mockChannel.On("Recv").Return(&risppb.ServerMessage{
+			State:   risppb.ConnectionState_CONNECTED,
+			Index:   uint32(idx),
+			Payload: *val,
+		}, nil).Once()
+	}
+	sum, err := checksum.Sum(sequence...)
+	require.NoError(t, err)
+	mockChannel.On("Recv").Return(&risppb.ServerMessage{State: risppb.ConnectionState_CLOSING, Checksum: sum}, nil).Once()
+	mockChannel.On("Recv").Return(&risppb.ServerMessage{State: risppb.ConnectionState_CLOSED}, nil).Once()
+	mockChannel.On("Recv").Return(nil, nil).Maybe()
+	require.NoError(t, c.Run(context.Background()))
+	require.NoError(t, c.Finish())
+	var received []Event
+	for event := range c.Deliveries() {
+		received = append(received, event)
+	}
+	require.Len(t, received, len(sequence)+1)
+	require.Equal(t, &Verification{Checksum: sum}, received[len(sequence)].Verification)
+
+	// Run closes the deliveries once the transfer cannot go on, without Finish
+	c, err = NewClient(WithSequenceLength(10), WithDelivery(2))
+	require.NoError(t, err)
+	mockChannel = &mocks.RISP_ConnectClient{}
+	c.channel = mockChannel
+	mockChannel.On("Send", mock.IsType(&risppb.ClientMessage{})).Return(nil)
+	mockChannel.On("Recv").Return(nil, status.Error(codes.FailedPrecondition, "sequence length mismatch")).Once()
+	mockChannel.On("Recv").Return(nil, nil).Maybe()
+	err = c.Run(context.Background())
+	require.Error(t, err)
+	require.False(t, Retryable(err))
+	received = nil
+	for event := range c.Deliveries() {
+		received = append(received, event)
+	}
+	require.Len(t, received, 1)
+	require.Equal(t, codes.FailedPrecondition, status.Code(errors.Cause(received[0].Verification.Err)))
+}
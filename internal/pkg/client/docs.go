@@ -2,7 +2,8 @@
 //
 // The client performs the following steps:
 //	1. Connect to the server.
-//	2. Send the initial handshake message with state CONNECTING, specifying the client UUID and sequence length and a small window size.
+//	2. Send the initial handshake message with state CONNECTING, specifying the client UUID and sequence length, a small window size,
+//	   and the supported protocol version and features. The server replies with state CONNECTING and the negotiated features.
 // 	3. Receive the server response with the state CONNECTED, containing the first payload item.
 // 	4. The client repeatedly receives payloads and stores them at the correct place in the sequence.
 //  5. When the window size is exhausted, the client sends a CONNECTED message to the server acknowledging the payloads received in the window.
@@ -18,7 +19,41 @@
 // but is reset to a default small value on disconnection. This strategy therefore tries to
 // optimise the data exchange according to the connection stability.
 //
-// When the client disconnects, it returns ErrClientDisconnected. The reconnection must be performed by the caller.
+// A consumer can receive the sequence while it is being transferred by configuring the client
+// using WithDelivery. Items are delivered in order on the Deliveries channel as soon as they are
+// contiguous from the start of the sequence, and the window is limited to the space left in the
+// delivery buffer so that a slow consumer applies backpressure to the server. The final event
+// carries the result of the checksum verification, or the error that ended the transfer. Otherwise, the sequence is available from Sequence
+// once it is verified.
+//
+// The client can be configured with the addresses of several servers using WithServerAddrs.
+// When the connection to a server fails, the client fails over to the next server on reconnection.
+// Combined with a session store shared between the servers, this allows a transfer to survive
+// the failure of a server.
+//
+// A client configured using WithLive receives a live stream of unbounded length instead of a sequence.
+// It keeps a running checksum of the items received, and closes the stream after a limit or when
+// Stop is called, unless the server closes it first.
+//
+// A large sequence can be fetched in ranges over parallel streams by splitting the client with Split.
+// Each range is fetched by its own client, and the client it was split from verifies the sequence
+// once all the ranges are complete.
+//
+// A client configured using WithSequenceName requests a named sequence from the server's catalog instead of
+// a random sequence, and learns its length from the server's handshake. ListSequences lists the named sequences
+// served by a server.
+//
+// Several clients can share a single stream to the server using a Mux, which multiplexes their transfers
+// by transfer ID. Each client is attached to the mux with ConnectVia instead of Connect.
+//
+// When the client disconnects, it returns ErrClientDisconnected. The reconnection must be performed by the caller,
+// and Retryable reports whether an error returned by the client is worth reconnecting after.
+//
+// The round-trip of each window, from the request to the receipt of its last item, is traced as a span in the
+// context passed to Run, and the trace context is propagated to the server in the metadata of the stream.
+//
+// A client configured using WithRecorder records every message it sends and receives to a transcript, in the order
+// in which it handled them. Replay re-drives clients from such a transcript to reproduce their state transitions.
 //
 // Additional flags can be specified to control the client message sending interval and the killswitch interval (to trigger disconnections).
 //
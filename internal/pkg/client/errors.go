@@ -1,6 +1,12 @@
 package client
 
-import "github.com/pkg/errors"
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
 
 // ErrNotDone indicates that the client is not in the done state.
 var ErrNotDone = errors.New("not done")
@@ -11,5 +17,48 @@ var ErrMissingChecksum = errors.New("missing checksum")
 // ErrChecksumMismatch indicates that the checksum does not match the expected value.
 var ErrChecksumMismatch = errors.New("checksum mismatch")
 
+// ErrSignatureInvalid indicates that the checksum is not signed by the server the client trusts.
+var ErrSignatureInvalid = errors.New("invalid checksum signature")
+
 // ErrClientDisconnected indicates that the client disconnected from the server but should reconnect.
 var ErrClientDisconnected = errors.New("client disconnected")
+
+// ErrCheckpointMismatch indicates that the checkpoint records a transfer of a different sequence length.
+var ErrCheckpointMismatch = errors.New("checkpoint sequence length mismatch")
+
+// ErrProtocol indicates that the server violated the RISP protocol.
+var ErrProtocol = errors.New("protocol error")
+
+// Retryable reports whether the client should reconnect after the given error.
+// Disconnections and transient gRPC errors are retryable, whereas protocol errors,
+// checksum mismatches, invalid signatures and gRPC errors describing a request the
+// server will never accept are permanent.
+func Retryable(err error) bool {
+	switch {
+	case errors.Is(err, ErrClientDisconnected):
+		return true
+	case errors.Is(err, ErrProtocol),
+		errors.Is(err, ErrChecksumMismatch),
+		errors.Is(err, ErrSignatureInvalid),
+		errors.Is(err, ErrCheckpointMismatch),
+		errors.Is(err, context.Canceled):
+		return false
+	}
+	var grpcErr interface{ GRPCStatus() *status.Status }
+	if !errors.As(err, &grpcErr) {
+		return true
+	}
+	switch grpcErr.GRPCStatus().Code() {
+	case codes.InvalidArgument,
+		codes.NotFound,
+		codes.AlreadyExists,
+		codes.PermissionDenied,
+		codes.FailedPrecondition,
+		codes.OutOfRange,
+		codes.Unimplemented,
+		codes.DataLoss,
+		codes.Unauthenticated:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,18 @@
+package client
+
+// Event is delivered to the consumer of a Client configured using WithDelivery.
+// Items are delivered in order as soon as they are contiguous from the start of the sequence,
+// and the final event carries the Verification of the whole sequence.
+type Event struct {
+	Index uint32
+	Value uint32
+
+	// Verification is only set on the final event.
+	Verification *Verification
+}
+
+// Verification describes the outcome of checking the received sequence against the server checksum.
+type Verification struct {
+	Checksum uint64
+	Err      error
+}
@@ -0,0 +1,119 @@
+package client
+
+import (
+	"sync"
+
+	risppb "risp/api/proto/gen/pb-go/github.com/mschristensen/risp/api/build/go"
+	"risp/pkg/checksum"
+
+	"github.com/pkg/errors"
+)
+
+// live is the state of a live stream, whose items are produced by the server over time.
+type live struct {
+	limit       uint32            // the number of items after which the client closes the stream, or 0 for no limit
+	ack         uint32            // the index of the first item not yet received
+	ahead       map[uint32]uint32 // the items received after a missing item
+	sum         checksum.Running  // the running checksum of the items before ack
+	end         *uint32           // the index after the last item, once the stream is closing
+	stopping    bool              // whether the client has asked the server to close the stream
+	undelivered []uint32          // the items before ack not yet delivered to the consumer
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// WithLive makes the client receive a live stream, whose items are produced by the server over time and whose
+// length is unbounded. The client closes the stream once it has received limit items, or if limit is 0, when
+// Stop is called. The server may also close the stream, in which case the client receives the items up to the
+// end of the stream before closing it.
+func WithLive(limit uint32) Cfg {
+	return func(c *Client) error {
+		c.live = &live{
+			limit: limit,
+			ahead: make(map[uint32]uint32),
+			stop:  make(chan struct{}),
+		}
+		c.session.Sequence = nil
+		c.randomLength = false
+		return nil
+	}
+}
+
+// Stop asks the server to close the live stream after the items received so far.
+// It is safe to call from another goroutine.
+func (c *Client) Stop() {
+	if c.live == nil {
+		return
+	}
+	c.live.stopOnce.Do(func() {
+		close(c.live.stop)
+	})
+}
+
+// handleLiveMessage updates the state of the live stream using the message from the server.
+func (c *Client) handleLiveMessage(msg *risppb.ServerMessage) error {
+	l := c.live
+	switch msg.State {
+	case risppb.ConnectionState_CLOSING:
+		// the closing message tells the client where the stream ends, and the checksum of the items before it
+		if msg.Index < l.ack {
+			return errors.Wrap(ErrProtocol, "received closing message ending before items already received")
+		}
+		end, sum := msg.Index, msg.Checksum
+		l.end = &end
+		c.checksum = &sum
+		c.signature = msg.Signature
+		return nil
+	case risppb.ConnectionState_CLOSED:
+		if l.end == nil || l.ack != *l.end {
+			return errors.Wrap(ErrProtocol, "received closed message before all items received")
+		}
+		c.done = true
+		return nil
+	}
+
+	c.session.Window--
+	// ignore items already received, items after the end of the stream, and items so far ahead of
+	// the first missing item that the server cannot have sent them in the current window
+	if l.stopping || msg.Index < l.ack || (l.end != nil && msg.Index >= *l.end) || msg.Index-l.ack >= MaxWindowSize {
+		return nil
+	}
+	l.ahead[msg.Index] = msg.Payload
+	for {
+		value, ok := l.ahead[l.ack]
+		if !ok {
+			break
+		}
+		delete(l.ahead, l.ack)
+		l.sum.Add(value)
+		l.ack++
+		if c.delivering() {
+			l.undelivered = append(l.undelivered, value)
+		}
+		if l.limit > 0 && l.ack == l.limit {
+			l.stopping = true
+			break
+		}
+	}
+	return nil
+}
+
+// nextLiveMessage prepares the next message of the live stream.
+func (c *Client) nextLiveMessage(msg *risppb.ClientMessage) *risppb.ClientMessage {
+	l := c.live
+	// ask the server to close the stream until it confirms where the stream ends
+	if l.stopping && (l.end == nil || *l.end != l.ack) {
+		msg.State = risppb.ConnectionState_CLOSING
+		return msg
+	}
+	if l.end != nil && l.ack == *l.end && c.checksum != nil {
+		msg.State = risppb.ConnectionState_CLOSED
+	}
+	return msg
+}
+
+// complete reports whether the live stream has been received up to the point where it closes.
+func (l *live) complete() bool {
+	return l.stopping || (l.end != nil && l.ack == *l.end)
+}
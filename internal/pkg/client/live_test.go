@@ -0,0 +1,77 @@
+package client
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"risp/internal/pkg/server"
+	"risp/internal/pkg/session"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLive(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name        string
+		sourceLimit uint32
+		clientLimit uint32
+		expected    uint32
+	}{
+		{name: "server closes the stream", sourceLimit: 50, expected: 50},
+		{name: "client closes the stream", clientLimit: 30, expected: 30},
+		{name: "client closes the stream first", sourceLimit: 80, clientLimit: 40, expected: 40},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			ctx := context.Background()
+			addr := serve(t, server.WithLiveSource(func() session.Source {
+				return session.NewRandomSource(time.Millisecond, test.sourceLimit)
+			}))
+			c, err := NewClient(WithServerAddrs(addr), WithLive(test.clientLimit), WithDelivery(8))
+			require.NoError(t, err)
+			events := make(chan []Event)
+			go func() {
+				var received []Event
+				for event := range c.Deliveries() {
+					received = append(received, event)
+				}
+				events <- received
+			}()
+
+			require.NoError(t, c.Connect(ctx))
+			require.NoError(t, c.Run(ctx))
+			require.True(t, c.Done())
+			require.NoError(t, c.Finish())
+
+			received := <-events
+			require.Len(t, received, int(test.expected)+1)
+			var sum uint64
+			for i, event := range received[:test.expected] {
+				require.Equal(t, uint32(i), event.Index)
+				sum += uint64(event.Value)
+			}
+			require.Equal(t, &Verification{Checksum: sum}, received[test.expected].Verification)
+		})
+	}
+}
+
+func TestLiveStop(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	c, err := NewClient(WithServerAddrs(serve(t, server.WithLiveSource(func() session.Source {
+		return session.NewRandomSource(time.Millisecond, 0)
+	}))), WithLive(0))
+	require.NoError(t, err)
+	require.NoError(t, c.Connect(ctx))
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		c.Stop()
+	}()
+	require.NoError(t, c.Run(ctx))
+	require.True(t, c.Done())
+	require.NoError(t, c.Finish())
+}
@@ -0,0 +1,273 @@
+package client
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	risppb "risp/api/proto/gen/pb-go/github.com/mschristensen/risp/api/build/go"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+)
+
+// ErrMuxClosed indicates that the multiplexed stream is closed.
+var ErrMuxClosed = errors.New("mux closed")
+
+// Mux multiplexes the transfers of many clients over a single Connect stream,
+// so that they share one connection to the server.
+//
+// Each client attached to the Mux using Client.ConnectVia is given its own transfer ID, which it sets on every
+// message it sends, and the messages received from the server are routed to the client by their transfer ID.
+// Each transfer performs its own handshake and has its own window and ack. If the stream fails, every attached
+// client disconnects, and the clients must be attached to a new Mux to reconnect.
+type Mux struct {
+	conn   io.Closer // the connection the stream is carried over
+	stream risppb.RISP_ConnectClient
+
+	sendMu sync.Mutex // serialises sends on the stream
+
+	mu        sync.Mutex
+	transfers map[uint32]*transfer
+	lastID    uint32
+
+	done chan struct{} // closed when the stream fails
+	err  error         // the reason the stream failed
+}
+
+// NewMux connects to the servers at the given addresses, and opens a stream to multiplex transfers over.
+func NewMux(ctx context.Context, addrs ...string) (*Mux, error) {
+	if len(addrs) == 0 {
+		return nil, errors.New("no server addresses")
+	}
+	conn, err := dial(ctx, addrs)
+	if err != nil {
+		return nil, errors.Wrap(err, "dial failed")
+	}
+	stream, err := risppb.NewRISPClient(conn).Connect(ctx)
+	if err != nil {
+		_ = conn.Close()
+		return nil, errors.Wrap(err, "call connect failed")
+	}
+	return newMux(conn, stream), nil
+}
+
+// NewWebSocketMux connects to the first of the servers at the given addresses that accepts a WebSocket, and opens
+// a stream over it to multiplex transfers over.
+func NewWebSocketMux(ctx context.Context, addrs ...string) (*Mux, error) {
+	if len(addrs) == 0 {
+		return nil, errors.New("no server addresses")
+	}
+	stream, err := dialWebSocket(ctx, addrs)
+	if err != nil {
+		return nil, errors.Wrap(err, "dial failed")
+	}
+	return newMux(stream, stream), nil
+}
+
+// newMux creates a Mux multiplexing transfers over the stream, which is carried over the given connection.
+func newMux(conn io.Closer, stream risppb.RISP_ConnectClient) *Mux {
+	m := &Mux{
+		conn:      conn,
+		stream:    stream,
+		transfers: make(map[uint32]*transfer),
+		done:      make(chan struct{}),
+	}
+	go m.demux()
+	return m
+}
+
+// nextTransferID returns a transfer ID that has not been used on the stream.
+// Transfer ID 0 is reserved for clients that are not multiplexed.
+func (m *Mux) nextTransferID() uint32 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lastID++
+	return m.lastID
+}
+
+// attach returns the stream for the given transfer.
+func (m *Mux) attach(transferID uint32) (risppb.RISP_ConnectClient, error) {
+	select {
+	case <-m.done:
+		return nil, m.err
+	default:
+	}
+	t := &transfer{
+		ClientStream: m.stream,
+		mux:          m,
+		id:           transferID,
+		ready:        make(chan struct{}, 1),
+		detached:     make(chan struct{}),
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if old, ok := m.transfers[transferID]; ok {
+		old.detach()
+	}
+	m.transfers[transferID] = t
+	return t, nil
+}
+
+// demux receives messages from the server and routes them to the transfers.
+// Heartbeats concern the whole stream, so they are sent to every transfer.
+//
+// A message is never dropped, nor does a transfer whose client is not keeping up hold up the others: the messages
+// are queued for each transfer, whose queue only grows with the windows its client asks for, since the server only
+// sends the items of those windows.
+func (m *Mux) demux() {
+	for {
+		msg, err := m.stream.Recv()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				err = ErrMuxClosed
+			}
+			m.err = err
+			close(m.done)
+			return
+		}
+		m.mu.Lock()
+		if msg.Heartbeat {
+			for _, t := range m.transfers {
+				// a transfer that already has messages waiting doesn't need the heartbeat
+				t.push(msg, false)
+			}
+			m.mu.Unlock()
+			continue
+		}
+		t, ok := m.transfers[msg.TransferId]
+		m.mu.Unlock()
+		if !ok {
+			continue // the transfer is no longer attached
+		}
+		t.push(msg, true)
+	}
+}
+
+// send sends a message on the stream.
+func (m *Mux) send(msg *risppb.ClientMessage) error {
+	m.sendMu.Lock()
+	defer m.sendMu.Unlock()
+	return m.stream.Send(msg)
+}
+
+// Err returns the reason the stream failed, or nil if it is still open.
+func (m *Mux) Err() error {
+	select {
+	case <-m.done:
+		return m.err
+	default:
+		return nil
+	}
+}
+
+// Close closes the stream and the connection to the server.
+func (m *Mux) Close() error {
+	m.sendMu.Lock()
+	err := m.stream.CloseSend()
+	m.sendMu.Unlock()
+	if err != nil {
+		return errors.Wrap(err, "close send failed")
+	}
+	return errors.Wrap(m.conn.Close(), "close connection failed")
+}
+
+// transfer is the stream of a single client transfer on a Mux.
+// It embeds the shared stream for the stream metadata, but messages are sent and received per transfer.
+type transfer struct {
+	grpc.ClientStream
+
+	mux      *Mux
+	id       uint32
+	detached chan struct{} // closed when the transfer is detached from the mux
+	once     sync.Once
+
+	mu    sync.Mutex
+	queue []*risppb.ServerMessage // the messages received for the transfer, in order
+	ready chan struct{}           // signalled when a message is queued
+}
+
+// push queues the message for the transfer, without waiting for its client to receive it.
+// Unless always is set, the message is only queued if no other message is waiting.
+func (t *transfer) push(msg *risppb.ServerMessage, always bool) {
+	t.mu.Lock()
+	if !always && len(t.queue) > 0 {
+		t.mu.Unlock()
+		return
+	}
+	t.queue = append(t.queue, msg)
+	t.mu.Unlock()
+	select {
+	case t.ready <- struct{}{}:
+	default: // the client is already signalled
+	}
+}
+
+// pop returns the next queued message, or false if none is waiting.
+func (t *transfer) pop() (*risppb.ServerMessage, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.queue) == 0 {
+		return nil, false
+	}
+	msg := t.queue[0]
+	t.queue[0] = nil
+	t.queue = t.queue[1:]
+	return msg, true
+}
+
+// Send sends a message for the transfer.
+func (t *transfer) Send(msg *risppb.ClientMessage) error {
+	select {
+	case <-t.detached:
+		return ErrMuxClosed
+	default:
+	}
+	if msg.TransferId != t.id {
+		return errors.Errorf("message for transfer %d sent on transfer %d", msg.TransferId, t.id)
+	}
+	return t.mux.send(msg)
+}
+
+// Recv receives the next message for the transfer.
+// The messages received before the stream failed are still received, but not those after the transfer detached.
+func (t *transfer) Recv() (*risppb.ServerMessage, error) {
+	for {
+		select {
+		case <-t.detached:
+			return nil, io.EOF
+		default:
+		}
+		if msg, ok := t.pop(); ok {
+			return msg, nil
+		}
+		select {
+		case <-t.ready:
+		case <-t.detached:
+			return nil, io.EOF
+		case <-t.mux.done:
+			if msg, ok := t.pop(); ok {
+				return msg, nil
+			}
+			return nil, t.mux.err
+		}
+	}
+}
+
+// CloseSend detaches the transfer from the mux, leaving the shared stream open.
+func (t *transfer) CloseSend() error {
+	t.mux.mu.Lock()
+	defer t.mux.mu.Unlock()
+	if t.mux.transfers[t.id] == t {
+		delete(t.mux.transfers, t.id)
+	}
+	t.detach()
+	return nil
+}
+
+// detach marks the transfer as detached.
+func (t *transfer) detach() {
+	t.once.Do(func() {
+		close(t.detached)
+	})
+}
@@ -0,0 +1,113 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"testing"
+	"time"
+
+	risppb "risp/api/proto/gen/pb-go/github.com/mschristensen/risp/api/build/go"
+	"risp/internal"
+	"risp/internal/pkg/protocol"
+	"risp/internal/pkg/server"
+	"risp/internal/pkg/session"
+	"risp/internal/pkg/tracing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+func init() {
+	// speed up the exchange of messages with a real server
+	internal.ServerTicker = time.Millisecond
+	internal.ClientTicker = 5 * time.Millisecond
+}
+
+// serve starts a RISP server with the given configuration on a random local port and returns its address.
+func serve(t *testing.T, cfgs ...server.Cfg) string {
+	t.Helper()
+	srv, err := server.NewServer(append([]server.Cfg{server.WithSessionStore(session.NewMemoryStore())}, cfgs...)...)
+	require.NoError(t, err)
+	return listen(t, srv)
+}
+
+// listen serves the RISP server on a random local port and returns its address.
+func listen(t *testing.T, srv *server.Server) string {
+	t.Helper()
+	grpcServer := grpc.NewServer(tracing.ServerOptions()...)
+	risppb.RegisterRISPServer(grpcServer, srv)
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() {
+		_ = grpcServer.Serve(lis)
+	}()
+	t.Cleanup(grpcServer.Stop)
+	return lis.Addr().String()
+}
+
+func TestMux(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	mux, err := NewMux(ctx, serve(t))
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, mux.Close())
+	}()
+
+	clients := make([]*Client, 5)
+	errs := make(chan error, len(clients))
+	for i := range clients {
+		clients[i], err = NewClient(WithSequenceLength(uint16(10 * (i + 1))))
+		require.NoError(t, err)
+		require.NoError(t, clients[i].ConnectVia(mux))
+		go func(c *Client) {
+			errs <- c.Run(ctx)
+		}(clients[i])
+	}
+	for range clients {
+		require.NoError(t, <-errs)
+	}
+	for i, c := range clients {
+		require.True(t, c.Done(), fmt.Sprintf("client %d not done", i))
+		require.NoError(t, c.Finish())
+	}
+	require.NoError(t, mux.Err())
+}
+
+func TestMuxStalledTransfer(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	mux, err := NewMux(ctx, serve(t))
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, mux.Close())
+	}()
+
+	// a transfer whose client never receives the items it asked for
+	stalled, err := mux.attach(mux.nextTransferID())
+	require.NoError(t, err)
+	clientUUID := uuid.New()
+	require.NoError(t, stalled.Send(&risppb.ClientMessage{
+		State:      risppb.ConnectionState_CONNECTING,
+		Uuid:       clientUUID[:],
+		Len:        math.MaxUint16,
+		Window:     math.MaxUint16,
+		Version:    protocol.Version,
+		Features:   uint64(protocol.Multiplex),
+		TransferId: 1,
+	}))
+
+	// long enough for the server to send the stalled transfer hundreds of items
+	time.Sleep(500 * time.Millisecond)
+
+	// does not hold up the other transfers on the stream
+	c, err := NewClient(WithSequenceLength(10))
+	require.NoError(t, err)
+	require.NoError(t, c.ConnectVia(mux))
+	require.NoError(t, c.Run(ctx))
+	require.True(t, c.Done())
+	require.NoError(t, c.Finish())
+}
@@ -0,0 +1,178 @@
+package client
+
+import (
+	"context"
+	"fmt"
+
+	risppb "risp/api/proto/gen/pb-go/github.com/mschristensen/risp/api/build/go"
+	"risp/internal/pkg/session"
+	"risp/internal/pkg/transcript"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/proto"
+)
+
+// replayKey identifies a replayed client, which is the client of a UUID fetching a range of the sequence,
+// or the whole sequence if the range is empty.
+type replayKey struct {
+	uuid       uuid.UUID
+	rangeStart uint32
+	rangeEnd   uint32
+}
+
+// replay re-drives clients with the messages of a transcript.
+type replay struct {
+	clients map[replayKey]*Client
+	streams map[uint64]*Client
+	failed  map[uint64]error // the error with which the replayed client of each stream rejected a message
+}
+
+// Replay re-drives clients with the messages they received in a transcript recorded by clients, in the order in
+// which they were handled, and checks that every message each replayed client sends is the message it sent when
+// the transcript was recorded, and that it rejects exactly the messages it rejected then.
+// It returns a *transcript.Divergence if the replay departs from the transcript.
+//
+// The clients are created from the handshakes in the transcript. The items a client received before the transcript
+// was recorded, e.g. when it resumed from a checkpoint, are unknown, and replayed as zero. Live streams cannot be
+// replayed, since their length is only known to the application.
+func Replay(entries []*risppb.TranscriptEntry) error {
+	r := &replay{
+		clients: make(map[replayKey]*Client),
+		streams: make(map[uint64]*Client),
+		failed:  make(map[uint64]error),
+	}
+	for i, entry := range entries {
+		if err := r.step(i, entry); err != nil {
+			return err
+		}
+	}
+	// a client that rejected a message must have recorded its error
+	for _, err := range r.failed {
+		return &transcript.Divergence{Entry: len(entries), Want: "the end of the transcript", Got: fmt.Sprintf("error %q", err)}
+	}
+	return nil
+}
+
+// step replays the ith entry of the transcript.
+func (r *replay) step(i int, entry *risppb.TranscriptEntry) error {
+	if err, ok := r.failed[entry.Stream]; ok {
+		// the recorded client rejected the same message if the next entry on the stream is its error
+		if entry.GetError() == "" || entry.Direction != risppb.Direction_SENT {
+			return &transcript.Divergence{Entry: i, Want: transcript.Describe(entry), Got: fmt.Sprintf("error %q", err)}
+		}
+		delete(r.failed, entry.Stream)
+		delete(r.streams, entry.Stream)
+		return nil
+	}
+	switch event := entry.Event.(type) {
+	case *risppb.TranscriptEntry_Client:
+		if event.Client.Heartbeat {
+			return nil
+		}
+		return r.send(i, entry.Stream, event.Client)
+	case *risppb.TranscriptEntry_Server:
+		if event.Server.Heartbeat {
+			return nil
+		}
+		c, ok := r.streams[entry.Stream]
+		if !ok {
+			return errors.Errorf("entry %d: stream %d has no handshake", i, entry.Stream)
+		}
+		if err := c.handleMessage(context.Background(), event.Server); err != nil {
+			r.failed[entry.Stream] = err
+		}
+		return nil
+	case *risppb.TranscriptEntry_Error:
+		if entry.Direction == risppb.Direction_SENT {
+			return &transcript.Divergence{Entry: i, Want: transcript.Describe(entry), Got: "no error"}
+		}
+		// the stream was ended by the server or the network
+		delete(r.streams, entry.Stream)
+		return nil
+	}
+	return errors.Errorf("entry %d: no event", i)
+}
+
+// send replays the client sending a message on a tick, and checks that it is the recorded message.
+func (r *replay) send(i int, stream uint64, want *risppb.ClientMessage) error {
+	c, ok := r.streams[stream]
+	if want.State == risppb.ConnectionState_CONNECTING {
+		var err error
+		if c, err = r.connect(want); err != nil {
+			return errors.Wrapf(err, "entry %d", i)
+		}
+		r.streams[stream] = c
+	} else if !ok {
+		return errors.Errorf("entry %d: stream %d has no handshake", i, stream)
+	}
+	diverged := func(got string) error {
+		return &transcript.Divergence{Entry: i, Want: fmt.Sprintf("sent %s", want), Got: got}
+	}
+	// the client sends on a tick only if it is not waiting for the handshake reply, and needs a new window or is complete
+	if c.started && !c.negotiated {
+		return diverged("no message, waiting for the handshake reply")
+	}
+	if c.started && c.session.Window != 0 && !c.complete() {
+		return diverged(fmt.Sprintf("no message, %d items left in the window", c.session.Window))
+	}
+	if c.session.Window == 0 {
+		c.session.Window = c.nextWindow()
+	}
+	got := c.nextMessage()
+	if !proto.Equal(got, want) {
+		return diverged(fmt.Sprintf("sent %s", got))
+	}
+	return nil
+}
+
+// connect returns the client that sent the handshake, which is created the first time it connects, and prepares it
+// for the new stream.
+func (r *replay) connect(msg *risppb.ClientMessage) (*Client, error) {
+	if msg.Live {
+		return nil, errors.New("replaying a live stream is not supported")
+	}
+	id, err := uuid.FromBytes(msg.Uuid)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse client UUID failed")
+	}
+	key := replayKey{uuid: id, rangeStart: msg.RangeStart, rangeEnd: msg.RangeEnd}
+	c, ok := r.clients[key]
+	if !ok {
+		c = &Client{
+			uuid:       id,
+			name:       msg.Name,
+			encodings:  msg.Encodings,
+			rangeStart: uint16(msg.RangeStart),
+			rangeEnd:   uint16(msg.RangeEnd),
+		}
+		c.session.Sequence = r.sequence(id, msg)
+		// the items received before the transcript was recorded are unknown
+		if c.session.Sequence != nil {
+			for i := msg.RangeStart; i < msg.Ack && int(i) < len(c.session.Sequence); i++ {
+				if c.session.Sequence[i] == nil {
+					c.session.Sequence[i] = new(uint32)
+				}
+			}
+			c.session.Ack = c.ackFrom(c.rangeStart)
+		}
+		r.clients[key] = c
+	}
+	c.transferID = msg.TransferId
+	c.Reset()
+	return c, nil
+}
+
+// sequence returns the sequence shared by the clients of the UUID, or a new sequence of the length requested
+// by the handshake if it is the first of them, which is nil if the client learns the length from the server.
+func (r *replay) sequence(id uuid.UUID, msg *risppb.ClientMessage) session.Sequence {
+	for key, c := range r.clients {
+		if key.uuid == id && c.session.Sequence != nil {
+			return c.session.Sequence
+		}
+	}
+	if msg.Len == 0 {
+		return nil
+	}
+	return make(session.Sequence, msg.Len)
+}
@@ -0,0 +1,91 @@
+package client
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	risppb "risp/api/proto/gen/pb-go/github.com/mschristensen/risp/api/build/go"
+	"risp/internal/pkg/server"
+	"risp/internal/pkg/transcript"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+// record runs a transfer of a sequence fetched in ranges, recording the transcripts of the clients and the server.
+func record(t *testing.T) (clientEntries, serverEntries []*risppb.TranscriptEntry) {
+	t.Helper()
+	dir := t.TempDir()
+	serverRecorder, err := transcript.Create(filepath.Join(dir, "server"))
+	require.NoError(t, err)
+	clientRecorder, err := transcript.Create(filepath.Join(dir, "client"))
+	require.NoError(t, err)
+	addr := serve(t, server.WithRecorder(serverRecorder))
+
+	ctx := context.Background()
+	c, err := NewClient(WithServerAddrs(addr), WithSequenceLength(30), WithRecorder(clientRecorder))
+	require.NoError(t, err)
+	ranges, err := c.Split(2)
+	require.NoError(t, err)
+	errs := make(chan error, len(ranges))
+	for _, r := range ranges {
+		go func(r *Client) {
+			if err := r.Connect(ctx); err != nil {
+				errs <- err
+				return
+			}
+			errs <- r.Run(ctx)
+		}(r)
+	}
+	for range ranges {
+		require.NoError(t, <-errs)
+	}
+	require.NoError(t, c.Connect(ctx))
+	require.NoError(t, c.Run(ctx))
+	require.NoError(t, c.Finish())
+	for _, r := range ranges {
+		require.NoError(t, r.Finish())
+	}
+
+	require.NoError(t, clientRecorder.Close())
+	require.NoError(t, serverRecorder.Close())
+	clientEntries, err = transcript.Load(filepath.Join(dir, "client"))
+	require.NoError(t, err)
+	serverEntries, err = transcript.Load(filepath.Join(dir, "server"))
+	require.NoError(t, err)
+	return clientEntries, serverEntries
+}
+
+func TestReplay(t *testing.T) {
+	t.Parallel()
+	clientEntries, serverEntries := record(t)
+	side, err := transcript.RecordedBy(clientEntries)
+	require.NoError(t, err)
+	require.Equal(t, transcript.Client, side)
+	side, err = transcript.RecordedBy(serverEntries)
+	require.NoError(t, err)
+	require.Equal(t, transcript.Server, side)
+
+	require.NoError(t, Replay(clientEntries))
+	require.NoError(t, server.Replay(serverEntries))
+
+	// a client asking for a different window diverges from the transcript
+	var divergence *transcript.Divergence
+	for _, entry := range clientEntries {
+		if msg := entry.GetClient(); msg != nil && msg.State == risppb.ConnectionState_CONNECTED && msg.Window > 0 {
+			msg.Window++
+			break
+		}
+	}
+	require.True(t, errors.As(Replay(clientEntries), &divergence))
+
+	// a server sending a different item diverges from the transcript
+	for _, entry := range serverEntries {
+		if msg := entry.GetServer(); msg != nil && msg.State == risppb.ConnectionState_CONNECTED && !msg.Heartbeat {
+			msg.Index++
+			break
+		}
+	}
+	require.True(t, errors.As(server.Replay(serverEntries), &divergence))
+}
@@ -0,0 +1,26 @@
+package client
+
+import (
+	"context"
+
+	risppb "risp/api/proto/gen/pb-go/github.com/mschristensen/risp/api/build/go"
+
+	"github.com/pkg/errors"
+)
+
+// ListSequences lists the named sequences served by the first available server at the given addresses.
+func ListSequences(ctx context.Context, addrs ...string) ([]*risppb.SequenceInfo, error) {
+	if len(addrs) == 0 {
+		return nil, errors.New("no server addresses")
+	}
+	conn, err := dial(ctx, addrs)
+	if err != nil {
+		return nil, errors.Wrap(err, "dial failed")
+	}
+	defer conn.Close() // nolint: errcheck // the connection is only used for this request
+	res, err := risppb.NewRISPClient(conn).ListSequences(ctx, &risppb.ListSequencesRequest{})
+	if err != nil {
+		return nil, errors.Wrap(err, "list sequences failed")
+	}
+	return res.Sequences, nil
+}
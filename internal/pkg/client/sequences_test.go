@@ -0,0 +1,94 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"risp/internal/pkg/catalog"
+	"risp/internal/pkg/server"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNamedSequence(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "primes.txt"), []byte("2\n3\n5\n7\n11\n13\n"), 0o600))
+	cat, err := catalog.Load(dir)
+	require.NoError(t, err)
+	addr := serve(t, server.WithCatalog(cat))
+
+	sequences, err := ListSequences(ctx, addr)
+	require.NoError(t, err)
+	require.Len(t, sequences, 1)
+	require.Equal(t, "primes", sequences[0].Name)
+	require.Equal(t, uint32(6), sequences[0].Len)
+
+	c, err := NewClient(WithServerAddrs(addr), WithSequenceName("primes"))
+	require.NoError(t, err)
+	require.NoError(t, c.Connect(ctx))
+	require.NoError(t, c.Run(ctx))
+	require.True(t, c.Done())
+	require.NoError(t, c.Finish())
+	items, err := c.Sequence()
+	require.NoError(t, err)
+	require.Equal(t, []uint32{2, 3, 5, 7, 11, 13}, items)
+
+	// the ranges of a named sequence can be fetched in parallel once its length is known
+	c, err = NewClient(WithServerAddrs(addr), WithSequenceName("primes"), WithSequenceLength(6))
+	require.NoError(t, err)
+	ranges, err := c.Split(2)
+	require.NoError(t, err)
+	for _, r := range ranges {
+		require.NoError(t, r.Connect(ctx))
+		require.NoError(t, r.Run(ctx))
+		require.NoError(t, r.Finish())
+	}
+	require.NoError(t, c.Connect(ctx))
+	require.NoError(t, c.Run(ctx))
+	require.NoError(t, c.Finish())
+	require.Equal(t, []uint32{2, 3, 5, 7, 11, 13}, c.session.Sequence.ToUint32Slice())
+
+	c, err = NewClient(WithServerAddrs(addr), WithSequenceName("missing"))
+	require.NoError(t, err)
+	require.NoError(t, c.Connect(ctx))
+	require.Error(t, c.Run(ctx))
+}
+
+func TestFanOut(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "dataset.txt"), []byte("1\n2\n3\n4\n5\n6\n7\n8\n"), 0o600))
+	cat, err := catalog.Load(dir)
+	require.NoError(t, err)
+	mux, err := NewMux(ctx, serve(t, server.WithCatalog(cat)))
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, mux.Close())
+	}()
+
+	// many clients subscribe to the same sequence, each progressing through it independently
+	clients := make([]*Client, 20)
+	errs := make(chan error, len(clients))
+	for i := range clients {
+		clients[i], err = NewClient(WithSequenceName("dataset"))
+		require.NoError(t, err)
+		require.NoError(t, clients[i].ConnectVia(mux))
+		go func(c *Client) {
+			errs <- c.Run(ctx)
+		}(clients[i])
+	}
+	for range clients {
+		require.NoError(t, <-errs)
+	}
+	for i, c := range clients {
+		require.True(t, c.Done(), fmt.Sprintf("client %d not done", i))
+		require.NoError(t, c.Finish())
+		require.Equal(t, []uint32{1, 2, 3, 4, 5, 6, 7, 8}, c.session.Sequence.ToUint32Slice())
+	}
+}
@@ -0,0 +1,87 @@
+package client
+
+import (
+	"context"
+	"crypto/ed25519"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"risp/internal/pkg/server"
+	"risp/internal/pkg/session"
+	"risp/internal/pkg/transcript"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignature(t *testing.T) {
+	t.Parallel()
+	pub, key, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	other, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	signed := serve(t, server.WithSigningKey(key), server.WithLiveSource(func() session.Source {
+		return session.NewRandomSource(time.Millisecond, 20)
+	}))
+	unsigned := serve(t)
+
+	tests := []struct {
+		name     string
+		addr     string
+		cfgs     []Cfg
+		expected error
+	}{
+		{name: "signed", addr: signed, cfgs: []Cfg{WithSequenceLength(20), WithVerifyKey(pub)}},
+		{name: "signed live stream", addr: signed, cfgs: []Cfg{WithLive(0), WithVerifyKey(pub)}},
+		{name: "not verified", addr: signed, cfgs: []Cfg{WithSequenceLength(20)}},
+		{name: "signed with another key", addr: signed, cfgs: []Cfg{WithSequenceLength(20), WithVerifyKey(other)}, expected: ErrSignatureInvalid},
+		{name: "not signed", addr: unsigned, cfgs: []Cfg{WithSequenceLength(20), WithVerifyKey(pub)}, expected: ErrSignatureInvalid},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			ctx := context.Background()
+			c, err := NewClient(append([]Cfg{WithServerAddrs(test.addr)}, test.cfgs...)...)
+			require.NoError(t, err)
+			require.NoError(t, c.Connect(ctx))
+			require.NoError(t, c.Run(ctx))
+			err = c.Finish()
+			if test.expected == nil {
+				require.NoError(t, err)
+				return
+			}
+			require.True(t, errors.Is(err, test.expected), err)
+			require.False(t, Retryable(err))
+			_, err = c.Sequence()
+			require.True(t, errors.Is(err, test.expected), err)
+		})
+	}
+
+	_, err = NewClient(WithSequenceLength(20), WithVerifyKey(pub[:8]))
+	require.Error(t, err)
+}
+
+func TestSignatureReplay(t *testing.T) {
+	t.Parallel()
+	pub, key, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "server")
+	recorder, err := transcript.Create(path)
+	require.NoError(t, err)
+	addr := serve(t, server.WithSigningKey(key), server.WithRecorder(recorder))
+
+	ctx := context.Background()
+	c, err := NewClient(WithServerAddrs(addr), WithSequenceLength(20), WithVerifyKey(pub))
+	require.NoError(t, err)
+	require.NoError(t, c.Connect(ctx))
+	require.NoError(t, c.Run(ctx))
+	require.NoError(t, c.Finish())
+
+	// the replayed server has no signing key, so it sends the checksum without the signature
+	require.NoError(t, recorder.Close())
+	entries, err := transcript.Load(path)
+	require.NoError(t, err)
+	require.NoError(t, server.Replay(entries))
+}
@@ -0,0 +1,62 @@
+package client
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplit(t *testing.T) {
+	t.Parallel()
+	c, err := NewClient(WithSequenceLength(10))
+	require.NoError(t, err)
+	x := uint32(1)
+	c.session.Sequence[0] = &x
+	c.session.Sequence[5] = &x
+
+	ranges, err := c.Split(3)
+	require.NoError(t, err)
+	require.Len(t, ranges, 3)
+	expected := [][3]uint16{{1, 4, 1}, {4, 7, 4}, {7, 10, 7}}
+	for i, r := range ranges {
+		require.Equal(t, expected[i], [3]uint16{r.rangeStart, r.rangeEnd, r.session.Ack})
+		require.Equal(t, c.uuid, r.uuid)
+	}
+
+	_, err = ranges[0].Split(2)
+	require.Error(t, err)
+}
+
+func TestRanges(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	c, err := NewClient(WithServerAddrs(serve(t)), WithSequenceLength(100))
+	require.NoError(t, err)
+	ranges, err := c.Split(4)
+	require.NoError(t, err)
+	require.Len(t, ranges, 4)
+
+	errs := make(chan error, len(ranges))
+	for _, r := range ranges {
+		go func(r *Client) {
+			if err := r.Connect(ctx); err != nil {
+				errs <- err
+				return
+			}
+			errs <- r.Run(ctx)
+		}(r)
+	}
+	for range ranges {
+		require.NoError(t, <-errs)
+	}
+	for _, r := range ranges {
+		require.True(t, r.Done())
+		require.NoError(t, r.Finish())
+	}
+
+	require.NoError(t, c.Connect(ctx))
+	require.NoError(t, c.Run(ctx))
+	require.True(t, c.Done())
+	require.NoError(t, c.Finish())
+}
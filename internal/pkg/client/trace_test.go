@@ -0,0 +1,41 @@
+package client
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// TestTrace is not parallel, since it installs the global tracer provider.
+func TestTrace(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	ctx, root := otel.Tracer("test").Start(context.Background(), "test")
+	c, err := NewClient(WithServerAddrs(serve(t)), WithSequenceLength(20))
+	require.NoError(t, err)
+	require.NoError(t, c.Connect(ctx))
+	require.NoError(t, c.Run(ctx))
+	require.NoError(t, c.Finish())
+	root.End()
+
+	// the spans of the server are part of the trace of the client, since the trace context is propagated
+	spans := make(map[string]int)
+	for _, span := range recorder.Ended() {
+		if span.SpanContext().TraceID() == root.SpanContext().TraceID() {
+			spans[span.Name()]++
+		}
+	}
+	// the windows grow from 4 to 8 to 16 items, so 20 items take 3 windows
+	require.Equal(t, 3, spans["client.window"])
+	require.Equal(t, 1, spans["session.Store/New"])
+	require.Equal(t, 1, spans["session.Store/Clear"])
+	require.Positive(t, spans["session.Store/Get"])
+	require.Positive(t, spans["session.Store/Set"])
+}
@@ -0,0 +1,50 @@
+package client
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"risp/internal/pkg/server"
+	"risp/internal/pkg/session"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTune(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	srv, err := server.NewServer(server.WithSessionStore(session.NewMemoryStore()))
+	require.NoError(t, err)
+	tunables := srv.Tunables()
+	tunables.Ticker = time.Hour
+	require.NoError(t, srv.Tune(tunables))
+	addr := listen(t, srv)
+
+	c, err := NewClient(WithServerAddrs(addr), WithSequenceLength(20))
+	require.NoError(t, err)
+	require.NoError(t, c.Connect(ctx))
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Run(ctx)
+	}()
+	// the server sends nothing at its initial pace, until the stream is retuned
+	select {
+	case err := <-done:
+		t.Fatalf("client finished before the server was retuned: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	tunables.Ticker = time.Millisecond
+	require.NoError(t, srv.Tune(tunables))
+	require.Equal(t, tunables, srv.Tunables())
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(10 * time.Second):
+		t.Fatal("client did not finish after the server was retuned")
+	}
+	require.NoError(t, c.Finish())
+
+	tunables.Ticker = 0
+	require.Error(t, srv.Tune(tunables))
+}
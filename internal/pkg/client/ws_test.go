@@ -0,0 +1,113 @@
+package client
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	risppb "risp/api/proto/gen/pb-go/github.com/mschristensen/risp/api/build/go"
+	"risp/internal/pkg/protocol"
+	"risp/internal/pkg/server"
+	"risp/internal/pkg/session"
+	"risp/internal/pkg/ws"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// serveWebSocket serves the RISP server over WebSockets on a random local port and returns its address.
+func serveWebSocket(t *testing.T, srv *server.Server) string {
+	t.Helper()
+	ts := httptest.NewServer(srv.WebSocket())
+	t.Cleanup(ts.Close)
+	return strings.TrimPrefix(ts.URL, "http://")
+}
+
+func TestWebSocket(t *testing.T) {
+	t.Parallel()
+	srv, err := server.NewServer(server.WithSessionStore(session.NewMemoryStore()))
+	require.NoError(t, err)
+	addr := serveWebSocket(t, srv)
+	ctx := context.Background()
+
+	c, err := NewClient(WithServerAddrs("127.0.0.1:1", addr), WithSequenceLength(100), WithWebSocket(), WithEncodings(risppb.Encoding_DELTA))
+	require.NoError(t, err)
+	require.NoError(t, c.Connect(ctx))
+	require.NoError(t, c.Run(ctx))
+	require.NoError(t, c.Finish())
+
+	// the server fails the stream with the same status as over gRPC
+	c, err = NewClient(WithServerAddrs(addr), WithSequenceName("primes"), WithWebSocket())
+	require.NoError(t, err)
+	require.NoError(t, c.Connect(ctx))
+	err = c.Run(ctx)
+	require.Equal(t, codes.NotFound, status.Code(errors.Cause(err)), err)
+	require.False(t, Retryable(err))
+
+	mux, err := NewWebSocketMux(ctx, addr)
+	require.NoError(t, err)
+	for _, length := range []uint16{10, 200} {
+		c, err := NewClient(WithSequenceLength(length))
+		require.NoError(t, err)
+		require.NoError(t, c.ConnectVia(mux))
+		require.NoError(t, c.Run(ctx))
+		require.NoError(t, c.Finish())
+	}
+	require.NoError(t, mux.Close())
+}
+
+func TestWebSocketStream(t *testing.T) {
+	t.Parallel()
+	srv, err := server.NewServer(server.WithSessionStore(session.NewMemoryStore()))
+	require.NoError(t, err)
+	s, err := ws.Dial(context.Background(), serveWebSocket(t, srv))
+	require.NoError(t, err)
+	defer s.Close()
+
+	// the stream has no metadata, and the messages can be sent as those of any gRPC stream
+	header, err := s.Header()
+	require.NoError(t, err)
+	require.Empty(t, header)
+	require.Empty(t, s.Trailer())
+	clientUUID := uuid.New()
+	require.NoError(t, s.SendMsg(&risppb.ClientMessage{
+		State:   risppb.ConnectionState_CONNECTING,
+		Uuid:    clientUUID[:],
+		Len:     10,
+		Version: protocol.Version,
+	}))
+	var reply risppb.ServerMessage
+	require.NoError(t, s.RecvMsg(&reply))
+	require.Equal(t, risppb.ConnectionState_CONNECTING, reply.State)
+	require.Error(t, s.SendMsg("not a message"))
+}
+
+func TestWebSocketResume(t *testing.T) {
+	t.Parallel()
+	srv, err := server.NewServer(server.WithSessionStore(session.NewMemoryStore()))
+	require.NoError(t, err)
+	grpcAddr := listen(t, srv)
+	wsAddr := serveWebSocket(t, srv)
+	ctx := context.Background()
+
+	// the ranges of the session are fetched over either transport, and the sequence verified over a WebSocket
+	c, err := NewClient(WithServerAddrs(wsAddr), WithSequenceLength(100), WithWebSocket())
+	require.NoError(t, err)
+	ranges, err := c.Split(2)
+	require.NoError(t, err)
+	require.True(t, ranges[0].websocket)
+	ranges[1].serverAddrs = []string{grpcAddr}
+	ranges[1].websocket = false
+	for _, r := range ranges {
+		require.NoError(t, r.Connect(ctx))
+		require.NoError(t, r.Run(ctx))
+		require.NoError(t, r.Finish())
+	}
+	require.NoError(t, c.Connect(ctx))
+	require.NoError(t, c.Run(ctx))
+	require.NoError(t, c.Finish())
+}
@@ -0,0 +1,112 @@
+// Package conformance checks that a running RISP server speaks the protocol correctly.
+//
+// The checker drives scripted scenarios against the server over raw Connect streams, playing the part of
+// a client that misbehaves in specific ways, and checks every reply against the protocol described in the README.
+// Each scenario uses its own client UUIDs, so the scenarios can be run against a server that is serving
+// other clients at the same time.
+package conformance
+
+import (
+	"context"
+	"time"
+
+	risppb "risp/api/proto/gen/pb-go/github.com/mschristensen/risp/api/build/go"
+	"risp/internal/pkg/tracing"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// DefaultTimeout is the default time to wait for each expected message from the server.
+const DefaultTimeout = 5 * time.Second
+
+// Scenario is a scripted exchange with the server.
+type Scenario struct {
+	Name        string
+	Description string
+	run         func(ctx context.Context, c *Checker) error
+}
+
+// Result is the outcome of a scenario, which passed if Err is nil.
+type Result struct {
+	Scenario Scenario
+	Err      error
+	Elapsed  time.Duration
+}
+
+// Passed reports whether the scenario passed.
+func (r Result) Passed() bool {
+	return r.Err == nil
+}
+
+// Checker runs scenarios against a server.
+type Checker struct {
+	client  risppb.RISPClient
+	timeout time.Duration // the time to wait for each expected message, and for which the server must stay silent otherwise
+}
+
+// Cfg configures a Checker.
+type Cfg func(*Checker) error
+
+// WithTimeout sets the time to wait for each expected message from the server.
+// It is also the time for which the server must stay silent when no message is expected,
+// so it should be several times the interval between the messages of the server.
+func WithTimeout(timeout time.Duration) Cfg {
+	return func(c *Checker) error {
+		if timeout <= 0 {
+			return errors.New("timeout must be positive")
+		}
+		c.timeout = timeout
+		return nil
+	}
+}
+
+// NewChecker creates a new Checker of the server the connection is made to.
+func NewChecker(conn grpc.ClientConnInterface, cfgs ...Cfg) (*Checker, error) {
+	c := &Checker{
+		client:  risppb.NewRISPClient(conn),
+		timeout: DefaultTimeout,
+	}
+	for _, cfg := range cfgs {
+		if err := cfg(c); err != nil {
+			return nil, errors.Wrap(err, "apply Checker cfg failed")
+		}
+	}
+	return c, nil
+}
+
+// Dial connects to the server at the given address.
+func Dial(ctx context.Context, addr string) (*grpc.ClientConn, error) {
+	opts := []grpc.DialOption{
+		grpc.WithTransportCredentials(insecure.NewCredentials()), // TODO: use TLS
+	}
+	opts = append(opts, tracing.DialOptions()...)
+	conn, err := grpc.DialContext(ctx, addr, opts...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "connect to %s failed", addr)
+	}
+	return conn, nil
+}
+
+// Run runs the scenarios in turn, and returns the result of each.
+func (c *Checker) Run(ctx context.Context, scenarios ...Scenario) []Result {
+	results := make([]Result, 0, len(scenarios))
+	for _, s := range scenarios {
+		start := time.Now()
+		err := c.run(ctx, s)
+		results = append(results, Result{
+			Scenario: s,
+			Err:      err,
+			Elapsed:  time.Since(start),
+		})
+	}
+	return results
+}
+
+// run runs the scenario, closing any streams it leaves open.
+func (c *Checker) run(ctx context.Context, s Scenario) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	return s.run(ctx, c)
+}
@@ -0,0 +1,84 @@
+package conformance
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	risppb "risp/api/proto/gen/pb-go/github.com/mschristensen/risp/api/build/go"
+	"risp/internal/pkg/server"
+	"risp/internal/pkg/session"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+// listen serves the RISP service on a random local port and returns a checker of it.
+func listen(t *testing.T, srv risppb.RISPServer) *Checker {
+	t.Helper()
+	grpcServer := grpc.NewServer()
+	risppb.RegisterRISPServer(grpcServer, srv)
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() {
+		_ = grpcServer.Serve(lis)
+	}()
+	t.Cleanup(grpcServer.Stop)
+	conn, err := Dial(context.Background(), lis.Addr().String())
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = conn.Close()
+	})
+	c, err := NewChecker(conn, WithTimeout(200*time.Millisecond))
+	require.NoError(t, err)
+	return c
+}
+
+func TestServer(t *testing.T) {
+	t.Parallel()
+	testServer(t)
+}
+
+// TestServerDebug runs the scenarios against a server logging at debug level, at which every message received is
+// logged, including the malformed ones. It changes the level of the default logger, so it does not run in parallel.
+func TestServerDebug(t *testing.T) {
+	l := logrus.StandardLogger()
+	level, out := l.GetLevel(), l.Out
+	l.SetLevel(logrus.DebugLevel)
+	l.SetOutput(io.Discard)
+	defer func() {
+		l.SetLevel(level)
+		l.SetOutput(out)
+	}()
+	testServer(t)
+}
+
+// testServer requires a server to pass every scenario.
+func testServer(t *testing.T) {
+	t.Helper()
+	srv, err := server.NewServer(
+		server.WithSessionStore(session.NewMemoryStore()),
+		server.WithTunables(server.Tunables{
+			Ticker:            time.Millisecond,
+			HeartbeatInterval: time.Second,
+			IdleTimeout:       time.Minute,
+			LiveInterval:      time.Millisecond,
+		}),
+	)
+	require.NoError(t, err)
+	c := listen(t, srv)
+	for _, r := range c.Run(context.Background(), Scenarios()...) {
+		require.NoError(t, r.Err, r.Scenario.Name)
+	}
+}
+
+func TestUnimplemented(t *testing.T) {
+	t.Parallel()
+	c := listen(t, &risppb.UnimplementedRISPServer{})
+	for _, r := range c.Run(context.Background(), Scenarios()...) {
+		require.False(t, r.Passed(), r.Scenario.Name)
+	}
+}
@@ -0,0 +1,388 @@
+package conformance
+
+import (
+	"context"
+
+	risppb "risp/api/proto/gen/pb-go/github.com/mschristensen/risp/api/build/go"
+	"risp/internal/pkg/protocol"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+)
+
+// unknownFeature is a feature no version of the protocol defines.
+const unknownFeature = 1 << 63
+
+// missingName is the name of a sequence no server is expected to serve.
+const missingName = "risp-conformance-missing"
+
+// Scenarios returns every scenario, in the order they are run.
+func Scenarios() []Scenario {
+	return []Scenario{
+		{"handshake", "A whole transfer, from the handshake to the closing handshake.", handshake},
+		{"unknown features", "The negotiated features exclude those the server does not support.", unknownFeatures},
+		{"unsupported version", "A client without a version, or with a future version, is rejected.", unsupportedVersion},
+		{"handshake not first", "A stream that does not start with CONNECTING is rejected.", handshakeNotFirst},
+		{"invalid uuid", "A handshake with a malformed client UUID is rejected.", invalidUUID},
+		{"transfer id without multiplexing", "A transfer ID is rejected unless multiplexing is negotiated.", transferIDWithoutMultiplex},
+		{"unknown sequence name", "A request for a sequence the server does not have is rejected.", unknownName},
+		{"resume unknown session", "A client cannot resume a session the server does not have.", resumeUnknown},
+		{"window 0", "No items are sent while the window is 0, until the client opens it.", window0},
+		{"reconnect with stale ack", "A client reconnecting with an earlier ack is sent the same items again.", staleAck},
+		{"out-of-order acks", "An ack behind the last one rewinds the transfer to it.", outOfOrderAcks},
+		{"ack beyond the end", "An ack beyond the end of the sequence is rejected.", ackBeyondEnd},
+		{"ack outside the range", "An ack outside the range fetched by a ranged transfer is rejected.", ackOutsideRange},
+		{"closing before completion", "A premature CLOSING is answered with the checksum of the whole sequence, and the transfer can still resume.", closingEarly},
+		{"length mismatch on resume", "A client resuming a session with a different length is rejected.", lengthMismatch},
+	}
+}
+
+// connecting returns the handshake of a client speaking the current version of the protocol without any features.
+func connecting(length, ack, window uint32) *risppb.ClientMessage {
+	return &risppb.ClientMessage{
+		Version: protocol.Version,
+		Len:     length,
+		Ack:     ack,
+		Window:  window,
+	}
+}
+
+// sum returns the checksum of the items.
+func sum(items []uint32) uint64 {
+	var s uint64
+	for _, item := range items {
+		s += uint64(item)
+	}
+	return s
+}
+
+// equal requires the items to have been sent before.
+func equal(got, want []uint32) error {
+	for i := range got {
+		if got[i] != want[i] {
+			return errors.Errorf("got item %d again with payload %d, want %d", i, got[i], want[i])
+		}
+	}
+	return nil
+}
+
+// rejected requires the server to reject the handshake with the given gRPC status code.
+func rejected(ctx context.Context, c *Checker, msg *risppb.ClientMessage, code codes.Code) error {
+	s, err := c.connect(ctx, uuid.New())
+	if err != nil {
+		return err
+	}
+	defer s.close()
+	if err := s.send(msg); err != nil {
+		return err
+	}
+	return s.expectStatus(code)
+}
+
+// finish runs the closing handshake once every item is received, requiring the checksum to match the items,
+// and requires the server to end the stream.
+func finish(s *stream, items []uint32) error {
+	if err := s.send(&risppb.ClientMessage{State: risppb.ConnectionState_CLOSING, Ack: uint32(len(items))}); err != nil {
+		return err
+	}
+	msg, err := s.expect(risppb.ConnectionState_CLOSING)
+	if err != nil {
+		return err
+	}
+	if msg.Checksum != sum(items) {
+		return errors.Errorf("got checksum %d, want %d", msg.Checksum, sum(items))
+	}
+	if err := s.send(&risppb.ClientMessage{State: risppb.ConnectionState_CLOSED, Ack: uint32(len(items))}); err != nil {
+		return err
+	}
+	if _, err := s.expectAfterRepeats(risppb.ConnectionState_CLOSING, risppb.ConnectionState_CLOSED); err != nil {
+		return err
+	}
+	return s.expectEnd()
+}
+
+func handshake(ctx context.Context, c *Checker) error {
+	s, err := c.connect(ctx, uuid.New())
+	if err != nil {
+		return err
+	}
+	defer s.close()
+	reply, err := s.handshake(connecting(5, 0, 5))
+	if err != nil {
+		return err
+	}
+	if reply.Len != 5 {
+		return errors.Errorf("handshake reply has length %d, want 5", reply.Len)
+	}
+	items, err := s.items(0, 5)
+	if err != nil {
+		return err
+	}
+	return finish(s, items)
+}
+
+func unknownFeatures(ctx context.Context, c *Checker) error {
+	s, err := c.connect(ctx, uuid.New())
+	if err != nil {
+		return err
+	}
+	defer s.close()
+	msg := connecting(1, 0, 1)
+	msg.Features = uint64(protocol.Supported) | unknownFeature
+	reply, err := s.handshake(msg)
+	if err != nil {
+		return err
+	}
+	if reply.Features&unknownFeature != 0 {
+		return errors.New("handshake negotiated an unknown feature")
+	}
+	return nil
+}
+
+func unsupportedVersion(ctx context.Context, c *Checker) error {
+	for _, version := range []uint32{0, protocol.Version + 1000} {
+		msg := connecting(1, 0, 1)
+		msg.Version = version
+		if err := rejected(ctx, c, msg, codes.FailedPrecondition); err != nil {
+			return errors.Wrapf(err, "version %d", version)
+		}
+	}
+	return nil
+}
+
+func handshakeNotFirst(ctx context.Context, c *Checker) error {
+	msg := connecting(1, 0, 1)
+	msg.State = risppb.ConnectionState_CONNECTED
+	return rejected(ctx, c, msg, codes.InvalidArgument)
+}
+
+func invalidUUID(ctx context.Context, c *Checker) error {
+	s, err := c.connect(ctx, uuid.New())
+	if err != nil {
+		return err
+	}
+	defer s.close()
+	// send the message as is, since send sets a valid UUID
+	if err := s.s.Send(&risppb.ClientMessage{Version: protocol.Version, Len: 1, Window: 1, Uuid: []byte{1, 2, 3}}); err != nil {
+		return errors.Wrap(err, "send CONNECTING failed")
+	}
+	return s.expectStatus(codes.InvalidArgument)
+}
+
+func transferIDWithoutMultiplex(ctx context.Context, c *Checker) error {
+	msg := connecting(1, 0, 1)
+	msg.TransferId = 7
+	return rejected(ctx, c, msg, codes.InvalidArgument)
+}
+
+func unknownName(ctx context.Context, c *Checker) error {
+	msg := connecting(0, 0, 1)
+	msg.Name = missingName
+	return rejected(ctx, c, msg, codes.NotFound)
+}
+
+func resumeUnknown(ctx context.Context, c *Checker) error {
+	return rejected(ctx, c, connecting(5, 3, 1), codes.NotFound)
+}
+
+func window0(ctx context.Context, c *Checker) error {
+	s, err := c.connect(ctx, uuid.New())
+	if err != nil {
+		return err
+	}
+	defer s.close()
+	if _, err := s.handshake(connecting(5, 0, 0)); err != nil {
+		return err
+	}
+	if err := s.expectSilence(); err != nil {
+		return errors.Wrap(err, "window 0")
+	}
+	if err := s.send(&risppb.ClientMessage{State: risppb.ConnectionState_CONNECTED, Window: 2}); err != nil {
+		return err
+	}
+	if _, err := s.items(0, 2); err != nil {
+		return err
+	}
+	return errors.Wrap(s.expectSilence(), "window exhausted")
+}
+
+func staleAck(ctx context.Context, c *Checker) error {
+	clientUUID := uuid.New()
+	s, err := c.connect(ctx, clientUUID)
+	if err != nil {
+		return err
+	}
+	if _, err := s.handshake(connecting(10, 0, 4)); err != nil {
+		s.close()
+		return err
+	}
+	first, err := s.items(0, 4)
+	s.close()
+	if err != nil {
+		return err
+	}
+
+	// the client only kept the first item before disconnecting
+	s, err = c.connect(ctx, clientUUID)
+	if err != nil {
+		return err
+	}
+	defer s.close()
+	reply, err := s.handshake(connecting(10, 1, 3))
+	if err != nil {
+		return errors.Wrap(err, "reconnect")
+	}
+	if reply.Len != 10 {
+		return errors.Errorf("handshake reply has length %d, want 10", reply.Len)
+	}
+	again, err := s.items(1, 4)
+	if err != nil {
+		return errors.Wrap(err, "reconnect")
+	}
+	return equal(again, first[1:])
+}
+
+func outOfOrderAcks(ctx context.Context, c *Checker) error {
+	s, err := c.connect(ctx, uuid.New())
+	if err != nil {
+		return err
+	}
+	defer s.close()
+	if _, err := s.handshake(connecting(10, 0, 4)); err != nil {
+		return err
+	}
+	first, err := s.items(0, 4)
+	if err != nil {
+		return err
+	}
+	if err := s.send(&risppb.ClientMessage{State: risppb.ConnectionState_CONNECTED, Ack: 4, Window: 2}); err != nil {
+		return err
+	}
+	if _, err := s.items(4, 6); err != nil {
+		return err
+	}
+	// an ack behind the last one requests the items from it again
+	if err := s.send(&risppb.ClientMessage{State: risppb.ConnectionState_CONNECTED, Ack: 2, Window: 2}); err != nil {
+		return err
+	}
+	again, err := s.items(2, 4)
+	if err != nil {
+		return errors.Wrap(err, "rewound ack")
+	}
+	return equal(again, first[2:])
+}
+
+func ackBeyondEnd(ctx context.Context, c *Checker) error {
+	s, err := c.connect(ctx, uuid.New())
+	if err != nil {
+		return err
+	}
+	defer s.close()
+	if _, err := s.handshake(connecting(5, 0, 1)); err != nil {
+		return err
+	}
+	if _, err := s.items(0, 1); err != nil {
+		return err
+	}
+	if err := s.send(&risppb.ClientMessage{State: risppb.ConnectionState_CONNECTED, Ack: 9, Window: 1}); err != nil {
+		return err
+	}
+	return s.expectStatus(codes.InvalidArgument)
+}
+
+func ackOutsideRange(ctx context.Context, c *Checker) error {
+	s, err := c.connect(ctx, uuid.New())
+	if err != nil {
+		return err
+	}
+	defer s.close()
+	msg := connecting(6, 2, 1)
+	msg.Features = uint64(protocol.Ranges)
+	msg.RangeStart = 2
+	msg.RangeEnd = 4
+	if _, err := s.handshake(msg); err != nil {
+		return err
+	}
+	if _, err := s.items(2, 3); err != nil {
+		return err
+	}
+	// the ack is within the sequence, but before the start of the range
+	if err := s.send(&risppb.ClientMessage{State: risppb.ConnectionState_CONNECTED, Ack: 1, Window: 1}); err != nil {
+		return err
+	}
+	return s.expectStatus(codes.InvalidArgument)
+}
+
+func closingEarly(ctx context.Context, c *Checker) error {
+	clientUUID := uuid.New()
+	s, err := c.connect(ctx, clientUUID)
+	if err != nil {
+		return err
+	}
+	if _, err := s.handshake(connecting(5, 0, 2)); err != nil {
+		s.close()
+		return err
+	}
+	first, err := s.items(0, 2)
+	if err != nil {
+		s.close()
+		return err
+	}
+	if err := s.send(&risppb.ClientMessage{State: risppb.ConnectionState_CLOSING, Ack: 2}); err != nil {
+		s.close()
+		return err
+	}
+	msg, err := s.expect(risppb.ConnectionState_CLOSING)
+	s.close()
+	if err != nil {
+		return errors.Wrap(err, "premature CLOSING")
+	}
+	early := msg.Checksum
+
+	// the session survives the premature closing handshake, so the client can receive the rest
+	s, err = c.connect(ctx, clientUUID)
+	if err != nil {
+		return err
+	}
+	defer s.close()
+	if _, err := s.handshake(connecting(5, 2, 3)); err != nil {
+		return errors.Wrap(err, "reconnect")
+	}
+	rest, err := s.items(2, 5)
+	if err != nil {
+		return errors.Wrap(err, "reconnect")
+	}
+	items := append(first, rest...)
+	if early != sum(items) {
+		return errors.Errorf("premature CLOSING has checksum %d, want %d for the whole sequence", early, sum(items))
+	}
+	return finish(s, items)
+}
+
+func lengthMismatch(ctx context.Context, c *Checker) error {
+	clientUUID := uuid.New()
+	s, err := c.connect(ctx, clientUUID)
+	if err != nil {
+		return err
+	}
+	if _, err := s.handshake(connecting(5, 0, 2)); err != nil {
+		s.close()
+		return err
+	}
+	_, err = s.items(0, 2)
+	s.close()
+	if err != nil {
+		return err
+	}
+
+	s, err = c.connect(ctx, clientUUID)
+	if err != nil {
+		return err
+	}
+	defer s.close()
+	if err := s.send(&risppb.ClientMessage{State: risppb.ConnectionState_CONNECTING, Version: protocol.Version, Len: 6, Ack: 2, Window: 2}); err != nil {
+		return err
+	}
+	return s.expectStatus(codes.FailedPrecondition)
+}
@@ -0,0 +1,212 @@
+package conformance
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	risppb "risp/api/proto/gen/pb-go/github.com/mschristensen/risp/api/build/go"
+	"risp/internal/pkg/protocol"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// errTimeout indicates that no message was received from the server in time.
+var errTimeout = errors.New("timed out waiting for a message")
+
+// received is a message received from the server, or the error that ended the stream.
+type received struct {
+	msg *risppb.ServerMessage
+	err error
+}
+
+// stream is a Connect stream to the server, over which the checker plays the part of a single client.
+type stream struct {
+	uuid    uuid.UUID
+	s       risppb.RISP_ConnectClient
+	in      <-chan received
+	cancel  context.CancelFunc
+	timeout time.Duration
+}
+
+// connect opens a stream for the client with the given UUID.
+func (c *Checker) connect(ctx context.Context, clientUUID uuid.UUID) (*stream, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	s, err := c.client.Connect(ctx)
+	if err != nil {
+		cancel()
+		return nil, errors.Wrap(err, "connect failed")
+	}
+	in := make(chan received)
+	go func() {
+		defer close(in)
+		for {
+			msg, err := s.Recv()
+			select {
+			case <-ctx.Done():
+				return
+			case in <- received{msg, err}:
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return &stream{
+		uuid:    clientUUID,
+		s:       s,
+		in:      in,
+		cancel:  cancel,
+		timeout: c.timeout,
+	}, nil
+}
+
+// close drops the stream, as a client does when it disconnects abruptly.
+func (s *stream) close() {
+	s.cancel()
+}
+
+// send sends the message, setting the client UUID.
+func (s *stream) send(msg *risppb.ClientMessage) error {
+	msg.Uuid = s.uuid[:]
+	return errors.Wrapf(s.s.Send(msg), "send %s failed", msg.State)
+}
+
+// recv receives the next message from the server, ignoring heartbeats.
+func (s *stream) recv() (*risppb.ServerMessage, error) {
+	timeout := time.NewTimer(s.timeout)
+	defer timeout.Stop()
+	for {
+		select {
+		case r, ok := <-s.in:
+			if !ok {
+				return nil, errors.New("stream closed")
+			}
+			if r.err != nil {
+				return nil, r.err
+			}
+			if r.msg.Heartbeat {
+				continue
+			}
+			return r.msg, nil
+		case <-timeout.C:
+			return nil, errTimeout
+		}
+	}
+}
+
+// expect receives the next message from the server, which must be in the given state.
+func (s *stream) expect(state risppb.ConnectionState) (*risppb.ServerMessage, error) {
+	msg, err := s.recv()
+	if err != nil {
+		return nil, errors.Wrapf(err, "want %s", state)
+	}
+	if msg.State != state {
+		return nil, errors.Errorf("got %s, want %s", describe(msg), state)
+	}
+	return msg, nil
+}
+
+// expectAfterRepeats receives messages from the server, skipping any in the repeated state, until one arrives,
+// which must be in the given state. The server resends a reply until it receives the client's answer, so the reply
+// may be repeated while the answer is in flight.
+func (s *stream) expectAfterRepeats(repeated, state risppb.ConnectionState) (*risppb.ServerMessage, error) {
+	for {
+		msg, err := s.recv()
+		if err != nil {
+			return nil, errors.Wrapf(err, "want %s", state)
+		}
+		if msg.State == repeated {
+			continue
+		}
+		if msg.State != state {
+			return nil, errors.Errorf("got %s, want %s", describe(msg), state)
+		}
+		return msg, nil
+	}
+}
+
+// expectSilence requires the server not to send any message within the timeout.
+func (s *stream) expectSilence() error {
+	msg, err := s.recv()
+	if errors.Is(err, errTimeout) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "want no message")
+	}
+	return errors.Errorf("got %s, want no message", describe(msg))
+}
+
+// expectStatus requires the server to end the stream with the given gRPC status code.
+func (s *stream) expectStatus(code codes.Code) error {
+	msg, err := s.recv()
+	if err == nil {
+		return errors.Errorf("got %s, want a %s error", describe(msg), code)
+	}
+	if status.Code(err) != code {
+		return errors.Errorf("got %q, want a %s error", err, code)
+	}
+	return nil
+}
+
+// expectEnd requires the server to end the stream without an error.
+func (s *stream) expectEnd() error {
+	msg, err := s.recv()
+	if errors.Is(err, io.EOF) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "want the stream to end")
+	}
+	return errors.Errorf("got %s, want the stream to end", describe(msg))
+}
+
+// handshake sends the CONNECTING message and requires the server to reply with the negotiated protocol,
+// which must speak a supported version and only use features the client supports.
+func (s *stream) handshake(msg *risppb.ClientMessage) (*risppb.ServerMessage, error) {
+	msg.State = risppb.ConnectionState_CONNECTING
+	if err := s.send(msg); err != nil {
+		return nil, err
+	}
+	reply, err := s.expect(risppb.ConnectionState_CONNECTING)
+	if err != nil {
+		return nil, errors.Wrap(err, "handshake failed")
+	}
+	if err := protocol.CheckVersion(reply.Version); err != nil {
+		return nil, errors.Wrap(err, "handshake failed")
+	}
+	if extra := protocol.Features(reply.Features &^ msg.Features); extra != 0 {
+		return nil, errors.Errorf("handshake negotiated features the client does not support: %s", extra)
+	}
+	return reply, nil
+}
+
+// items requires the server to send the items from the given index onwards, up to but excluding the end,
+// and returns their payloads.
+func (s *stream) items(from, end uint32) ([]uint32, error) {
+	payloads := make([]uint32, 0, end-from)
+	for i := from; i < end; i++ {
+		msg, err := s.expect(risppb.ConnectionState_CONNECTED)
+		if err != nil {
+			return nil, errors.Wrapf(err, "want item %d", i)
+		}
+		if msg.Index != i {
+			return nil, errors.Errorf("got item %d, want item %d", msg.Index, i)
+		}
+		payloads = append(payloads, msg.Payload)
+	}
+	return payloads, nil
+}
+
+// describe describes a message from the server for a failure report.
+func describe(msg *risppb.ServerMessage) string {
+	if msg.State == risppb.ConnectionState_CONNECTED {
+		return fmt.Sprintf("CONNECTED item %d", msg.Index)
+	}
+	return msg.State.String()
+}
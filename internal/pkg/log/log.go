@@ -2,61 +2,195 @@
 package log
 
 import (
+	"encoding/hex"
+	"fmt"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	risppb "risp/api/proto/gen/pb-go/github.com/mschristensen/risp/api/build/go"
+	"risp/internal/pkg/protocol"
 
 	"github.com/google/uuid"
+	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 )
 
-var logger logrus.FieldLogger = logrus.StandardLogger()
-
-// SetLogger sets the default logger's level.
-func SetLogger(level string) {
-	logrus.SetLevel(logrus.ErrorLevel)
-	customFormatter := new(logrus.TextFormatter)
-	customFormatter.TimestampFormat = time.RFC3339
-	logrus.SetFormatter(customFormatter)
-	customFormatter.FullTimestamp = true
-	switch strings.ToLower(level) {
-	case "trace":
-		logrus.SetLevel(logrus.TraceLevel)
-	case "debug":
-		logrus.SetLevel(logrus.DebugLevel)
-	case "info":
-		logrus.SetLevel(logrus.InfoLevel)
-	case "warn":
-		logrus.SetLevel(logrus.WarnLevel)
-	case "error":
-		logrus.SetLevel(logrus.ErrorLevel)
-	default:
-		logrus.SetLevel(logrus.ErrorLevel)
+// sampleEvery is the number of CONNECTED messages for each one that is logged.
+var sampleEvery uint64 = 1
+
+// sampleCount counts the CONNECTED messages considered for logging.
+var sampleCount uint64
+
+// Cfg configures the default logger.
+type Cfg func(*logrus.Logger) error
+
+// WithFormat sets the format of the log output, which should be one of: text, json.
+func WithFormat(format string) Cfg {
+	return func(l *logrus.Logger) error {
+		switch strings.ToLower(format) {
+		case "", "text":
+			l.SetFormatter(&logrus.TextFormatter{
+				TimestampFormat: time.RFC3339,
+				FullTimestamp:   true,
+			})
+		case "json":
+			l.SetFormatter(&logrus.JSONFormatter{
+				TimestampFormat: time.RFC3339,
+			})
+		default:
+			return errors.Errorf("unknown log format %q", format)
+		}
+		return nil
+	}
+}
+
+// WithSampling logs only one in every n CONNECTED messages, which make up the bulk of a transfer.
+// Messages in any other state are always logged.
+func WithSampling(n int) Cfg {
+	return func(*logrus.Logger) error {
+		if n < 1 {
+			return errors.New("log sampling must be positive")
+		}
+		atomic.StoreUint64(&sampleEvery, uint64(n))
+		return nil
 	}
 }
 
+// WithFile writes the log output to the file at the given path instead of stderr, rotating the file once it
+// exceeds maxSizeMB megabytes and keeping at most maxBackups rotated files. An empty path leaves the output as it is.
+func WithFile(path string, maxSizeMB int, maxBackups int) Cfg {
+	return func(l *logrus.Logger) error {
+		if path == "" {
+			return nil
+		}
+		f, err := OpenRotatingFile(path, int64(maxSizeMB)<<20, maxBackups)
+		if err != nil {
+			return errors.Wrap(err, "open log file failed")
+		}
+		l.SetOutput(f)
+		return nil
+	}
+}
+
+// WithLevel sets the level of the log output, which should be one of: trace, debug, info, warn, error.
+// Any other level is treated as error.
+func WithLevel(level string) Cfg {
+	return func(l *logrus.Logger) error {
+		switch strings.ToLower(level) {
+		case "trace":
+			l.SetLevel(logrus.TraceLevel)
+		case "debug":
+			l.SetLevel(logrus.DebugLevel)
+		case "info":
+			l.SetLevel(logrus.InfoLevel)
+		case "warn":
+			l.SetLevel(logrus.WarnLevel)
+		case "error":
+			l.SetLevel(logrus.ErrorLevel)
+		default:
+			l.SetLevel(logrus.ErrorLevel)
+		}
+		return nil
+	}
+}
+
+// SetLogger sets the default logger's level, and applies the given configuration to it.
+func SetLogger(level string, cfgs ...Cfg) error {
+	return Configure(append([]Cfg{WithFormat("text"), WithLevel(level)}, cfgs...)...)
+}
+
+// Configure applies the given configuration to the default logger, leaving the rest of its configuration as it is,
+// so that it can be changed while the application is running.
+func Configure(cfgs ...Cfg) error {
+	l := logrus.StandardLogger()
+	for _, cfg := range cfgs {
+		if err := cfg(l); err != nil {
+			return errors.Wrap(err, "apply logger cfg failed")
+		}
+	}
+	return nil
+}
+
+// Sampled reports whether a message in the given state should be logged.
+// Messages are logged at Debug level, and CONNECTED messages are sampled according to WithSampling.
+func Sampled(state risppb.ConnectionState) bool {
+	if !logrus.IsLevelEnabled(logrus.DebugLevel) {
+		return false
+	}
+	if state != risppb.ConnectionState_CONNECTED {
+		return true
+	}
+	n := atomic.LoadUint64(&sampleEvery)
+	return n <= 1 || atomic.AddUint64(&sampleCount, 1)%n == 1
+}
+
 // ClientMessageToFields converts a client message to logrus.Fields.
+// The message may come from an untrusted client, so a UUID that does not parse is logged as hex.
 func ClientMessageToFields(msg *risppb.ClientMessage) logrus.Fields {
-	id, err := uuid.FromBytes(msg.Uuid)
-	if err != nil {
-		logger.Fatalln(err)
-	}
-	return logrus.Fields{
-		"uuid":   id.String(),
+	fields := logrus.Fields{
 		"state":  msg.State.String(),
 		"ack":    msg.Ack,
 		"len":    msg.Len,
 		"window": msg.Window,
 	}
+	if id, err := uuid.FromBytes(msg.Uuid); err == nil {
+		fields["uuid"] = id.String()
+	} else {
+		fields["uuid"] = hex.EncodeToString(msg.Uuid)
+	}
+	if msg.TransferId != 0 {
+		fields["transfer_id"] = msg.TransferId
+	}
+	if msg.State == risppb.ConnectionState_CONNECTING {
+		fields["version"] = msg.Version
+		fields["features"] = protocol.Features(msg.Features).String()
+		if msg.RangeEnd != 0 {
+			fields["range"] = fmt.Sprintf("%d-%d", msg.RangeStart, msg.RangeEnd)
+		}
+		if msg.Live {
+			fields["live"] = true
+		}
+		if msg.Name != "" {
+			fields["name"] = msg.Name
+		}
+		if len(msg.Encodings) > 0 {
+			encodings := make([]string, len(msg.Encodings))
+			for i, encoding := range msg.Encodings {
+				encodings[i] = encoding.String()
+			}
+			fields["encodings"] = strings.Join(encodings, ",")
+		}
+	}
+	return fields
 }
 
 // ServerMessageToFields converts a server message to logrus.Fields.
 func ServerMessageToFields(msg *risppb.ServerMessage) logrus.Fields {
-	return logrus.Fields{
+	fields := logrus.Fields{
 		"state":    msg.State.String(),
 		"index":    msg.Index,
 		"payload":  msg.Payload,
 		"checksum": msg.Checksum,
 	}
+	if msg.TransferId != 0 {
+		fields["transfer_id"] = msg.TransferId
+	}
+	if len(msg.Signature) > 0 {
+		fields["signed"] = true
+	}
+	if msg.Count > 0 {
+		delete(fields, "payload")
+		fields["count"] = msg.Count
+		fields["bytes"] = len(msg.Items)
+	}
+	if msg.State == risppb.ConnectionState_CONNECTING {
+		fields["version"] = msg.Version
+		fields["features"] = protocol.Features(msg.Features).String()
+		fields["len"] = msg.Len
+		if msg.Encoding != risppb.Encoding_NONE {
+			fields["encoding"] = msg.Encoding.String()
+		}
+	}
+	return fields
 }
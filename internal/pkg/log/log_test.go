@@ -0,0 +1,44 @@
+package log
+
+import (
+	"testing"
+
+	risppb "risp/api/proto/gen/pb-go/github.com/mschristensen/risp/api/build/go"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSampled(t *testing.T) {
+	require.NoError(t, SetLogger("info", WithSampling(3)))
+	require.False(t, Sampled(risppb.ConnectionState_CLOSING))
+
+	require.NoError(t, SetLogger("debug", WithSampling(3)))
+	defer func() {
+		require.NoError(t, SetLogger("debug", WithSampling(1)))
+	}()
+	var sampled int
+	for i := 0; i < 9; i++ {
+		if Sampled(risppb.ConnectionState_CONNECTED) {
+			sampled++
+		}
+	}
+	require.Equal(t, 3, sampled)
+	require.True(t, Sampled(risppb.ConnectionState_CONNECTING))
+
+	require.Error(t, SetLogger("debug", WithFormat("xml")))
+	require.NoError(t, SetLogger("debug", WithFormat("json")))
+	_, ok := logrus.StandardLogger().Formatter.(*logrus.JSONFormatter)
+	require.True(t, ok)
+}
+
+func TestClientMessageToFields(t *testing.T) {
+	t.Parallel()
+	fields := ClientMessageToFields(&risppb.ClientMessage{State: risppb.ConnectionState_CONNECTING, Uuid: []byte{1, 2, 0xff}})
+	require.Equal(t, "0102ff", fields["uuid"])
+
+	id := uuid.New()
+	fields = ClientMessageToFields(&risppb.ClientMessage{State: risppb.ConnectionState_CONNECTED, Uuid: id[:]})
+	require.Equal(t, id.String(), fields["uuid"])
+}
@@ -0,0 +1,106 @@
+package log
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// RotatingFile is a log file that is rotated once it exceeds a maximum size.
+// On rotation, the file is renamed with the suffix .1, any older files are shifted up by one,
+// and the oldest is removed if there would otherwise be more than the maximum number of backups.
+type RotatingFile struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+
+	mu   sync.Mutex
+	f    *os.File
+	size int64
+}
+
+// OpenRotatingFile opens the log file at the given path for appending, creating it if it does not exist.
+func OpenRotatingFile(path string, maxSize int64, maxBackups int) (*RotatingFile, error) {
+	if maxSize < 1 {
+		return nil, errors.New("maximum log file size must be positive")
+	}
+	if maxBackups < 0 {
+		return nil, errors.New("maximum number of log file backups must not be negative")
+	}
+	r := &RotatingFile{
+		path:       path,
+		maxSize:    maxSize,
+		maxBackups: maxBackups,
+	}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// open opens the log file, and records its current size.
+func (r *RotatingFile) open() error {
+	f, err := os.OpenFile(r.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644) // nolint: gosec // logs are meant to be read
+	if err != nil {
+		return errors.Wrap(err, "open failed")
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return errors.Wrap(err, "stat failed")
+	}
+	r.f = f
+	r.size = info.Size()
+	return nil
+}
+
+// backup returns the path of the nth rotated file.
+func (r *RotatingFile) backup(n int) string {
+	return fmt.Sprintf("%s.%d", r.path, n)
+}
+
+// rotate closes the log file, shifts the rotated files up by one, and opens a new log file.
+func (r *RotatingFile) rotate() error {
+	if err := r.f.Close(); err != nil {
+		return errors.Wrap(err, "close failed")
+	}
+	if r.maxBackups == 0 {
+		if err := os.Remove(r.path); err != nil && !os.IsNotExist(err) {
+			return errors.Wrap(err, "remove failed")
+		}
+		return r.open()
+	}
+	for n := r.maxBackups - 1; n > 0; n-- {
+		if err := os.Rename(r.backup(n), r.backup(n+1)); err != nil && !os.IsNotExist(err) {
+			return errors.Wrap(err, "rename backup failed")
+		}
+	}
+	if err := os.Rename(r.path, r.backup(1)); err != nil {
+		return errors.Wrap(err, "rename failed")
+	}
+	return r.open()
+}
+
+// Write writes to the log file, rotating it first if the write would take it over the maximum size.
+// A single write larger than the maximum size is written to a file of its own.
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.size > 0 && r.size+int64(len(p)) > r.maxSize {
+		if err := r.rotate(); err != nil {
+			return 0, errors.Wrap(err, "rotate log file failed")
+		}
+	}
+	n, err := r.f.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// Close closes the log file.
+func (r *RotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.f.Close()
+}
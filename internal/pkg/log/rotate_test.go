@@ -0,0 +1,37 @@
+package log
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRotatingFile(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "risp.log")
+	require.NoError(t, os.WriteFile(path, []byte("old\n"), 0o600))
+
+	f, err := OpenRotatingFile(path, 10, 2)
+	require.NoError(t, err)
+	for _, line := range []string{"one\n", "two\n", "three\n", "four\n", "five\n", "six\n"} {
+		_, err := f.Write([]byte(line))
+		require.NoError(t, err)
+	}
+	require.NoError(t, f.Close())
+
+	// the existing file is appended to, and the oldest backup is dropped
+	expected := map[string]string{
+		path:        "six\n",
+		path + ".1": "four\nfive\n",
+		path + ".2": "two\nthree\n",
+	}
+	for p, content := range expected {
+		data, err := os.ReadFile(p)
+		require.NoError(t, err)
+		require.Equal(t, content, string(data), p)
+	}
+	_, err = os.Stat(path + ".3")
+	require.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,127 @@
+// Package output writes a received sequence in one of several formats, to stdout or to a file.
+//
+// The sequence is encoded in full before anything is written, and a file is written to a temporary file
+// that is renamed into place, so that a reader never sees a partially written sequence.
+package output
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	risppb "risp/api/proto/gen/pb-go/github.com/mschristensen/risp/api/build/go"
+
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/proto"
+)
+
+// Stdout is the path that writes the sequence to stdout.
+const Stdout = "-"
+
+// Format is an encoding of a sequence.
+type Format string
+
+// The supported formats.
+const (
+	// Text is one item per line.
+	Text Format = "text"
+	// JSON is an array of the items.
+	JSON Format = "json"
+	// CSV has a header row, followed by a row with the index and value of each item.
+	CSV Format = "csv"
+	// Binary is the items as raw little-endian uint32 values.
+	Binary Format = "binary"
+	// Protobuf is a risp.v1.Sequence message.
+	Protobuf Format = "protobuf"
+)
+
+// ErrUnknownFormat indicates that a format is not supported.
+var ErrUnknownFormat = errors.New("unknown format")
+
+// Encode encodes the items in the given format.
+func Encode(format Format, items []uint32) ([]byte, error) {
+	var buf bytes.Buffer
+	switch format {
+	case Text:
+		for _, x := range items {
+			buf.WriteString(strconv.FormatUint(uint64(x), 10))
+			buf.WriteByte('\n')
+		}
+	case JSON:
+		if items == nil {
+			items = []uint32{}
+		}
+		if err := json.NewEncoder(&buf).Encode(items); err != nil {
+			return nil, errors.Wrap(err, "encode json failed")
+		}
+	case CSV:
+		w := csv.NewWriter(&buf)
+		_ = w.Write([]string{"index", "value"})
+		for i, x := range items {
+			_ = w.Write([]string{strconv.Itoa(i), strconv.FormatUint(uint64(x), 10)})
+		}
+		w.Flush()
+		if err := w.Error(); err != nil {
+			return nil, errors.Wrap(err, "encode csv failed")
+		}
+	case Binary:
+		b := make([]byte, 4*len(items))
+		for i, x := range items {
+			binary.LittleEndian.PutUint32(b[4*i:], x)
+		}
+		buf.Write(b)
+	case Protobuf:
+		b, err := proto.Marshal(&risppb.Sequence{Items: items})
+		if err != nil {
+			return nil, errors.Wrap(err, "encode protobuf failed")
+		}
+		buf.Write(b)
+	default:
+		return nil, errors.Wrapf(ErrUnknownFormat, "%q", format)
+	}
+	return buf.Bytes(), nil
+}
+
+// Write encodes the items in the given format, and writes them to the file at the given path,
+// or to stdout if the path is Stdout.
+func Write(path string, format Format, items []uint32) error {
+	data, err := Encode(format, items)
+	if err != nil {
+		return err
+	}
+	if path == Stdout {
+		_, err := os.Stdout.Write(data)
+		return errors.Wrap(err, "write to stdout failed")
+	}
+	return WriteFile(path, data)
+}
+
+// WriteFile atomically replaces the file at the given path with the data.
+func WriteFile(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return errors.Wrap(err, "create temporary file failed")
+	}
+	defer os.Remove(tmp.Name()) // nolint: errcheck // the file no longer exists once renamed
+	// the output is meant to be read by other programs, unlike the temporary file it is written to
+	if err := tmp.Chmod(0o644); err != nil { // nolint: gosec // the sequence is not sensitive
+		_ = tmp.Close()
+		return errors.Wrap(err, "chmod temporary file failed")
+	}
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return errors.Wrap(err, "write temporary file failed")
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return errors.Wrap(err, "sync temporary file failed")
+	}
+	if err := tmp.Close(); err != nil {
+		return errors.Wrap(err, "close temporary file failed")
+	}
+	return errors.Wrap(os.Rename(tmp.Name(), path), "rename temporary file failed")
+}
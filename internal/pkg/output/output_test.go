@@ -0,0 +1,72 @@
+package output
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	risppb "risp/api/proto/gen/pb-go/github.com/mschristensen/risp/api/build/go"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestEncode(t *testing.T) {
+	t.Parallel()
+	items := []uint32{3, 0, 4294967295}
+
+	data, err := Encode(Text, items)
+	require.NoError(t, err)
+	require.Equal(t, "3\n0\n4294967295\n", string(data))
+
+	data, err = Encode(JSON, items)
+	require.NoError(t, err)
+	var decoded []uint32
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	require.Equal(t, items, decoded)
+	data, err = Encode(JSON, nil)
+	require.NoError(t, err)
+	require.Equal(t, "[]\n", string(data))
+
+	data, err = Encode(CSV, items)
+	require.NoError(t, err)
+	require.Equal(t, "index,value\n0,3\n1,0\n2,4294967295\n", string(data))
+
+	data, err = Encode(Binary, items)
+	require.NoError(t, err)
+	require.Len(t, data, 12)
+	require.Equal(t, uint32(4294967295), binary.LittleEndian.Uint32(data[8:]))
+
+	data, err = Encode(Protobuf, items)
+	require.NoError(t, err)
+	var sequence risppb.Sequence
+	require.NoError(t, proto.Unmarshal(data, &sequence))
+	require.Equal(t, items, sequence.Items)
+
+	_, err = Encode("xml", items)
+	require.ErrorIs(t, err, ErrUnknownFormat)
+}
+
+func TestWriteFile(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "sequence.txt")
+	require.NoError(t, Write(path, Text, []uint32{1, 2}))
+	require.NoError(t, Write(path, Text, []uint32{3}))
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "3\n", string(data))
+
+	// an unknown format leaves the file as it was
+	require.Error(t, Write(path, "xml", []uint32{4}))
+	data, err = os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "3\n", string(data))
+
+	// no temporary files are left behind
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+}
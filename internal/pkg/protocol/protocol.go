@@ -0,0 +1,91 @@
+// Package protocol describes the versions and features of the RISP protocol,
+// which are negotiated by the client and server in the CONNECTING handshake.
+//
+// The client sends the protocol version it speaks and the set of features it supports.
+// The server rejects clients speaking a version it does not support, and otherwise replies
+// with a CONNECTING message containing its own version and the negotiated feature set, which
+// is the intersection of the features supported by both peers. Data only flows once the
+// client has received the reply, and both peers only use the negotiated features.
+package protocol
+
+import (
+	"fmt"
+	"math/bits"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Version is the version of the protocol implemented by this package.
+const Version uint32 = 1
+
+// MinVersion is the oldest version of the protocol that is still supported.
+// Peers that do not send a version are treated as speaking version 0.
+const MinVersion uint32 = 1
+
+// ErrUnsupportedVersion indicates that the peer speaks an unsupported version of the protocol.
+var ErrUnsupportedVersion = errors.New("unsupported protocol version")
+
+// Features is a set of protocol capabilities.
+type Features uint64
+
+// Protocol features.
+const (
+	// Heartbeat indicates that the peer sends heartbeat messages and detects idle peers.
+	Heartbeat Features = 1 << iota
+	// Multiplex indicates that many transfers can be multiplexed over a single stream,
+	// identified by the transfer ID on each message.
+	Multiplex
+	// Ranges indicates that a transfer can fetch a range of the sequence, so that a sequence
+	// can be fetched in parts over parallel streams.
+	Ranges
+	// Live indicates that the server can stream a live sequence, whose items are produced over time
+	// and whose length is unbounded.
+	Live
+)
+
+// featureNames are the names of the protocol features, indexed by bit.
+var featureNames = []string{
+	"heartbeat",
+	"multiplex",
+	"ranges",
+	"live",
+}
+
+// Supported is the set of features supported by this implementation.
+const Supported = Heartbeat | Multiplex | Ranges | Live
+
+// Has reports whether the set includes all of the given features.
+func (f Features) Has(features Features) bool {
+	return f&features == features
+}
+
+func (f Features) String() string {
+	var names []string
+	for f != 0 {
+		bit := bits.TrailingZeros64(uint64(f))
+		if bit < len(featureNames) {
+			names = append(names, featureNames[bit])
+		} else {
+			names = append(names, fmt.Sprintf("unknown(%d)", bit))
+		}
+		f &^= 1 << bit
+	}
+	return strings.Join(names, ",")
+}
+
+// CheckVersion returns ErrUnsupportedVersion if the given version is not supported.
+func CheckVersion(version uint32) error {
+	if version < MinVersion || version > Version {
+		return errors.Wrapf(ErrUnsupportedVersion, "peer speaks version %d but versions %d to %d are supported", version, MinVersion, Version)
+	}
+	return nil
+}
+
+// Negotiate returns the set of features to use with a peer speaking the given version and supporting the given features.
+func Negotiate(version uint32, features Features) (Features, error) {
+	if err := CheckVersion(version); err != nil {
+		return 0, err
+	}
+	return features & Supported, nil
+}
@@ -0,0 +1,140 @@
+// Package reconnect implements the policy used to reconnect to a server after a failure.
+//
+// Attempts are retried with exponential backoff and full jitter, that is the delay before
+// each attempt is chosen uniformly at random between zero and an exponentially growing cap.
+// The policy gives up when an attempt fails with an error that is not retryable, or when
+// the maximum number of attempts or the maximum elapsed time is reached.
+package reconnect
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var logger logrus.FieldLogger = logrus.StandardLogger()
+
+var tracer = otel.Tracer("risp/internal/pkg/reconnect")
+
+// DefaultBaseDelay is the default cap on the delay before the first retry.
+const DefaultBaseDelay = 500 * time.Millisecond
+
+// DefaultMaxDelay is the default maximum cap on the delay between attempts.
+const DefaultMaxDelay = 30 * time.Second
+
+// ErrPermanent indicates that the policy gave up because an attempt failed with an error that is not retryable.
+var ErrPermanent = errors.New("permanent error")
+
+// ErrMaxAttempts indicates that the policy gave up because the maximum number of attempts was reached.
+var ErrMaxAttempts = errors.New("max attempts reached")
+
+// ErrMaxElapsed indicates that the policy gave up because the maximum elapsed time was reached.
+var ErrMaxElapsed = errors.New("max elapsed time reached")
+
+// Error is returned when the policy gives up, and describes why.
+type Error struct {
+	// Reason is one of ErrPermanent, ErrMaxAttempts, ErrMaxElapsed or the context error.
+	Reason   error
+	Attempts int
+	Elapsed  time.Duration
+	// Err is the error returned by the last attempt.
+	Err error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("gave up after %d attempts in %s: %s: %s", e.Attempts, e.Elapsed.Round(time.Millisecond), e.Reason, e.Err)
+}
+
+// Unwrap returns the error returned by the last attempt.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether the target is the reason the policy gave up.
+func (e *Error) Is(target error) bool {
+	return errors.Is(e.Reason, target)
+}
+
+// Policy describes how to retry a failed attempt.
+type Policy struct {
+	// MaxAttempts is the maximum number of attempts, or 0 for no limit.
+	MaxAttempts int
+	// MaxElapsed is the maximum time spent retrying, or 0 for no limit.
+	MaxElapsed time.Duration
+	// BaseDelay is the cap on the delay before the first retry, which doubles with each attempt.
+	BaseDelay time.Duration
+	// MaxDelay is the maximum cap on the delay between attempts.
+	MaxDelay time.Duration
+	// Retryable reports whether an error is worth retrying. All errors are retried if it is nil.
+	Retryable func(error) bool
+}
+
+// NewPolicy creates a new Policy with the default delays.
+func NewPolicy(maxAttempts int, maxElapsed time.Duration, retryable func(error) bool) Policy {
+	return Policy{
+		MaxAttempts: maxAttempts,
+		MaxElapsed:  maxElapsed,
+		BaseDelay:   DefaultBaseDelay,
+		MaxDelay:    DefaultMaxDelay,
+		Retryable:   retryable,
+	}
+}
+
+// delay returns the randomised delay before the next attempt, given the number of attempts made so far.
+func (p Policy) delay(attempts int) time.Duration {
+	backoff := p.MaxDelay
+	if shift := attempts - 1; shift < 32 && p.BaseDelay<<shift < p.MaxDelay {
+		backoff = p.BaseDelay << shift
+	}
+	if backoff <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(backoff))) // nolint: gosec // we don't need high security here
+}
+
+// Do calls fn until it succeeds or the policy gives up, in which case an *Error is returned.
+func (p Policy) Do(ctx context.Context, fn func() error) error {
+	start := time.Now()
+	for attempts := 1; ; attempts++ {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+		giveUp := func(reason error) error {
+			return &Error{Reason: reason, Attempts: attempts, Elapsed: time.Since(start), Err: err}
+		}
+		if p.Retryable != nil && !p.Retryable(err) {
+			return giveUp(ErrPermanent)
+		}
+		if p.MaxAttempts > 0 && attempts >= p.MaxAttempts {
+			return giveUp(ErrMaxAttempts)
+		}
+		delay := p.delay(attempts)
+		if p.MaxElapsed > 0 && time.Since(start)+delay > p.MaxElapsed {
+			return giveUp(ErrMaxElapsed)
+		}
+		logger.WithFields(logrus.Fields{
+			"attempts": attempts,
+			"delay":    delay.String(),
+		}).Warning(errors.Wrap(err, "attempt failed, retrying"))
+		// the backoff is traced, so that the time spent waiting to reconnect shows in the trace of the transfer
+		_, span := tracer.Start(ctx, "reconnect.backoff", trace.WithAttributes(
+			attribute.Int("attempts", attempts),
+			attribute.String("delay", delay.String()),
+		))
+		select {
+		case <-ctx.Done():
+			span.End()
+			return giveUp(ctx.Err())
+		case <-time.After(delay):
+		}
+		span.End()
+	}
+}
@@ -0,0 +1,50 @@
+package reconnect
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+var errTransient = errors.New("transient")
+
+var errFatal = errors.New("fatal")
+
+func TestDo(t *testing.T) {
+	t.Parallel()
+	retryable := func(err error) bool { return !errors.Is(err, errFatal) }
+	tests := []struct {
+		name     string
+		policy   Policy
+		errs     []error
+		reason   error
+		attempts int
+	}{
+		{"succeeds", Policy{MaxAttempts: 3, Retryable: retryable}, []error{errTransient, nil}, nil, 2},
+		{"permanent", Policy{MaxAttempts: 3, Retryable: retryable}, []error{errTransient, errFatal}, ErrPermanent, 2},
+		{"max attempts", Policy{MaxAttempts: 3, Retryable: retryable}, []error{errTransient, errTransient, errTransient}, ErrMaxAttempts, 3},
+		{"max elapsed", Policy{MaxElapsed: time.Millisecond, BaseDelay: time.Hour, MaxDelay: time.Hour}, []error{errTransient}, ErrMaxElapsed, 1},
+	}
+	for i := range tests {
+		tt := tests[i]
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			attempts := 0
+			err := tt.policy.Do(context.Background(), func() error {
+				err := tt.errs[attempts]
+				attempts++
+				return err
+			})
+			require.Equal(t, tt.attempts, attempts)
+			if tt.reason == nil {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorIs(t, err, tt.reason)
+			require.ErrorIs(t, err, tt.errs[attempts-1])
+		})
+	}
+}
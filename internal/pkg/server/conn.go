@@ -0,0 +1,279 @@
+package server
+
+import (
+	"context"
+	"io"
+	"time"
+
+	risppb "risp/api/proto/gen/pb-go/github.com/mschristensen/risp/api/build/go"
+	"risp/internal/pkg/log"
+	"risp/internal/pkg/protocol"
+	"risp/internal/pkg/session"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// conn runs the transfers multiplexed over a single Connect stream.
+//
+// Messages from the client are routed to the handler for their transfer ID, and a handler is opened for each
+// CONNECTING handshake. On every server tick, each transfer with a message ready sends one message, and the
+// transfer that goes first rotates between ticks, so that the transfers share the stream fairly.
+type conn struct {
+	server *Server
+	id     uint64 // identifies the stream in the logs and the transcript
+	srv    risppb.RISP_ConnectServer
+	logger logrus.FieldLogger // carries the context of the stream
+	store  session.Store      // traces the operations on the session store as part of the stream
+
+	handlers map[uint32]*Handler
+	order    []*Handler // the order in which the handlers are scheduled
+	next     int        // the position in order of the handler to schedule first on the next tick
+
+	heartbeat   bool // whether heartbeats were negotiated by any transfer
+	multiplexed bool // whether the client multiplexes transfers, and so keeps the stream open between them
+}
+
+// newConn creates a new conn for the stream with the given ID, which logs with the given logger.
+func newConn(server *Server, id uint64, srv risppb.RISP_ConnectServer, l logrus.FieldLogger) *conn {
+	return &conn{
+		server:   server,
+		id:       id,
+		srv:      srv,
+		logger:   l,
+		store:    traced(srv.Context(), server.store),
+		handlers: make(map[uint32]*Handler),
+	}
+}
+
+// recv receives messages from the client and sends them on the returned channel,
+// which is closed when the client disconnects.
+func (c *conn) recv(ctx context.Context) <-chan *risppb.ClientMessage {
+	in := make(chan *risppb.ClientMessage)
+	go func() {
+		defer close(in)
+		for {
+			msg, err := c.srv.Recv()
+			if err != nil {
+				if errors.Is(err, io.EOF) || status.Code(err) == codes.Canceled {
+					c.logger.Warning("client disconnected")
+				} else {
+					c.logger.Warning(errors.Wrap(err, "receive failed"))
+				}
+				return
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case in <- msg:
+			}
+		}
+	}()
+	return in
+}
+
+// send sends a message to the client, logging it with the given logger.
+func (c *conn) send(l logrus.FieldLogger, msg *risppb.ServerMessage) error {
+	if err := c.srv.Send(msg); err != nil {
+		return errors.Wrap(err, "send message failed")
+	}
+	c.record(risppb.Direction_SENT, msg)
+	if msg.Heartbeat {
+		l.WithFields(log.ServerMessageToFields(msg)).Debug("sent heartbeat")
+	} else if log.Sampled(msg.State) {
+		l.WithFields(log.ServerMessageToFields(msg)).Debug("sent message")
+	}
+	return nil
+}
+
+// handleMessage routes the client message to the handler for its transfer,
+// opening a new handler if the message is a handshake.
+func (c *conn) handleMessage(msg *risppb.ClientMessage) error {
+	if msg.State == risppb.ConnectionState_CONNECTING {
+		h, reply, err := c.server.open(c.store, msg, c.logger)
+		if err != nil {
+			return err
+		}
+		if log.Sampled(msg.State) {
+			h.logger.WithFields(log.ClientMessageToFields(msg)).Debug("received message")
+		}
+		if err := c.send(h.logger, reply); err != nil {
+			c.server.release(h.clientUUID)
+			return errors.Wrap(err, "send handshake reply failed")
+		}
+		c.add(h)
+		return nil
+	}
+	h, ok := c.handlers[msg.TransferId]
+	if !ok {
+		if len(c.handlers) == 0 && !c.multiplexed {
+			return status.Error(codes.InvalidArgument, "client handshake must be CONNECTING")
+		}
+		// the client repeats CLOSED until it receives ours, so a repeat may arrive after the transfer is removed
+		if c.multiplexed && msg.State == risppb.ConnectionState_CLOSED {
+			c.logger.WithFields(log.ClientMessageToFields(msg)).Debug("ignoring message for finished transfer")
+			return nil
+		}
+		return status.Errorf(codes.InvalidArgument, "unknown transfer %d", msg.TransferId)
+	}
+	return wrap(h.handleMessage(msg), "handle message failed")
+}
+
+// add schedules the handler, replacing any existing handler for the same transfer.
+func (c *conn) add(h *Handler) {
+	if _, ok := c.handlers[h.transferID]; ok {
+		c.remove(h.transferID)
+	}
+	c.handlers[h.transferID] = h
+	c.order = append(c.order, h)
+	c.heartbeat = c.heartbeat || h.features.Has(protocol.Heartbeat)
+	c.multiplexed = c.multiplexed || h.transferID != 0
+}
+
+// remove stops scheduling the handler for the given transfer, which no longer uses its session.
+func (c *conn) remove(transferID uint32) {
+	if h, ok := c.handlers[transferID]; ok {
+		c.server.release(h.clientUUID)
+	}
+	delete(c.handlers, transferID)
+	for i := range c.order {
+		if c.order[i].transferID == transferID {
+			c.order = append(c.order[:i], c.order[i+1:]...)
+			break
+		}
+	}
+}
+
+// tick sends the next message of every transfer that has one ready, in round-robin order.
+// It returns true if there are no more transfers to run on the stream.
+func (c *conn) tick() (bool, error) {
+	n := len(c.order)
+	var finished []uint32
+	for i := 0; i < n; i++ {
+		h := c.order[(c.next+i)%n]
+		msg, err := h.nextMessage()
+		if err != nil {
+			return false, wrap(err, "next message failed")
+		}
+		if msg == nil {
+			continue
+		}
+		if err := c.send(h.logger, msg); err != nil {
+			return false, err
+		}
+		h.sent(msg)
+		if msg.State == risppb.ConnectionState_CLOSED {
+			finished = append(finished, h.transferID)
+		}
+	}
+	if n > 0 {
+		c.next = (c.next + 1) % n
+	}
+	for _, id := range finished {
+		c.remove(id)
+	}
+	return len(finished) > 0 && len(c.handlers) == 0 && !c.multiplexed, nil
+}
+
+// run runs the transfers on the stream until the client disconnects, or the only transfer is complete
+// if the client is not multiplexing transfers. The transfers are paced by the server tunables, which are
+// applied as soon as they change.
+// If heartbeats were negotiated, they are sent to the client at regular intervals, and if no message is received
+// from the client within the idle timeout, the stream is torn down. The session state acknowledged by the client
+// remains in the store, so that the client can resume the session when it reconnects.
+func (c *conn) run() error {
+	ctx, cancel := context.WithCancel(c.srv.Context())
+	defer cancel()
+	defer func() {
+		for id := range c.handlers {
+			c.remove(id)
+		}
+	}()
+	in := c.recv(ctx)
+	t, tuned := c.server.watchTunables()
+	ticker := time.NewTicker(t.Ticker)
+	defer ticker.Stop()
+	heartbeat := time.NewTicker(t.HeartbeatInterval)
+	defer heartbeat.Stop()
+	lastRecv := time.Now()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-tuned:
+			latest, next := c.server.watchTunables()
+			if latest.Ticker != t.Ticker {
+				ticker.Reset(latest.Ticker)
+			}
+			if latest.HeartbeatInterval != t.HeartbeatInterval {
+				heartbeat.Reset(latest.HeartbeatInterval)
+			}
+			t, tuned = latest, next
+		case msg, ok := <-in:
+			if !ok || msg == nil {
+				return nil
+			}
+			lastRecv = time.Now()
+			c.record(risppb.Direction_RECEIVED, msg)
+			if msg.Heartbeat {
+				c.logger.WithFields(log.ClientMessageToFields(msg)).Debug("received heartbeat")
+				continue
+			}
+			// a handshake is only logged once it is validated, as its fields are not yet to be trusted
+			if msg.State != risppb.ConnectionState_CONNECTING && log.Sampled(msg.State) {
+				c.logger.WithFields(log.ClientMessageToFields(msg)).Debug("received message")
+			}
+			if err := c.handleMessage(msg); err != nil {
+				c.recordError(err)
+				return err
+			}
+		case <-heartbeat.C:
+			if !c.heartbeat {
+				continue
+			}
+			if time.Since(lastRecv) > t.IdleTimeout {
+				c.logger.Warning("client idle timeout, disconnecting")
+				return status.Error(codes.DeadlineExceeded, ErrIdleTimeout.Error())
+			}
+			if err := c.send(c.logger, &risppb.ServerMessage{State: risppb.ConnectionState_CONNECTED, Heartbeat: true}); err != nil {
+				return err
+			}
+		case <-ticker.C:
+			done, err := c.tick()
+			if err != nil {
+				return err
+			}
+			if done {
+				c.logger.Info("disconnecting")
+				return nil
+			}
+		}
+	}
+}
+
+// record records the message in the transcript, if the server is recorded.
+func (c *conn) record(direction risppb.Direction, msg proto.Message) {
+	if c.server.recorder != nil {
+		c.server.recorder.Record(c.id, direction, msg)
+	}
+}
+
+// recordError records the error with which the server rejected the last message of the client in the transcript,
+// if the server is recorded.
+func (c *conn) recordError(err error) {
+	if c.server.recorder != nil {
+		c.server.recorder.RecordError(c.id, risppb.Direction_SENT, err)
+	}
+}
+
+// wrap wraps the error with the message, unless it is a status error, whose code must reach the client intact.
+func wrap(err error, message string) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	return errors.Wrap(err, message)
+}
@@ -3,7 +3,9 @@
 // The server performs the following steps:
 // 	1. Sets up a gRPC server to handle incoming connections from clients.
 // 	2. On connection with a client, it receives the initial handshake message with state CONNECTING,
-// 	   specifying the client UUID and sequence length and a small window size.
+// 	   specifying the client UUID and sequence length, a small window size and the supported protocol version and
+// 	   features. Clients speaking an unsupported version are rejected, otherwise the server replies with state
+// 	   CONNECTING and the negotiated features.
 // 	3. The server initialises the client session state with a random sequence, before sending the
 // 	   server response with the state CONNECTED, containing the first payload item.
 // 	4. The server repeatedly sends sequence items until the window size is exhausted.
@@ -16,6 +18,46 @@
 // it updates the client state in a session store. This session store could be adapted to a persistent store like Redis
 // to allow multiple server instances to handle client reconnections.
 //
+// A session references its sequence rather than holding a copy. A random sequence is generated for each client,
+// whereas a named sequence is loaded once and shared by every session for it, so that serving the same sequence
+// to many clients costs little more than serving it to one.
+//
+// Heartbeats are exchanged with the client, and a handler whose client sends no message within the idle timeout
+// tears down the stream, leaving the session in the store for the client to resume.
+//
+// A client that negotiates multiplexing can run many transfers over a single stream, each with its own handler.
+// On every tick, the server sends at most one message for each transfer in round-robin order, so that the
+// transfers share the stream fairly.
+//
+// A live session has no fixed sequence. Its items are produced over time by a source, and kept in a bounded replay
+// buffer until the client acknowledges them. The server closes the stream when the source ends, or when the client
+// asks to close it.
+//
+// A transfer can be restricted to a range of the sequence, so that a client can fetch the sequence over parallel
+// streams sharing the same session. The position of each range is tracked by its handler, and the sequence is
+// verified by a final transfer without a range.
+//
+// A server configured using WithCatalog also serves the named sequences of a catalog loaded from data files.
+// A client requests a named sequence by name instead of by length, and the sequences are listed by ListSequences.
+//
+// The pacing of the transfers, the heartbeats and the live sessions is governed by the server Tunables, which
+// can be changed with Tune while the server is running. Open streams apply the change immediately.
+// ExpireSessions clears the sessions that no transfer has used for longer than the session TTL of the tunables,
+// so that the sessions abandoned by their clients do not accumulate.
+//
+// Every operation on the session store is traced as a span of the stream it is made for, which is part of the
+// trace of the client transfer if the client propagates its trace context.
+//
+// A server configured using WithRecorder records every message of every stream to a transcript, in the order in which
+// each stream handled them. Replay re-drives the server from such a transcript to reproduce its state transitions.
+//
+// Gateway exposes the sessions over HTTP as JSON, for clients that cannot open a bidirectional gRPC stream. Each
+// request is a step of the protocol on its own, applied to the same session store, so that a session can be
+// resumed over either transport.
+//
+// WebSocket serves the same streams as Connect over WebSockets, for clients behind proxies that block the HTTP/2
+// streams of gRPC.
+//
 // Additional flags can be specified to control the server message sending interval.
 //
 // TODO: it would be nice to switch up message ordering, to demonstrate how the protocol can deal with this.
@@ -0,0 +1,9 @@
+package server
+
+import "github.com/pkg/errors"
+
+// ErrIdleTimeout indicates that no message was received from the client within the idle timeout.
+var ErrIdleTimeout = errors.New("idle timeout")
+
+// ErrSessionInUse indicates that the session cannot be cleared, since a transfer is using it.
+var ErrSessionInUse = errors.New("session in use")
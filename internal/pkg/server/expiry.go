@@ -0,0 +1,137 @@
+package server
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"risp/internal/pkg/session"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+// sweepsPerTTL is the number of times the sessions are checked for expiry within each session TTL, so that an
+// abandoned session is cleared at most a quarter of the TTL late.
+const sweepsPerTTL = 4
+
+// expiry tracks which sessions are in use by a transfer, and since when the others have been idle, so that the
+// sessions abandoned by their clients can be cleared once the session TTL has passed.
+type expiry struct {
+	mu    sync.Mutex
+	inUse map[uuid.UUID]int       // the number of transfers using each session
+	idle  map[uuid.UUID]time.Time // when each session that is not in use was last used
+}
+
+// newExpiry creates a new expiry tracker with no sessions.
+func newExpiry() *expiry {
+	return &expiry{
+		inUse: make(map[uuid.UUID]int),
+		idle:  make(map[uuid.UUID]time.Time),
+	}
+}
+
+// acquire marks the session of the client as in use, so that it does not expire.
+func (e *expiry) acquire(clientUUID uuid.UUID) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.inUse[clientUUID]++
+	delete(e.idle, clientUUID)
+}
+
+// release marks the session of the client as no longer used by one of its transfers. Once no transfer uses it,
+// the session is idle from now on, unless it no longer exists, in which case it is no longer tracked.
+func (e *expiry) release(clientUUID uuid.UUID, exists bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.inUse[clientUUID]--; e.inUse[clientUUID] > 0 {
+		return
+	}
+	delete(e.inUse, clientUUID)
+	if exists {
+		e.idle[clientUUID] = time.Now()
+	}
+}
+
+// sweep clears every session from the store that has been idle for longer than the TTL, and returns how many
+// were cleared. The tracker is locked throughout, so that a session cannot be acquired while it is cleared.
+func (e *expiry) sweep(store session.Store, ttl time.Duration) (int, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	var cleared int
+	for clientUUID, since := range e.idle {
+		if time.Since(since) <= ttl {
+			continue
+		}
+		delete(e.idle, clientUUID)
+		if err := store.Clear(clientUUID); err != nil {
+			if errors.Is(err, session.ErrSessionNotFound) {
+				continue // the session was cleared by its client in the meantime
+			}
+			return cleared, errors.Wrap(err, "clear session failed")
+		}
+		cleared++
+	}
+	return cleared, nil
+}
+
+// clear clears the session of the client from the store, unless a transfer is using it. The tracker is locked
+// throughout, so that a session cannot be acquired while it is cleared.
+func (e *expiry) clear(store session.Store, clientUUID uuid.UUID) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.inUse[clientUUID] > 0 {
+		return ErrSessionInUse
+	}
+	if err := store.Clear(clientUUID); err != nil {
+		return err
+	}
+	delete(e.idle, clientUUID)
+	return nil
+}
+
+// acquire marks the session of the client as in use by a transfer until it is released.
+func (s *Server) acquire(clientUUID uuid.UUID) {
+	s.expiry.acquire(clientUUID)
+}
+
+// release marks the session of the client as no longer used by a transfer, from when the session TTL runs.
+func (s *Server) release(clientUUID uuid.UUID) {
+	_, err := s.store.Get(clientUUID)
+	s.expiry.release(clientUUID, !errors.Is(err, session.ErrSessionNotFound))
+}
+
+// ExpireSessions clears the sessions that no transfer has used for longer than the session TTL of the server
+// tunables, until the context is done. Sessions never expire while the TTL is 0.
+// A client whose session expired can no longer resume its transfer, and must start again.
+func (s *Server) ExpireSessions(ctx context.Context) {
+	t, tuned := s.watchTunables()
+	for {
+		var sweep <-chan time.Time
+		var timer *time.Timer
+		if t.SessionTTL > 0 {
+			timer = time.NewTimer(t.SessionTTL / sweepsPerTTL)
+			sweep = timer.C
+		}
+		select {
+		case <-ctx.Done():
+			if timer != nil {
+				timer.Stop()
+			}
+			return
+		case <-tuned:
+			if timer != nil {
+				timer.Stop()
+			}
+			t, tuned = s.watchTunables()
+		case <-sweep:
+			cleared, err := s.expiry.sweep(s.store, t.SessionTTL)
+			if err != nil {
+				logger.Error(errors.Wrap(err, "expire sessions failed"))
+			}
+			if cleared > 0 {
+				logger.WithField("sessions", cleared).Info("expired idle sessions")
+			}
+		}
+	}
+}
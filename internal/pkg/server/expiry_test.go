@@ -0,0 +1,91 @@
+package server
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	risppb "risp/api/proto/gen/pb-go/github.com/mschristensen/risp/api/build/go"
+	"risp/internal/pkg/protocol"
+	"risp/internal/pkg/session"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+func TestExpireSessions(t *testing.T) {
+	t.Parallel()
+	const ttl = 50 * time.Millisecond
+	tunables := Tunables{
+		Ticker:            time.Millisecond,
+		HeartbeatInterval: time.Second,
+		IdleTimeout:       time.Minute,
+		LiveInterval:      time.Millisecond,
+		SessionTTL:        ttl,
+	}
+	srv, err := NewServer(WithSessionStore(session.NewMemoryStore()), WithTunables(tunables))
+	require.NoError(t, err)
+	grpcServer := grpc.NewServer()
+	risppb.RegisterRISPServer(grpcServer, srv)
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() {
+		_ = grpcServer.Serve(lis)
+	}()
+	t.Cleanup(grpcServer.Stop)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go srv.ExpireSessions(ctx)
+	expired := func(clientUUID uuid.UUID) bool {
+		_, err := srv.store.Get(clientUUID)
+		return errors.Is(err, session.ErrSessionNotFound)
+	}
+
+	// a session is kept while a transfer uses it, however long the client takes to acknowledge the items
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+	streamCtx, closeStream := context.WithCancel(ctx)
+	stream, err := risppb.NewRISPClient(conn).Connect(streamCtx)
+	require.NoError(t, err)
+	inUse := uuid.New()
+	require.NoError(t, stream.Send(&risppb.ClientMessage{
+		State:   risppb.ConnectionState_CONNECTING,
+		Uuid:    inUse[:],
+		Len:     10,
+		Window:  5,
+		Version: protocol.Version,
+	}))
+	_, err = stream.Recv()
+	require.NoError(t, err)
+	time.Sleep(4 * ttl)
+	require.False(t, expired(inUse))
+
+	// and expires once the client is gone for longer than the TTL
+	closeStream()
+	require.Eventually(t, func() bool { return expired(inUse) }, 20*ttl, ttl/10)
+
+	// as does a session that no transfer ever used after the handshake
+	idle := uuid.New()
+	require.NoError(t, srv.store.New(idle, "", session.NewRandomSequence(10)))
+	srv.acquire(idle)
+	srv.release(idle)
+	require.Eventually(t, func() bool { return expired(idle) }, 20*ttl, ttl/10)
+
+	// sessions never expire once the TTL is 0
+	tunables.SessionTTL = 0
+	require.NoError(t, srv.Tune(tunables))
+	kept := uuid.New()
+	require.NoError(t, srv.store.New(kept, "", session.NewRandomSequence(10)))
+	srv.acquire(kept)
+	srv.release(kept)
+	time.Sleep(4 * ttl)
+	require.False(t, expired(kept))
+
+	tunables.SessionTTL = -time.Second
+	require.Error(t, srv.Tune(tunables))
+}
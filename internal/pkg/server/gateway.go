@@ -0,0 +1,332 @@
+package server
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	risppb "risp/api/proto/gen/pb-go/github.com/mschristensen/risp/api/build/go"
+	"risp/internal/pkg/protocol"
+	"risp/internal/pkg/session"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// MaxGatewayWindow is the largest number of items the gateway returns in a window.
+const MaxGatewayWindow = 1 << 8
+
+// gatewayPrefix is the path under which the gateway serves the sessions.
+const gatewayPrefix = "/v1/sessions"
+
+// internalError is the message of the response to a request that failed because of the server, whose error is logged.
+const internalError = "internal error"
+
+// methodError indicates that the path does not support the request method, but only the allowed one.
+type methodError struct {
+	allow string
+}
+
+func (e methodError) Error() string {
+	return "method not allowed"
+}
+
+// GRPCStatus returns the status of the error, so that it is reported like the other errors of the gateway.
+func (e methodError) GRPCStatus() *status.Status {
+	return status.New(codes.Unimplemented, e.Error())
+}
+
+// StartRequest is the body of a request to the gateway to start or resume a session.
+// The UUID is generated by the server if it is not given.
+type StartRequest struct {
+	UUID string `json:"uuid,omitempty"`
+	Len  uint32 `json:"len,omitempty"`
+	Name string `json:"name,omitempty"`
+	Ack  uint32 `json:"ack,omitempty"`
+}
+
+// StartResponse is the response of the gateway to a request to start or resume a session.
+type StartResponse struct {
+	UUID string `json:"uuid"`
+	Len  uint32 `json:"len"`
+	Ack  uint32 `json:"ack"`
+}
+
+// WindowResponse is the response of the gateway to a request for the window of items after an ack.
+type WindowResponse struct {
+	Index uint32   `json:"index"`
+	Items []uint32 `json:"items"`
+}
+
+// ChecksumResponse is the response of the gateway to a request for the checksum of a sequence.
+type ChecksumResponse struct {
+	Checksum  uint64 `json:"checksum"`
+	Signature []byte `json:"signature,omitempty"`
+}
+
+// ErrorResponse is the response of the gateway to a request that failed.
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
+// gateway serves the sessions of a server over HTTP, for clients that cannot stream over gRPC.
+type gateway struct {
+	server *Server
+}
+
+// Gateway returns an HTTP handler exposing the sessions of the server as JSON, for clients that cannot open a
+// bidirectional gRPC stream. Every request is a step of the protocol on its own:
+//
+//   - POST /v1/sessions starts or resumes a session, as the handshake does, with a StartRequest.
+//   - GET /v1/sessions/{uuid}/items?ack=&window= acknowledges the items before ack, and returns the window of
+//     items after it.
+//   - GET /v1/sessions/{uuid}/checksum?ack= returns the checksum once ack is the length of the sequence, as the
+//     closing handshake does.
+//   - DELETE /v1/sessions/{uuid} closes the session, unless a transfer is using it.
+//
+// The gateway shares the session store of the server, so a session started over HTTP can be resumed over gRPC,
+// and the other way around. Live sessions are not served over HTTP.
+func (s *Server) Gateway() http.Handler {
+	return &gateway{server: s}
+}
+
+// ServeHTTP implements http.Handler.
+func (g *gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	l := logger.WithFields(logrus.Fields{
+		"method": r.Method,
+		"path":   r.URL.Path,
+		"remote": r.RemoteAddr,
+	})
+	res, err := g.route(r)
+	if err != nil {
+		code, msg := gatewayStatus(err)
+		if code == http.StatusInternalServerError {
+			l.WithError(err).Error("gateway request failed")
+		} else {
+			l.WithError(err).Info("gateway request rejected")
+		}
+		var methodErr methodError
+		if errors.As(err, &methodErr) {
+			w.Header().Set("Allow", methodErr.allow)
+		}
+		writeJSON(w, code, &ErrorResponse{Error: msg})
+		return
+	}
+	l.Debug("gateway request served")
+	if res == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+// route serves the request, and returns the response to write, if any.
+func (g *gateway) route(r *http.Request) (interface{}, error) {
+	if r.URL.Path == gatewayPrefix {
+		if r.Method != http.MethodPost {
+			return nil, methodError{allow: http.MethodPost}
+		}
+		return g.start(r)
+	}
+	if !strings.HasPrefix(r.URL.Path, gatewayPrefix+"/") {
+		return nil, status.Errorf(codes.NotFound, "no such path %s", r.URL.Path)
+	}
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, gatewayPrefix+"/"), "/")
+	if len(parts) > 2 {
+		return nil, status.Errorf(codes.NotFound, "no such path %s", r.URL.Path)
+	}
+	clientUUID, err := uuid.Parse(parts[0])
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "parse client UUID failed: %s", err)
+	}
+	resource := ""
+	if len(parts) == 2 {
+		resource = parts[1]
+	}
+	switch {
+	case resource == "" && r.Method == http.MethodDelete:
+		return nil, g.close(clientUUID)
+	case resource == "items" && r.Method == http.MethodGet:
+		return g.window(r, clientUUID)
+	case resource == "checksum" && r.Method == http.MethodGet:
+		return g.checksum(r, clientUUID)
+	case resource == "":
+		return nil, methodError{allow: http.MethodDelete}
+	case resource == "items" || resource == "checksum":
+		return nil, methodError{allow: http.MethodGet}
+	}
+	return nil, status.Errorf(codes.NotFound, "no such path %s", r.URL.Path)
+}
+
+// start starts or resumes a session, using the same handshake as a gRPC client.
+func (g *gateway) start(r *http.Request) (*StartResponse, error) {
+	var req StartRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "decode request failed: %s", err)
+	}
+	if (req.Len == 0 && req.Name == "") || req.Len > math.MaxUint16 {
+		return nil, status.Errorf(codes.InvalidArgument, "len must be from 1 to %d, unless a name is given", math.MaxUint16)
+	}
+	clientUUID := uuid.New()
+	if req.UUID != "" {
+		var err error
+		if clientUUID, err = uuid.Parse(req.UUID); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "parse client UUID failed: %s", err)
+		}
+	}
+	_, reply, err := g.server.open(g.server.store, &risppb.ClientMessage{
+		State:   risppb.ConnectionState_CONNECTING,
+		Uuid:    clientUUID[:],
+		Len:     req.Len,
+		Name:    req.Name,
+		Ack:     req.Ack,
+		Version: protocol.Version,
+	}, logger.WithField("gateway", true))
+	if err != nil {
+		return nil, err
+	}
+	// no transfer keeps using the session between the requests of the gateway
+	g.server.release(clientUUID)
+	return &StartResponse{UUID: clientUUID.String(), Len: reply.Len, Ack: req.Ack}, nil
+}
+
+// window acknowledges the items before the ack, and returns the window of items after it.
+func (g *gateway) window(r *http.Request, clientUUID uuid.UUID) (*WindowResponse, error) {
+	g.server.acquire(clientUUID)
+	defer g.server.release(clientUUID)
+	sess, err := g.session(clientUUID)
+	if err != nil {
+		return nil, err
+	}
+	ack, err := queryUint(r, "ack", len(sess.Sequence), 0)
+	if err != nil {
+		return nil, err
+	}
+	window, err := queryUint(r, "window", MaxGatewayWindow, MaxGatewayWindow)
+	if err != nil {
+		return nil, err
+	}
+	sess.Ack = uint16(ack)
+	sess.Window = uint16(window)
+	if err := g.server.store.Set(clientUUID, sess); err != nil {
+		return nil, errors.Wrap(err, "set session failed")
+	}
+	end := ack + window
+	if end > len(sess.Sequence) {
+		end = len(sess.Sequence)
+	}
+	res := &WindowResponse{Index: uint32(ack), Items: make([]uint32, 0, end-ack)}
+	for _, item := range sess.Sequence[ack:end] {
+		res.Items = append(res.Items, *item)
+	}
+	return res, nil
+}
+
+// checksum returns the checksum of the sequence, signed if the server has a signing key, once the ack shows that
+// every item was received.
+func (g *gateway) checksum(r *http.Request, clientUUID uuid.UUID) (*ChecksumResponse, error) {
+	g.server.acquire(clientUUID)
+	defer g.server.release(clientUUID)
+	sess, err := g.session(clientUUID)
+	if err != nil {
+		return nil, err
+	}
+	ack, err := queryUint(r, "ack", len(sess.Sequence), 0)
+	if err != nil {
+		return nil, err
+	}
+	if ack != len(sess.Sequence) {
+		return nil, status.Errorf(codes.FailedPrecondition, "ack %d before the end %d", ack, len(sess.Sequence))
+	}
+	sess.Ack = uint16(ack)
+	if err := g.server.store.Set(clientUUID, sess); err != nil {
+		return nil, errors.Wrap(err, "set session failed")
+	}
+	// the checksum is sent by a handler in the closing state, as it is to a gRPC client
+	h, err := NewHandler(0, clientUUID, g.server.store, 0)
+	if err != nil {
+		return nil, errors.Wrap(err, "new handler failed")
+	}
+	h.signingKey = g.server.signingKey
+	h.closing = true
+	msg, err := h.nextMessage()
+	if err != nil {
+		return nil, err
+	}
+	return &ChecksumResponse{Checksum: msg.Checksum, Signature: msg.Signature}, nil
+}
+
+// close removes the session, unless a transfer is using it.
+func (g *gateway) close(clientUUID uuid.UUID) error {
+	if err := g.server.expiry.clear(g.server.store, clientUUID); err != nil {
+		if errors.Is(err, session.ErrSessionNotFound) {
+			return status.Errorf(codes.NotFound, "session %s not found", clientUUID)
+		}
+		if errors.Is(err, ErrSessionInUse) {
+			return status.Errorf(codes.FailedPrecondition, "session %s is in use by a transfer", clientUUID)
+		}
+		return errors.Wrap(err, "clear session failed")
+	}
+	return nil
+}
+
+// session returns the session of the client, which must be a session of a sequence of fixed length.
+func (g *gateway) session(clientUUID uuid.UUID) (session.Session, error) {
+	sess, err := g.server.store.Get(clientUUID)
+	if err != nil {
+		if errors.Is(err, session.ErrSessionNotFound) {
+			return session.Session{}, status.Errorf(codes.NotFound, "session %s not found", clientUUID)
+		}
+		return session.Session{}, errors.Wrap(err, "get session failed")
+	}
+	if sess.Live != nil {
+		return session.Session{}, status.Errorf(codes.FailedPrecondition, "session %s is live, which is not served over HTTP", clientUUID)
+	}
+	return sess, nil
+}
+
+// queryUint parses the named query parameter, which must be at most max, or returns the default if it is not set.
+func queryUint(r *http.Request, name string, max, def int) (int, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.ParseUint(v, 10, 32)
+	if err != nil || n > uint64(max) {
+		return 0, status.Errorf(codes.InvalidArgument, "%s must be an integer from 0 to %d", name, max)
+	}
+	return int(n), nil
+}
+
+// gatewayStatus returns the HTTP status and the message of the error, from its gRPC status if it has one.
+// The message of an internal error is not returned, since it may reveal the internals of the server.
+func gatewayStatus(err error) (int, string) {
+	var grpcErr interface{ GRPCStatus() *status.Status }
+	if !errors.As(err, &grpcErr) {
+		return http.StatusInternalServerError, internalError
+	}
+	st := grpcErr.GRPCStatus()
+	switch st.Code() {
+	case codes.InvalidArgument, codes.OutOfRange:
+		return http.StatusBadRequest, st.Message()
+	case codes.NotFound:
+		return http.StatusNotFound, st.Message()
+	case codes.AlreadyExists, codes.FailedPrecondition:
+		return http.StatusConflict, st.Message()
+	case codes.Unimplemented:
+		return http.StatusMethodNotAllowed, st.Message()
+	}
+	return http.StatusInternalServerError, internalError
+}
+
+// writeJSON writes the response as JSON with the given status.
+func writeJSON(w http.ResponseWriter, code int, res interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(res)
+}
@@ -0,0 +1,248 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	risppb "risp/api/proto/gen/pb-go/github.com/mschristensen/risp/api/build/go"
+	"risp/internal/pkg/protocol"
+	"risp/internal/pkg/session"
+	"risp/internal/pkg/signature"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// request sends a request to the gateway, and decodes the response into res if it succeeds.
+func request(t *testing.T, method, url string, body, res interface{}) int {
+	t.Helper()
+	var b bytes.Buffer
+	if body != nil {
+		require.NoError(t, json.NewEncoder(&b).Encode(body))
+	}
+	req, err := http.NewRequest(method, url, &b)
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK && res != nil {
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(res))
+	}
+	return resp.StatusCode
+}
+
+// fetch resumes the session over a gRPC stream from the given ack, and returns the window of items it receives.
+func fetch(t *testing.T, addr string, clientUUID uuid.UUID, length, ack, window uint32) []uint32 {
+	t.Helper()
+	conn, err := grpc.Dial(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+	stream, err := risppb.NewRISPClient(conn).Connect(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, stream.Send(&risppb.ClientMessage{
+		State:   risppb.ConnectionState_CONNECTING,
+		Uuid:    clientUUID[:],
+		Len:     length,
+		Ack:     ack,
+		Window:  window,
+		Version: protocol.Version,
+	}))
+	reply, err := stream.Recv()
+	require.NoError(t, err)
+	require.Equal(t, length, reply.Len)
+	items := make([]uint32, window)
+	for i := range items {
+		msg, err := stream.Recv()
+		require.NoError(t, err)
+		require.Equal(t, ack+uint32(i), msg.Index)
+		items[i] = msg.Payload
+	}
+	return items
+}
+
+func TestGateway(t *testing.T) {
+	t.Parallel()
+	pub, key, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	srv, err := NewServer(WithSessionStore(session.NewMemoryStore()), WithSigningKey(key), WithTunables(Tunables{
+		Ticker:            time.Millisecond,
+		HeartbeatInterval: time.Second,
+		IdleTimeout:       time.Second,
+		LiveInterval:      time.Millisecond,
+	}))
+	require.NoError(t, err)
+	grpcServer := grpc.NewServer()
+	risppb.RegisterRISPServer(grpcServer, srv)
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() {
+		_ = grpcServer.Serve(lis)
+	}()
+	t.Cleanup(grpcServer.Stop)
+	gateway := httptest.NewServer(srv.Gateway())
+	t.Cleanup(gateway.Close)
+	sessions := gateway.URL + "/v1/sessions"
+
+	// a session started over HTTP
+	var started StartResponse
+	require.Equal(t, http.StatusOK, request(t, http.MethodPost, sessions, &StartRequest{Len: 20}, &started))
+	require.Equal(t, uint32(20), started.Len)
+	clientUUID, err := uuid.Parse(started.UUID)
+	require.NoError(t, err)
+	var window WindowResponse
+	require.Equal(t, http.StatusOK, request(t, http.MethodGet, fmt.Sprintf("%s/%s/items?ack=0&window=8", sessions, clientUUID), nil, &window))
+	require.Equal(t, uint32(0), window.Index)
+	require.Len(t, window.Items, 8)
+	items := window.Items
+
+	// is resumed over gRPC
+	items = append(items, fetch(t, lis.Addr().String(), clientUUID, 20, 8, 4)...)
+	sess, err := srv.store.Get(clientUUID)
+	require.NoError(t, err)
+	require.Equal(t, uint16(8), sess.Ack)
+
+	// and over HTTP again, past the end of the sequence
+	require.Equal(t, http.StatusOK, request(t, http.MethodGet, fmt.Sprintf("%s/%s/items?ack=12", sessions, clientUUID), nil, &window))
+	require.Equal(t, uint32(12), window.Index)
+	require.Len(t, window.Items, 8)
+	items = append(items, window.Items...)
+	for i, item := range items {
+		require.Equal(t, *sess.Sequence[i], item)
+	}
+
+	// the checksum is only sent once every item is acknowledged
+	var sum ChecksumResponse
+	require.Equal(t, http.StatusConflict, request(t, http.MethodGet, fmt.Sprintf("%s/%s/checksum?ack=19", sessions, clientUUID), nil, &sum))
+	require.Equal(t, http.StatusOK, request(t, http.MethodGet, fmt.Sprintf("%s/%s/checksum?ack=20", sessions, clientUUID), nil, &sum))
+	var expected uint64
+	for _, item := range items {
+		expected += uint64(item)
+	}
+	require.Equal(t, expected, sum.Checksum)
+	require.True(t, signature.Verify(pub, signature.Statement{UUID: clientUUID, Len: 20, Checksum: expected}, sum.Signature))
+
+	// the session is closed once the server has seen the end of the gRPC transfer
+	require.Eventually(t, func() bool {
+		return request(t, http.MethodDelete, fmt.Sprintf("%s/%s", sessions, clientUUID), nil, nil) == http.StatusNoContent
+	}, time.Second, 10*time.Millisecond)
+	require.Equal(t, http.StatusNotFound, request(t, http.MethodGet, fmt.Sprintf("%s/%s/items", sessions, clientUUID), nil, nil))
+	require.Equal(t, http.StatusNotFound, request(t, http.MethodDelete, fmt.Sprintf("%s/%s", sessions, clientUUID), nil, nil))
+
+	// a session is resumed with the same rules as over gRPC
+	require.Equal(t, http.StatusNotFound, request(t, http.MethodPost, sessions, &StartRequest{UUID: clientUUID.String(), Len: 20, Ack: 4}, nil))
+	require.Equal(t, http.StatusOK, request(t, http.MethodPost, sessions, &StartRequest{UUID: clientUUID.String(), Len: 20}, nil))
+	require.Equal(t, http.StatusConflict, request(t, http.MethodPost, sessions, &StartRequest{UUID: clientUUID.String(), Len: 10}, nil))
+	require.Equal(t, http.StatusNotFound, request(t, http.MethodPost, sessions, &StartRequest{Name: "primes"}, nil))
+
+	// and requests the gateway cannot serve are rejected
+	require.Equal(t, http.StatusBadRequest, request(t, http.MethodPost, sessions, &StartRequest{}, nil))
+	require.Equal(t, http.StatusBadRequest, request(t, http.MethodGet, fmt.Sprintf("%s/%s/items?ack=21", sessions, clientUUID), nil, nil))
+	require.Equal(t, http.StatusBadRequest, request(t, http.MethodGet, fmt.Sprintf("%s/%s/items?window=257", sessions, clientUUID), nil, nil))
+	require.Equal(t, http.StatusBadRequest, request(t, http.MethodGet, sessions+"/not-a-uuid/items", nil, nil))
+	require.Equal(t, http.StatusMethodNotAllowed, request(t, http.MethodGet, sessions, nil, nil))
+	require.Equal(t, http.StatusMethodNotAllowed, request(t, http.MethodPost, fmt.Sprintf("%s/%s/items", sessions, clientUUID), nil, nil))
+	require.Equal(t, http.StatusNotFound, request(t, http.MethodGet, fmt.Sprintf("%s/%s/other", sessions, clientUUID), nil, nil))
+
+	// with the method each path allows instead
+	for path, allow := range map[string]string{
+		sessions: http.MethodPost,
+		fmt.Sprintf("%s/%s", sessions, clientUUID):          http.MethodDelete,
+		fmt.Sprintf("%s/%s/items", sessions, clientUUID):    http.MethodGet,
+		fmt.Sprintf("%s/%s/checksum", sessions, clientUUID): http.MethodGet,
+	} {
+		req, err := http.NewRequest(http.MethodPut, path, nil)
+		require.NoError(t, err)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode, path)
+		require.Equal(t, allow, resp.Header.Get("Allow"), path)
+	}
+}
+
+func TestGatewayCloseInUse(t *testing.T) {
+	t.Parallel()
+	srv, err := NewServer(WithSessionStore(session.NewMemoryStore()), WithTunables(Tunables{
+		Ticker:            time.Millisecond,
+		HeartbeatInterval: time.Second,
+		IdleTimeout:       time.Minute,
+		LiveInterval:      time.Millisecond,
+	}))
+	require.NoError(t, err)
+	grpcServer := grpc.NewServer()
+	risppb.RegisterRISPServer(grpcServer, srv)
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() {
+		_ = grpcServer.Serve(lis)
+	}()
+	t.Cleanup(grpcServer.Stop)
+	gateway := httptest.NewServer(srv.Gateway())
+	t.Cleanup(gateway.Close)
+
+	// a session in use by a transfer over gRPC is not closed under it
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream, err := risppb.NewRISPClient(conn).Connect(ctx)
+	require.NoError(t, err)
+	clientUUID := uuid.New()
+	require.NoError(t, stream.Send(&risppb.ClientMessage{
+		State:   risppb.ConnectionState_CONNECTING,
+		Uuid:    clientUUID[:],
+		Len:     10,
+		Version: protocol.Version,
+	}))
+	_, err = stream.Recv()
+	require.NoError(t, err)
+	path := fmt.Sprintf("%s/v1/sessions/%s", gateway.URL, clientUUID)
+	require.Equal(t, http.StatusConflict, request(t, http.MethodDelete, path, nil, nil))
+	_, err = srv.store.Get(clientUUID)
+	require.NoError(t, err)
+
+	// but once the transfer has ended
+	cancel()
+	require.Eventually(t, func() bool {
+		return request(t, http.MethodDelete, path, nil, nil) == http.StatusNoContent
+	}, time.Second, 10*time.Millisecond)
+}
+
+// failingStore is a session store whose backend is unavailable.
+type failingStore struct {
+	session.Store
+}
+
+func (failingStore) Get(uuid.UUID) (session.Session, error) {
+	return session.Session{}, errors.New("dial redis 10.0.0.7:6379 failed")
+}
+
+func TestGatewayInternalError(t *testing.T) {
+	t.Parallel()
+	srv, err := NewServer(WithSessionStore(failingStore{}))
+	require.NoError(t, err)
+	gateway := httptest.NewServer(srv.Gateway())
+	t.Cleanup(gateway.Close)
+
+	// the error is logged, but not revealed to the client
+	resp, err := http.Post(gateway.URL+"/v1/sessions", "application/json", strings.NewReader(`{"len": 10}`))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	var res ErrorResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&res))
+	require.Equal(t, "internal error", res.Error)
+}
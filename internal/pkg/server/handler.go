@@ -1,52 +1,110 @@
 package server
 
 import (
-	"context"
-	"time"
+	"crypto/ed25519"
 
 	risppb "risp/api/proto/gen/pb-go/github.com/mschristensen/risp/api/build/go"
-	"risp/internal"
-	"risp/internal/pkg/log"
+	"risp/internal/pkg/batch"
+	"risp/internal/pkg/protocol"
 	"risp/internal/pkg/session"
+	"risp/internal/pkg/signature"
 	"risp/pkg/checksum"
 
 	"github.com/google/uuid"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 var logger logrus.FieldLogger = logrus.StandardLogger()
 
-// Handler implements RISP for a specific client.
+// Handler implements RISP for a specific transfer to a client.
 type Handler struct {
+	transferID uint32
 	clientUUID uuid.UUID
 	store      session.Store
-	session    session.Session // current session state
+	session    session.Session    // current session state
+	features   protocol.Features  // features negotiated with the client
+	encoding   risppb.Encoding    // the encoding of the batches of items negotiated with the client, if any
+	logger     logrus.FieldLogger // carries the context of the session
+	signingKey ed25519.PrivateKey // signs the checksum, if set
+
+	// the range of the sequence fetched by the transfer, if rangeEnd is nonzero
+	rangeStart uint16
+	rangeEnd   uint16
+
+	next uint32 // the index of the next item to send, if the session is live
 
 	closing bool
 	done    bool
 }
 
-// NewHandler creates a new handler.
-func NewHandler(clientUUID uuid.UUID, store session.Store) *Handler {
+// NewHandler creates a new handler for the given transfer, using the protocol features negotiated with the client.
+// The handler state is initialised with the stored client session state.
+func NewHandler(transferID uint32, clientUUID uuid.UUID, store session.Store, features protocol.Features) (*Handler, error) {
+	sess, err := store.Get(clientUUID)
+	if err != nil {
+		return nil, errors.Wrap(err, "get session failed")
+	}
+	sess.Live = sess.Live.Clone()
 	return &Handler{
+		transferID: transferID,
 		clientUUID: clientUUID,
 		store:      store,
+		session:    sess,
+		features:   features,
+		logger:     logger.WithField("uuid", clientUUID.String()),
+	}, nil
+}
+
+// limit restricts the transfer to the given range of the sequence, starting from the given ack and window.
+// The position of a ranged transfer is tracked by the handler alone, since the session is shared by the
+// transfers of all the ranges.
+func (h *Handler) limit(start, end, ack, window uint16) {
+	h.rangeStart = start
+	h.rangeEnd = end
+	h.session.Ack = ack
+	h.session.Window = window
+}
+
+// end returns the index after the last item to send to the client.
+func (h *Handler) end() uint16 {
+	if h.rangeEnd != 0 {
+		return h.rangeEnd
 	}
+	return uint16(len(h.session.Sequence))
 }
 
 // handleMessage updates the server state based on the client message.
 func (h *Handler) handleMessage(msg *risppb.ClientMessage) error {
+	if h.session.Live != nil {
+		return h.handleLiveMessage(msg)
+	}
 	switch msg.State {
 	case risppb.ConnectionState_CONNECTING, risppb.ConnectionState_CONNECTED:
+		// the next item sent is the one at the ack, so it cannot be beyond the end
+		if msg.Ack > uint32(h.end()) {
+			return status.Errorf(codes.InvalidArgument, "ack %d beyond the end %d", msg.Ack, h.end())
+		}
+		if h.rangeEnd != 0 && uint16(msg.Ack) < h.rangeStart {
+			return status.Errorf(codes.InvalidArgument, "ack %d outside range %d-%d", msg.Ack, h.rangeStart, h.rangeEnd)
+		}
 		// update session state according to the client message
 		h.session.Ack = uint16(msg.Ack)
 		h.session.Window = uint16(msg.Window)
+		if h.rangeEnd != 0 {
+			return nil
+		}
 		if err := h.store.Set(h.clientUUID, h.session); err != nil {
 			return errors.Wrap(err, "set session failed")
 		}
 		return nil
 	case risppb.ConnectionState_CLOSING:
+		// the sequence is verified once, by the transfer that completes it
+		if h.rangeEnd != 0 {
+			return errors.New("closing handshake on a ranged transfer")
+		}
 		h.closing = true
 		return nil
 	case risppb.ConnectionState_CLOSED:
@@ -62,15 +120,23 @@ func (h *Handler) handleMessage(msg *risppb.ClientMessage) error {
 // nextMessage prepares the next message to send to the client based on the current handler state.
 func (h *Handler) nextMessage() (*risppb.ServerMessage, error) {
 	msg := &risppb.ServerMessage{
-		State: risppb.ConnectionState_CONNECTED,
+		State:      risppb.ConnectionState_CONNECTED,
+		TransferId: h.transferID,
 	}
 	if h.done {
 		msg.State = risppb.ConnectionState_CLOSED
+		// the session outlives a ranged transfer, since the sequence is yet to be verified
+		if h.rangeEnd != 0 {
+			return msg, nil
+		}
 		if err := h.store.Clear(h.clientUUID); err != nil {
 			return nil, errors.Wrap(err, "clear session failed")
 		}
 		return msg, nil
 	}
+	if h.session.Live != nil {
+		return h.nextLiveMessage(msg)
+	}
 	if h.closing {
 		msg.State = risppb.ConnectionState_CLOSING
 		sum, err := checksum.Sum(h.session.Sequence...)
@@ -78,59 +144,68 @@ func (h *Handler) nextMessage() (*risppb.ServerMessage, error) {
 			return nil, errors.Wrap(err, "checksum failed")
 		}
 		msg.Checksum = sum
+		h.sign(msg, uint32(len(h.session.Sequence)))
 		return msg, nil
 	}
 
 	// stop sending messages if we have sent all the messages
 	// or if we have exhausted the window size
-	if h.session.Ack == uint16(len(h.session.Sequence)) || h.session.Window == 0 {
+	if h.session.Ack == h.end() || h.session.Window == 0 {
 		return nil, nil
 	}
 
 	msg.Index = uint32(h.session.Ack)
+	if h.encoding != risppb.Encoding_NONE {
+		return h.batch(msg)
+	}
 	msg.Payload = *h.session.Sequence[h.session.Ack]
 
 	return msg, nil
 }
 
-// Run runs the handler.
-func (h *Handler) Run(ctx context.Context, in <-chan *risppb.ClientMessage, out chan<- *risppb.ServerMessage) error {
-	defer close(out)
-	ticker := time.NewTicker(time.Duration(internal.ServerTickerMS) * time.Millisecond)
+// sign signs the checksum in the closing message, of the items before the given end, if the server has a signing key.
+func (h *Handler) sign(msg *risppb.ServerMessage, end uint32) {
+	if h.signingKey == nil {
+		return
+	}
+	msg.Signature = signature.Sign(h.signingKey, signature.Statement{
+		UUID:     h.clientUUID,
+		Name:     h.session.Name,
+		Live:     h.session.Live != nil,
+		Len:      end,
+		Checksum: msg.Checksum,
+	})
+}
 
-	// initialise the handler state with the stored client session state
-	sess, err := h.store.Get(h.clientUUID)
+// batch fills the message with the rest of the window, encoded with the negotiated encoding.
+func (h *Handler) batch(msg *risppb.ServerMessage) (*risppb.ServerMessage, error) {
+	end := h.session.Ack + h.session.Window
+	if end > h.end() || end < h.session.Ack {
+		end = h.end()
+	}
+	items, err := batch.Encode(h.encoding, h.session.Sequence[h.session.Ack:end].ToUint32Slice())
 	if err != nil {
-		return errors.Wrap(err, "get session failed")
+		return nil, errors.Wrap(err, "encode batch failed")
 	}
-	h.session = sess
+	msg.Encoding = h.encoding
+	msg.Items = items
+	msg.Count = uint32(end - h.session.Ack)
+	return msg, nil
+}
 
-	for {
-		select {
-		case <-ctx.Done():
-			return nil
-		case msg, ok := <-in:
-			if !ok || msg == nil {
-				return nil
-			}
-			logger.WithFields(log.ClientMessageToFields(msg)).Info("received message")
-			if err := h.handleMessage(msg); err != nil {
-				return errors.Wrap(err, "handle message failed")
-			}
-		case <-ticker.C:
-			msg, err := h.nextMessage()
-			if err != nil {
-				return errors.Wrap(err, "next message failed")
-			}
-			if msg != nil {
-				out <- msg
-				logger.WithFields(log.ServerMessageToFields(msg)).Info("sent message")
-				if msg.State == risppb.ConnectionState_CLOSED {
-					return nil
-				}
-				h.session.Window--
-				h.session.Ack++
-			}
+// sent updates the handler state after the message was sent to the client.
+func (h *Handler) sent(msg *risppb.ServerMessage) {
+	if msg.State == risppb.ConnectionState_CONNECTED {
+		if msg.Count > 0 {
+			h.session.Window -= uint16(msg.Count)
+			h.session.Ack += uint16(msg.Count)
+			return
+		}
+		h.session.Window--
+		if h.session.Live != nil {
+			h.next++
+			return
 		}
+		h.session.Ack++
 	}
 }
@@ -0,0 +1,120 @@
+package server
+
+import (
+	"io"
+
+	risppb "risp/api/proto/gen/pb-go/github.com/mschristensen/risp/api/build/go"
+	"risp/internal/pkg/session"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// handleLiveMessage updates the state of a live transfer based on the client message.
+func (h *Handler) handleLiveMessage(msg *risppb.ClientMessage) error {
+	switch msg.State {
+	case risppb.ConnectionState_CONNECTING, risppb.ConnectionState_CONNECTED:
+		if err := h.acknowledge(msg.Ack); err != nil {
+			return err
+		}
+		h.session.Window = uint16(msg.Window)
+		return h.save()
+	case risppb.ConnectionState_CLOSING:
+		if err := h.acknowledge(msg.Ack); err != nil {
+			return err
+		}
+		// the client stops at the last item it received, unless the stream already ends before it
+		l := h.session.Live
+		if l.End == nil || msg.Ack < *l.End {
+			end := msg.Ack
+			l.End = &end
+		}
+		return h.save()
+	case risppb.ConnectionState_CLOSED:
+		h.done = true
+		return nil
+	}
+	return errors.New("unhandled state")
+}
+
+// acknowledge releases the items acknowledged by the client from the replay buffer,
+// and resumes sending items from the first item the client has not received.
+func (h *Handler) acknowledge(ack uint32) error {
+	if err := h.session.Live.Release(ack); err != nil {
+		return liveStatus(err, ack)
+	}
+	h.next = ack
+	return nil
+}
+
+// nextLiveMessage prepares the next message of a live transfer.
+// The server closes the stream once the source ends, or the client asks to close it.
+func (h *Handler) nextLiveMessage(msg *risppb.ServerMessage) (*risppb.ServerMessage, error) {
+	l := h.session.Live
+	if l.End == nil {
+		if err := h.produce(); err != nil {
+			return nil, errors.Wrap(err, "produce failed")
+		}
+	}
+	end := l.Head()
+	if l.End != nil {
+		end = *l.End
+	}
+	if h.next < end && h.session.Window > 0 {
+		item, err := l.Item(h.next)
+		if err != nil {
+			return nil, liveStatus(err, h.next)
+		}
+		msg.Index = h.next
+		msg.Payload = item
+		return msg, nil
+	}
+	if l.End == nil {
+		return nil, nil
+	}
+	// the closing message tells the client where the stream ends, and the checksum of the items before it
+	sum, err := l.Sum(end)
+	if err != nil {
+		return nil, liveStatus(err, end)
+	}
+	msg.State = risppb.ConnectionState_CLOSING
+	msg.Index = end
+	msg.Checksum = sum
+	h.sign(msg, end)
+	return msg, nil
+}
+
+// produce polls the source for new items and adds them to the replay buffer.
+// When the source ends, the stream is closed after its last item.
+func (h *Handler) produce() error {
+	l := h.session.Live
+	items, err := l.Source.Poll()
+	if err != nil && !errors.Is(err, io.EOF) {
+		return errors.Wrap(err, "poll source failed")
+	}
+	l.Produce(items...)
+	if err != nil {
+		end := l.Head()
+		l.End = &end
+		h.logger.WithField("end", end).Info("live source ended, closing stream")
+	} else if len(items) == 0 {
+		return nil
+	}
+	return h.save()
+}
+
+// save stores a copy of the live session state, so that the handler can keep updating its own state.
+func (h *Handler) save() error {
+	sess := h.session
+	sess.Live = h.session.Live.Clone()
+	return errors.Wrap(h.store.Set(h.clientUUID, sess), "set session failed")
+}
+
+// liveStatus converts an error from the replay buffer to a status error for the client.
+func liveStatus(err error, index uint32) error {
+	if errors.Is(err, session.ErrItemUnavailable) {
+		return status.Errorf(codes.DataLoss, "item %d was evicted from the replay buffer", index)
+	}
+	return status.Errorf(codes.InvalidArgument, "item %d: %s", index, err)
+}
@@ -0,0 +1,276 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	risppb "risp/api/proto/gen/pb-go/github.com/mschristensen/risp/api/build/go"
+	"risp/internal/pkg/batch"
+	"risp/internal/pkg/catalog"
+	"risp/internal/pkg/session"
+	"risp/internal/pkg/transcript"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+)
+
+// replayStream stands in for the stream of a replayed conn, keeping the messages the conn sends until they are
+// checked against the transcript.
+type replayStream struct {
+	grpc.ServerStream
+	sent []*risppb.ServerMessage
+}
+
+// Send implements risppb.RISP_ConnectServer.
+func (s *replayStream) Send(msg *risppb.ServerMessage) error {
+	s.sent = append(s.sent, msg)
+	return nil
+}
+
+// Recv implements risppb.RISP_ConnectServer. The messages of a replayed conn are passed to it directly.
+func (s *replayStream) Recv() (*risppb.ClientMessage, error) {
+	return nil, io.EOF
+}
+
+// Context implements grpc.ServerStream.
+func (s *replayStream) Context() context.Context {
+	return context.Background()
+}
+
+// take removes and returns the first message sent for the transfer, or nil if there is none.
+func (s *replayStream) take(transferID uint32) *risppb.ServerMessage {
+	for i, msg := range s.sent {
+		if msg.TransferId == transferID {
+			s.sent = append(s.sent[:i], s.sent[i+1:]...)
+			return msg
+		}
+	}
+	return nil
+}
+
+// replayStore creates the sessions of a replay with the sequences recorded in the transcript,
+// instead of the random or named sequences the server would create them with.
+type replayStore struct {
+	session.Store
+	sequences map[uuid.UUID]session.Sequence
+}
+
+// New implements session.Store.
+func (s *replayStore) New(clientUUID uuid.UUID, name string, sequence session.Sequence) error {
+	if recorded, ok := s.sequences[clientUUID]; ok {
+		sequence = recorded
+	}
+	return s.Store.New(clientUUID, name, sequence)
+}
+
+// replayConn is a conn re-driven with the messages of a stream in the transcript.
+type replayConn struct {
+	*conn
+	stream *replayStream
+	failed error // the error with which the conn rejected a message
+}
+
+// Replay re-drives the server with the messages it received in a transcript recorded by a server, in the order
+// in which they were handled, and checks that every message it sends on each stream is the message it sent when
+// the transcript was recorded, and that it rejects exactly the messages it rejected then.
+// It returns a *transcript.Divergence if the replay departs from the transcript.
+//
+// The sessions are created with the sequences recorded in the transcript. The items that were never sent are
+// unknown, and replayed as zero, as is every item of a session created before the transcript was recorded that was
+// not sent while it was recorded. Live sessions cannot be replayed, since their items are produced over time.
+func Replay(entries []*risppb.TranscriptEntry) error {
+	sessions, err := recorded(entries)
+	if err != nil {
+		return err
+	}
+	s, err := NewServer(WithSessionStore(sessions.store), WithCatalog(sessions.catalog))
+	if err != nil {
+		return errors.Wrap(err, "new server failed")
+	}
+	conns := make(map[uint64]*replayConn)
+	for i, entry := range entries {
+		c, ok := conns[entry.Stream]
+		if !ok {
+			stream := &replayStream{}
+			c = &replayConn{
+				conn:   newConn(s, entry.Stream, stream, logger.WithField("stream", entry.Stream)),
+				stream: stream,
+			}
+			conns[entry.Stream] = c
+		}
+		end, err := c.step(i, entry)
+		if err != nil {
+			return err
+		}
+		if end {
+			delete(conns, entry.Stream)
+		}
+	}
+	// a conn that rejected a message must have recorded its error
+	for _, c := range conns {
+		if c.failed != nil {
+			return &transcript.Divergence{Entry: len(entries), Want: "the end of the transcript", Got: fmt.Sprintf("error %q", c.failed)}
+		}
+	}
+	return nil
+}
+
+// step replays the ith entry of the transcript on the conn, and returns true if the stream ended.
+func (c *replayConn) step(i int, entry *risppb.TranscriptEntry) (bool, error) {
+	if c.failed != nil {
+		// the recorded server rejected the same message if the next entry on the stream is its error
+		if entry.GetError() == "" || entry.Direction != risppb.Direction_SENT {
+			return false, &transcript.Divergence{Entry: i, Want: transcript.Describe(entry), Got: fmt.Sprintf("error %q", c.failed)}
+		}
+		return true, nil
+	}
+	switch event := entry.Event.(type) {
+	case *risppb.TranscriptEntry_Client:
+		msg := event.Client
+		if msg.Heartbeat {
+			return false, nil
+		}
+		if msg.State == risppb.ConnectionState_CONNECTING && msg.Live {
+			return false, errors.Errorf("entry %d: replaying a live stream is not supported", i)
+		}
+		// the messages sent on a tick are recorded before the next message is received
+		if len(c.stream.sent) > 0 {
+			return false, &transcript.Divergence{Entry: i, Want: transcript.Describe(entry), Got: fmt.Sprintf("sent %s", c.stream.sent[0])}
+		}
+		if err := c.handleMessage(msg); err != nil {
+			c.failed = err
+		}
+		return false, nil
+	case *risppb.TranscriptEntry_Server:
+		want := event.Server
+		if want.Heartbeat {
+			return false, nil
+		}
+		// the messages sent on a tick are compared in turn, and the next tick is run once they are used up
+		got := c.stream.take(want.TransferId)
+		if got == nil {
+			if _, err := c.tick(); err != nil {
+				return false, &transcript.Divergence{Entry: i, Want: transcript.Describe(entry), Got: fmt.Sprintf("error %q", err)}
+			}
+			got = c.stream.take(want.TransferId)
+		}
+		if got == nil {
+			return false, &transcript.Divergence{Entry: i, Want: transcript.Describe(entry), Got: "no message"}
+		}
+		// the signing key is not recorded, so the signatures of the checksums are not replayed
+		if len(want.Signature) > 0 {
+			want = proto.Clone(want).(*risppb.ServerMessage)
+			want.Signature = nil
+		}
+		if !proto.Equal(got, want) {
+			return false, &transcript.Divergence{Entry: i, Want: transcript.Describe(entry), Got: fmt.Sprintf("sent %s", got)}
+		}
+		return false, nil
+	case *risppb.TranscriptEntry_Error:
+		if entry.Direction == risppb.Direction_SENT {
+			return false, &transcript.Divergence{Entry: i, Want: transcript.Describe(entry), Got: "no error"}
+		}
+		return true, nil
+	}
+	return false, errors.Errorf("entry %d: no event", i)
+}
+
+// replaySessions are the sessions recorded in a transcript.
+type replaySessions struct {
+	store   *replayStore
+	catalog *catalog.Catalog
+}
+
+// recorded reconstructs the sessions of the transcript from the handshakes and the items sent. The session of a client
+// that resumed a transfer in its first recorded handshake is created before the replay, since it predates the transcript.
+func recorded(entries []*risppb.TranscriptEntry) (*replaySessions, error) {
+	type transfer struct {
+		stream     uint64
+		transferID uint32
+	}
+	handshakes := make(map[transfer]*risppb.ClientMessage) // the last handshake of each transfer
+	opened := make(map[transfer]uuid.UUID)                 // the client of each transfer whose handshake was accepted
+	sequences := make(map[uuid.UUID]session.Sequence)
+	named := make(map[string]session.Sequence)
+	store := session.NewMemoryStore()
+	for _, entry := range entries {
+		if msg := entry.GetClient(); msg != nil && msg.State == risppb.ConnectionState_CONNECTING && !msg.Live {
+			handshakes[transfer{entry.Stream, msg.TransferId}] = msg
+			continue
+		}
+		msg := entry.GetServer()
+		if msg == nil || msg.Heartbeat {
+			continue
+		}
+		t := transfer{entry.Stream, msg.TransferId}
+		switch msg.State {
+		case risppb.ConnectionState_CONNECTING:
+			handshake, ok := handshakes[t]
+			if !ok {
+				continue
+			}
+			clientUUID, err := uuid.FromBytes(handshake.Uuid)
+			if err != nil {
+				return nil, errors.Wrap(err, "parse client UUID failed")
+			}
+			opened[t] = clientUUID
+			if _, ok := sequences[clientUUID]; ok {
+				continue
+			}
+			// the sessions of a named sequence share it
+			sequence, ok := named[handshake.Name]
+			if !ok {
+				sequence = make(session.Sequence, msg.Len)
+				if handshake.Name != "" {
+					named[handshake.Name] = sequence
+				}
+			}
+			sequences[clientUUID] = sequence
+			if handshake.Ack > handshake.RangeStart {
+				if err := store.New(clientUUID, handshake.Name, sequence); err != nil {
+					return nil, errors.Wrap(err, "new session failed")
+				}
+			}
+		case risppb.ConnectionState_CONNECTED:
+			clientUUID, ok := opened[t]
+			if !ok {
+				continue
+			}
+			items := []uint32{msg.Payload}
+			if msg.Count > 0 {
+				var err error
+				if items, err = batch.Decode(msg.Encoding, msg.Items, msg.Count); err != nil {
+					return nil, errors.Wrap(err, "decode batch failed")
+				}
+			}
+			sequence := sequences[clientUUID]
+			for i := range items {
+				if index := int(msg.Index) + i; index < len(sequence) {
+					sequence[index] = &items[i]
+				}
+			}
+		}
+	}
+	for _, sequence := range sequences {
+		for i := range sequence {
+			if sequence[i] == nil {
+				sequence[i] = new(uint32)
+			}
+		}
+	}
+	items := make(map[string][]uint32, len(named))
+	for name, sequence := range named {
+		items[name] = sequence.ToUint32Slice()
+	}
+	c, err := catalog.New(items)
+	if err != nil {
+		return nil, errors.Wrap(err, "new catalog failed")
+	}
+	return &replaySessions{
+		store:   &replayStore{Store: store, sequences: sequences},
+		catalog: c,
+	}, nil
+}
@@ -2,22 +2,51 @@ package server
 
 import (
 	"context"
-	"io"
+	"crypto/ed25519"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
 	risppb "risp/api/proto/gen/pb-go/github.com/mschristensen/risp/api/build/go"
-	"risp/internal/pkg/log"
+	"risp/internal"
+	"risp/internal/pkg/batch"
+	"risp/internal/pkg/catalog"
+	"risp/internal/pkg/protocol"
 	"risp/internal/pkg/session"
-	"sync"
+	"risp/internal/pkg/transcript"
+	"risp/internal/pkg/ws"
 
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
 
 	"github.com/google/uuid"
 	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// DefaultReplayBuffer is the default capacity of the replay buffer of a live session.
+const DefaultReplayBuffer = 1 << 12
+
+// DefaultLiveInterval is the default interval between the items of a live session.
+const DefaultLiveInterval = 100 * time.Millisecond
+
 // Server implements a gRPC server that handles client connections.
 type Server struct {
-	store session.Store
+	streams      uint64 // the number of streams opened, used to identify each stream in the logs
+	store        session.Store
+	catalog      *catalog.Catalog      // the named sequences served, if any
+	newSource    func() session.Source // creates the source of items for each live session
+	replayBuffer int
+	recorder     *transcript.Recorder // records the messages of every stream, if the server is recorded
+	signingKey   ed25519.PrivateKey   // signs the checksum of every sequence, if set
+	expiry       *expiry              // tracks the sessions in use, so that the others can expire
+
+	mu       sync.RWMutex
+	tunables Tunables
+	tuned    chan struct{} // closed when the tunables change
 }
 
 // Cfg configures a Server.
@@ -31,9 +60,71 @@ func WithSessionStore(store session.Store) Cfg {
 	}
 }
 
+// WithCatalog sets the catalog of named sequences served by the server.
+func WithCatalog(c *catalog.Catalog) Cfg {
+	return func(s *Server) error {
+		s.catalog = c
+		return nil
+	}
+}
+
+// WithLiveSource sets the function used to create the source of items for each live session.
+func WithLiveSource(newSource func() session.Source) Cfg {
+	return func(s *Server) error {
+		s.newSource = newSource
+		return nil
+	}
+}
+
+// WithReplayBuffer sets the maximum number of unacknowledged items kept for each live session.
+func WithReplayBuffer(size int) Cfg {
+	return func(s *Server) error {
+		if size < 1 {
+			return errors.New("replay buffer size must be positive")
+		}
+		s.replayBuffer = size
+		return nil
+	}
+}
+
+// WithRecorder records every message the server sends and receives to the transcript of the recorder.
+func WithRecorder(r *transcript.Recorder) Cfg {
+	return func(s *Server) error {
+		s.recorder = r
+		return nil
+	}
+}
+
+// WithSigningKey signs the checksum of every sequence with the given private key, so that a client holding the
+// matching public key can verify that the checksum was sent by the server.
+func WithSigningKey(key ed25519.PrivateKey) Cfg {
+	return func(s *Server) error {
+		if len(key) != ed25519.PrivateKeySize {
+			return errors.New("invalid Ed25519 private key")
+		}
+		s.signingKey = key
+		return nil
+	}
+}
+
 // NewServer creates a new Server with the given configuration.
 func NewServer(cfgs ...Cfg) (*Server, error) {
-	server := &Server{}
+	server := &Server{
+		replayBuffer: DefaultReplayBuffer,
+		tunables: Tunables{
+			Ticker:            internal.ServerTicker,
+			HeartbeatInterval: internal.HeartbeatInterval,
+			IdleTimeout:       internal.IdleTimeout,
+			LiveInterval:      DefaultLiveInterval,
+		},
+		tuned:  make(chan struct{}),
+		expiry: newExpiry(),
+	}
+	// the items of a live session are produced according to the tunables when the session is created
+	server.newSource = func() session.Source {
+		t := server.Tunables()
+		return session.NewRandomSource(t.LiveInterval, t.LiveLimit)
+	}
 	for _, cfg := range cfgs {
 		if err := cfg(server); err != nil {
 			return nil, errors.Wrap(err, "apply Server cfg failed")
@@ -43,88 +134,218 @@ func NewServer(cfgs ...Cfg) (*Server, error) {
 }
 
 // Connect implements the gRPC endpoint for establishing a bidirectional stream connection.
+// Many transfers can be multiplexed over the stream if the client negotiates it.
 func (s *Server) Connect(srv risppb.RISP_ConnectServer) error {
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-	logger.Info("connecting")
+	fields := logrus.Fields{}
+	if p, ok := peer.FromContext(srv.Context()); ok {
+		fields["remote"] = p.Addr.String()
+	}
+	return s.serve(srv, fields)
+}
 
-	// first, we expect to receive a client handshake with the clientUUID
-	// and expected sequence length
-	msg, err := srv.Recv()
-	if err != nil {
-		return errors.Wrap(err, "receive client handshake failed")
+// WebSocket returns an HTTP handler serving the same streams as Connect over WebSockets, at ws.Path, for clients
+// behind proxies that block the HTTP/2 streams of gRPC.
+func (s *Server) WebSocket() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle(ws.Path, ws.Handler(func(srv *ws.ServerStream) error {
+		return s.serve(srv, logrus.Fields{
+			"remote":    srv.RemoteAddr(),
+			"transport": "websocket",
+		})
+	}))
+	return mux
+}
+
+// serve runs the transfers over the stream, whichever transport carries it, logging with the given fields.
+func (s *Server) serve(srv risppb.RISP_ConnectServer, fields logrus.Fields) error {
+	stream := atomic.AddUint64(&s.streams, 1)
+	fields["stream"] = stream
+	// the trace of the client transfer is propagated in the stream metadata, if the client is traced
+	if sc := trace.SpanContextFromContext(srv.Context()); sc.IsValid() {
+		fields["trace_id"] = sc.TraceID().String()
+	}
+	l := logger.WithFields(fields)
+	l.Info("connecting")
+	return newConn(s, stream, srv, l).run()
+}
+
+// ListSequences implements the gRPC endpoint for listing the named sequences served by the server.
+func (s *Server) ListSequences(context.Context, *risppb.ListSequencesRequest) (*risppb.ListSequencesResponse, error) {
+	res := &risppb.ListSequencesResponse{}
+	if s.catalog == nil {
+		return res, nil
 	}
-	if msg.State != risppb.ConnectionState_CONNECTING {
-		return errors.New("client handshake must be CONNECTING")
+	for _, entry := range s.catalog.List() {
+		res.Sequences = append(res.Sequences, &risppb.SequenceInfo{
+			Name:     entry.Name,
+			Len:      uint32(entry.Len),
+			Checksum: entry.Checksum,
+		})
 	}
+	return res, nil
+}
+
+// named returns the sequence requested by name in the client handshake.
+func (s *Server) named(msg *risppb.ClientMessage) (session.Sequence, error) {
+	if msg.Live {
+		return nil, status.Error(codes.InvalidArgument, "a live stream cannot be requested by name")
+	}
+	var sequence session.Sequence
+	var ok bool
+	if s.catalog != nil {
+		sequence, ok = s.catalog.Get(msg.Name)
+	}
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "sequence %q not found", msg.Name)
+	}
+	// a client that already knows the length, e.g. to fetch the sequence in ranges, must agree with the server
+	if msg.Len != 0 && int(msg.Len) != len(sequence) {
+		return nil, status.Errorf(codes.FailedPrecondition, "sequence length mismatch: sequence %q has %d, client requested %d", msg.Name, len(sequence), msg.Len)
+	}
+	return sequence, nil
+}
+
+// open handles a client handshake, loading the existing session state for the client or creating new session
+// state if none exists, and returns a handler for the transfer together with the handshake reply.
+// The handler logs with the given stream logger, in the context of the client session, and accesses the session
+// state through the given store, which traces its operations as part of the stream.
+func (s *Server) open(store session.Store, msg *risppb.ClientMessage, l logrus.FieldLogger) (*Handler, *risppb.ServerMessage, error) {
 	clientUUID, err := uuid.FromBytes(msg.Uuid)
 	if err != nil {
-		return errors.Wrap(err, "parse client UUID failed")
+		return nil, nil, status.Errorf(codes.InvalidArgument, "parse client UUID failed: %s", err)
+	}
+	features, err := protocol.Negotiate(msg.Version, protocol.Features(msg.Features))
+	if err != nil {
+		return nil, nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	if msg.TransferId != 0 && !features.Has(protocol.Multiplex) {
+		return nil, nil, status.Error(codes.InvalidArgument, "transfer ID set without negotiating multiplexing")
+	}
+	if msg.Live {
+		if !features.Has(protocol.Live) {
+			return nil, nil, status.Error(codes.InvalidArgument, "live stream requested without negotiating live streams")
+		}
+		if msg.RangeEnd != 0 {
+			return nil, nil, status.Error(codes.InvalidArgument, "a live stream cannot be fetched in ranges")
+		}
+	}
+	length := msg.Len
+	var named session.Sequence
+	if msg.Name != "" {
+		if named, err = s.named(msg); err != nil {
+			return nil, nil, err
+		}
+		length = uint32(len(named))
+	}
+	if !msg.Live && msg.Ack > length {
+		return nil, nil, status.Errorf(codes.InvalidArgument, "ack %d beyond the end %d", msg.Ack, length)
+	}
+	ranged := msg.RangeEnd != 0
+	if ranged {
+		if !features.Has(protocol.Ranges) {
+			return nil, nil, status.Error(codes.InvalidArgument, "range set without negotiating ranges")
+		}
+		if msg.RangeStart >= msg.RangeEnd || msg.RangeEnd > length || msg.Ack < msg.RangeStart || msg.Ack > msg.RangeEnd {
+			return nil, nil, status.Errorf(codes.InvalidArgument, "invalid range %d-%d with ack %d for length %d", msg.RangeStart, msg.RangeEnd, msg.Ack, length)
+		}
+	}
+
+	l = l.WithField("uuid", clientUUID.String())
+	if msg.TransferId != 0 {
+		l = l.WithField("transfer_id", msg.TransferId)
 	}
-	logger.WithFields(log.ClientMessageToFields(msg)).Info("received message")
+
+	// the session is in use by the transfer from now on, so that it cannot expire while it is loaded
+	s.acquire(clientUUID)
+	opened := false
+	defer func() {
+		if !opened {
+			s.release(clientUUID)
+		}
+	}()
 
 	// load existing session state for client, or create new session state if none exists
-	sess, err := s.store.Get(clientUUID)
+	sess, err := store.Get(clientUUID)
 	if err != nil {
 		if !errors.Is(err, session.ErrSessionNotFound) {
-			return errors.Wrap(err, "get session failed")
+			return nil, nil, errors.Wrap(err, "get session failed")
+		}
+		// a client resuming a transfer from a checkpoint must not be served a different sequence
+		if msg.Ack > msg.RangeStart {
+			return nil, nil, status.Errorf(codes.NotFound, "session %s not found, cannot resume from ack %d", clientUUID, msg.Ack)
+		}
+		l.Info("welcoming a brand new client")
+		// a named sequence is shared by reference between the sessions of all the clients requesting it,
+		// whereas every other client is served its own random sequence
+		sequence := named
+		if sequence == nil && !msg.Live {
+			sequence = session.NewRandomSequence(uint16(msg.Len))
 		}
-		logger.WithField("uuid", clientUUID.String()).Info("welcoming a brand new client")
-		if err := s.store.New(clientUUID, uint16(msg.Len)); err != nil {
-			return errors.Wrap(err, "new session failed")
+		err = store.New(clientUUID, msg.Name, sequence)
+		// the transfers of the other ranges of the sequence may have raced to create the session
+		if err != nil && !errors.Is(err, session.ErrSessionAlreadyExists) {
+			return nil, nil, errors.Wrap(err, "new session failed")
 		}
-		sess, err = s.store.Get(clientUUID)
+		sess, err = store.Get(clientUUID)
 		if err != nil {
-			return errors.Wrap(err, "get session after creating it failed")
+			return nil, nil, errors.Wrap(err, "get session after creating it failed")
+		}
+		if msg.Live && sess.Live == nil {
+			sess.Live = session.NewLive(s.newSource(), s.replayBuffer)
+			if err = store.Set(clientUUID, sess); err != nil {
+				return nil, nil, errors.Wrap(err, "set session failed")
+			}
 		}
 	} else {
-		logger.WithField("uuid", clientUUID.String()).Info("welcoming back an old client")
+		l.Info("welcoming back an old client")
 	}
 
+	if msg.Live != (sess.Live != nil) || msg.Name != sess.Name {
+		return nil, nil, status.Errorf(codes.FailedPrecondition, "session %s is not for the requested sequence", clientUUID)
+	}
 	// if the client is reconnecting, the sequence length must match the expected sequence length
-	if len(sess.Sequence) != int(msg.Len) {
-		return errors.New("sequence length mismatch")
+	if len(sess.Sequence) != int(length) {
+		return nil, nil, status.Errorf(codes.FailedPrecondition, "sequence length mismatch: session has %d, client requested %d", len(sess.Sequence), length)
 	}
 
-	// update the session state according to what this client knows
-	sess.Ack = uint16(msg.Ack)
-	sess.Window = uint16(msg.Window)
-	if err = s.store.Set(clientUUID, sess); err != nil {
-		return errors.Wrap(err, "set session failed")
+	// update the session state according to what this client knows, unless the client only fetches a range
+	// of the sequence, in which case the handler tracks its position, or the session is live, in which case
+	// the handler checks the ack against the replay buffer
+	if !ranged && !msg.Live {
+		sess.Ack = uint16(msg.Ack)
+		sess.Window = uint16(msg.Window)
+		if err = store.Set(clientUUID, sess); err != nil {
+			return nil, nil, errors.Wrap(err, "set session failed")
+		}
 	}
 
-	// create a new handler instance to manage messages on this connection
-	in := make(chan *risppb.ClientMessage)
-	out := make(chan *risppb.ServerMessage)
-	var wg sync.WaitGroup
-	wg.Add(2)
-	go func() {
-		defer wg.Done()
-		if err := NewHandler(clientUUID, s.store).Run(ctx, in, out); err != nil {
-			logger.Fatalln(err, "run handler failed")
-		}
-	}()
-	go func() {
-		defer close(in)
-		defer wg.Done()
-		for {
-			msg, err := srv.Recv()
-			if err != nil && (errors.Is(err, io.EOF) || status.Code(err) == codes.Canceled) {
-				logger.WithField("uuid", clientUUID).Warning("client disconnected")
-				return
-			}
-			if err != nil {
-				logger.Fatalln(errors.Wrap(err, "receive failed"))
-			}
-			in <- msg
-		}
-	}()
-	for msg := range out {
-		if err := srv.Send(msg); err != nil {
-			return errors.Wrap(err, "send message failed")
+	h, err := NewHandler(msg.TransferId, clientUUID, store, features)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "new handler failed")
+	}
+	h.logger = l
+	h.signingKey = s.signingKey
+	// the items of a live stream are produced over time, so they are not batched
+	if !msg.Live {
+		h.encoding = batch.Negotiate(msg.Encodings)
+	}
+	if ranged {
+		h.limit(uint16(msg.RangeStart), uint16(msg.RangeEnd), uint16(msg.Ack), uint16(msg.Window))
+	}
+	if msg.Live {
+		if err := h.handleMessage(msg); err != nil {
+			return nil, nil, err
 		}
 	}
-	wg.Wait()
-	logger.WithField("uuid", clientUUID).Info("disconnecting")
-	return nil
+	// confirm the negotiated protocol features and the sequence length before any data flows
+	reply := &risppb.ServerMessage{
+		State:      risppb.ConnectionState_CONNECTING,
+		Version:    protocol.Version,
+		Features:   uint64(features),
+		TransferId: msg.TransferId,
+		Len:        uint32(len(sess.Sequence)),
+		Encoding:   h.encoding,
+	}
+	opened = true
+	return h, reply, nil
 }
@@ -0,0 +1,61 @@
+package server
+
+import (
+	"context"
+
+	"risp/internal/pkg/session"
+	"risp/internal/pkg/tracing"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("risp/internal/pkg/server")
+
+// tracedStore traces every operation on the session store as a child of the span in its context,
+// which is the span of the stream the operations are made for.
+type tracedStore struct {
+	store session.Store
+	ctx   context.Context
+}
+
+// traced wraps the store so that its operations are traced in the given context.
+func traced(ctx context.Context, store session.Store) session.Store {
+	return &tracedStore{store: store, ctx: ctx}
+}
+
+// start starts the span of the named operation on the session of the given client.
+func (t *tracedStore) start(operation string, clientUUID uuid.UUID) trace.Span {
+	_, span := tracer.Start(t.ctx, "session.Store/"+operation, trace.WithAttributes(
+		attribute.String("uuid", clientUUID.String()),
+	))
+	return span
+}
+
+func (t *tracedStore) New(clientUUID uuid.UUID, name string, sequence session.Sequence) (err error) {
+	span := t.start("New", clientUUID)
+	defer func() { tracing.End(span, err) }()
+	span.SetAttributes(attribute.String("name", name), attribute.Int("len", len(sequence)))
+	return t.store.New(clientUUID, name, sequence)
+}
+
+func (t *tracedStore) Get(clientUUID uuid.UUID) (sess session.Session, err error) {
+	span := t.start("Get", clientUUID)
+	defer func() { tracing.End(span, err) }()
+	return t.store.Get(clientUUID)
+}
+
+func (t *tracedStore) Set(clientUUID uuid.UUID, sess session.Session) (err error) {
+	span := t.start("Set", clientUUID)
+	defer func() { tracing.End(span, err) }()
+	span.SetAttributes(attribute.Int("ack", int(sess.Ack)))
+	return t.store.Set(clientUUID, sess)
+}
+
+func (t *tracedStore) Clear(clientUUID uuid.UUID) (err error) {
+	span := t.start("Clear", clientUUID)
+	defer func() { tracing.End(span, err) }()
+	return t.store.Clear(clientUUID)
+}
@@ -0,0 +1,16 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"risp/internal/pkg/session"
+	"risp/internal/pkg/session/storetest"
+)
+
+func TestTracedStore(t *testing.T) {
+	t.Parallel()
+	storetest.Run(t, func(*testing.T) session.Store {
+		return traced(context.Background(), session.NewMemoryStore())
+	})
+}
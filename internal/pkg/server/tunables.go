@@ -0,0 +1,68 @@
+package server
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Tunables are the settings of a server that are safe to change while it is running.
+type Tunables struct {
+	Ticker            time.Duration // the interval between the messages sent for each transfer
+	HeartbeatInterval time.Duration // the interval between heartbeats, if negotiated
+	IdleTimeout       time.Duration // the time without a message from the client after which a stream is torn down
+	LiveInterval      time.Duration // the interval between the items of a live session
+	LiveLimit         uint32        // the number of items after which a live session ends, or 0 for no limit
+	SessionTTL        time.Duration // the time after which a session no transfer uses is cleared, or 0 for never
+}
+
+// validate checks the tunables are usable.
+func (t Tunables) validate() error {
+	if t.Ticker <= 0 || t.HeartbeatInterval <= 0 || t.IdleTimeout <= 0 || t.LiveInterval <= 0 {
+		return errors.New("tunable intervals must be positive")
+	}
+	if t.SessionTTL < 0 {
+		return errors.New("session TTL must not be negative")
+	}
+	return nil
+}
+
+// WithTunables sets the initial tunables of the server.
+func WithTunables(t Tunables) Cfg {
+	return func(s *Server) error {
+		if err := t.validate(); err != nil {
+			return err
+		}
+		s.tunables = t
+		return nil
+	}
+}
+
+// Tune replaces the tunables of the running server at once. Every open stream applies the new pacing,
+// heartbeat interval and idle timeout to its transfers straight away, and the session TTL applies to every idle
+// session, whereas the live settings apply to the live sessions created afterwards, since the source of an
+// existing session is already running.
+func (s *Server) Tune(t Tunables) error {
+	if err := t.validate(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tunables = t
+	close(s.tuned)
+	s.tuned = make(chan struct{})
+	return nil
+}
+
+// Tunables returns the current tunables of the server.
+func (s *Server) Tunables() Tunables {
+	t, _ := s.watchTunables()
+	return t
+}
+
+// watchTunables returns the current tunables of the server, and a channel that is closed when they change.
+func (s *Server) watchTunables() (Tunables, <-chan struct{}) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.tunables, s.tuned
+}
@@ -7,3 +7,9 @@ var ErrSessionNotFound = errors.New("session not found")
 
 // ErrSessionAlreadyExists indicates that the session already exists for this uuid.
 var ErrSessionAlreadyExists = errors.New("session already exists")
+
+// ErrItemUnavailable indicates that an item of a live session was evicted from the replay buffer.
+var ErrItemUnavailable = errors.New("item no longer available")
+
+// ErrAckOutOfRange indicates that an index refers to an item of a live session that has not been produced.
+var ErrAckOutOfRange = errors.New("index out of range")
@@ -0,0 +1,99 @@
+package session
+
+import (
+	"risp/pkg/checksum"
+)
+
+// Live captures the state of a live session, whose items are produced over time by a Source
+// and whose length is unbounded.
+//
+// The items are kept in a bounded replay buffer until the client acknowledges them, so that they can be sent
+// again when messages are lost or the client reconnects. If the client falls too far behind, the oldest items
+// are evicted from the buffer before they are acknowledged, and can no longer be sent.
+type Live struct {
+	Source   Source
+	Capacity int              // the maximum number of items in the buffer
+	Base     uint32           // the index of the first item in the buffer
+	Buffer   []uint32         // the items from Base, which the client may not have received yet
+	Checksum checksum.Running // the running checksum of the items before Base
+	End      *uint32          // the index after the last item, once the stream is closing
+}
+
+// NewLive creates the state of a new live session, with items produced by the source
+// and a replay buffer of the given capacity.
+func NewLive(source Source, capacity int) *Live {
+	return &Live{
+		Source:   source,
+		Capacity: capacity,
+	}
+}
+
+// Clone returns a copy of the live session state that shares the source.
+func (l *Live) Clone() *Live {
+	if l == nil {
+		return nil
+	}
+	clone := *l
+	clone.Buffer = append([]uint32(nil), l.Buffer...)
+	if l.End != nil {
+		end := *l.End
+		clone.End = &end
+	}
+	return &clone
+}
+
+// Head returns the index after the last item produced.
+func (l *Live) Head() uint32 {
+	return l.Base + uint32(len(l.Buffer))
+}
+
+// Produce appends the items to the buffer, evicting the oldest items beyond its capacity.
+func (l *Live) Produce(items ...uint32) {
+	l.Buffer = append(l.Buffer, items...)
+	if n := len(l.Buffer) - l.Capacity; n > 0 {
+		l.drop(n)
+	}
+}
+
+// Release drops the items before the given index from the buffer, once the client has acknowledged them.
+func (l *Live) Release(ack uint32) error {
+	if ack < l.Base {
+		return ErrItemUnavailable
+	}
+	if ack > l.Head() {
+		return ErrAckOutOfRange
+	}
+	l.drop(int(ack - l.Base))
+	return nil
+}
+
+// drop removes the first n items from the buffer, adding them to the running checksum.
+func (l *Live) drop(n int) {
+	l.Checksum.Add(l.Buffer[:n]...)
+	l.Buffer = l.Buffer[n:]
+	l.Base += uint32(n)
+}
+
+// Item returns the item at the given index.
+func (l *Live) Item(index uint32) (uint32, error) {
+	if index < l.Base {
+		return 0, ErrItemUnavailable
+	}
+	if index >= l.Head() {
+		return 0, ErrAckOutOfRange
+	}
+	return l.Buffer[index-l.Base], nil
+}
+
+// Sum returns the checksum of the items before the given index.
+func (l *Live) Sum(end uint32) (uint64, error) {
+	if end < l.Base {
+		return 0, ErrItemUnavailable
+	}
+	if end > l.Head() {
+		return 0, ErrAckOutOfRange
+	}
+	sum := l.Checksum
+	sum.Add(l.Buffer[:end-l.Base]...)
+	return uint64(sum), nil
+}
@@ -0,0 +1,38 @@
+package session
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLive(t *testing.T) {
+	t.Parallel()
+	l := NewLive(nil, 4)
+	l.Produce(1, 2, 3)
+	require.Equal(t, uint32(3), l.Head())
+
+	require.NoError(t, l.Release(1))
+	item, err := l.Item(1)
+	require.NoError(t, err)
+	require.Equal(t, uint32(2), item)
+	sum, err := l.Sum(3)
+	require.NoError(t, err)
+	require.Equal(t, uint64(6), sum)
+
+	// the buffer evicts the oldest items beyond its capacity
+	l.Produce(4, 5, 6, 7)
+	require.Equal(t, uint32(3), l.Base)
+	_, err = l.Item(2)
+	require.ErrorIs(t, err, ErrItemUnavailable)
+	require.ErrorIs(t, l.Release(1), ErrItemUnavailable)
+	require.ErrorIs(t, l.Release(8), ErrAckOutOfRange)
+	sum, err = l.Sum(7)
+	require.NoError(t, err)
+	require.Equal(t, uint64(28), sum)
+
+	// a clone does not share the buffer
+	clone := l.Clone()
+	require.NoError(t, clone.Release(7))
+	require.Equal(t, uint32(3), l.Base)
+}
@@ -2,12 +2,27 @@ package session
 
 import (
 	"fmt"
+	"math/rand"
 	"strings"
+	"time"
 )
 
 // Sequence is the sequence of numbers to transmit using the RISP protocol.
+//
+// On the server, a sequence is immutable once created, so that it can be shared by the sessions of many clients.
 type Sequence []*uint32
 
+// NewRandomSequence creates a sequence of the given length with random values.
+func NewRandomSequence(length uint16) Sequence {
+	r := rand.New(rand.NewSource(time.Now().UnixNano())) // nolint: gosec // we don't need high security here
+	s := make(Sequence, length)
+	for i := range s {
+		x := r.Uint32()
+		s[i] = &x
+	}
+	return s
+}
+
 func (s Sequence) String() string {
 	arr := make([]string, len(s))
 	for i := range s {
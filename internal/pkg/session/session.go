@@ -2,26 +2,27 @@
 package session
 
 import (
-	"math/rand"
 	"sync"
-	"time"
 
 	"github.com/google/uuid"
 )
 
 // Store provides an API for perforing CRUD operations on client state.
 type Store interface {
-	New(clientUUID uuid.UUID, sequenceLength uint16) error
+	New(clientUUID uuid.UUID, name string, sequence Sequence) error
 	Get(clientUUID uuid.UUID) (Session, error)
 	Set(clientUUID uuid.UUID, session Session) error
 	Clear(clientUUID uuid.UUID) error
 }
 
 // Session captures the current session state of a client.
+// The sequence is a reference to an immutable sequence, which is shared by every session for the same named sequence.
 type Session struct {
-	Sequence Sequence
+	Name     string   // the name of the sequence, if it is a named sequence
+	Sequence Sequence // the shared sequence, which must not be modified
 	Ack      uint16
 	Window   uint16
+	Live     *Live // the state of a live session, which has no fixed sequence
 }
 
 // MemoryStore is a in-memory implementation of Store.
@@ -37,21 +38,17 @@ func NewMemoryStore() *MemoryStore {
 	}
 }
 
-// New creates a new session state for the given client uuid,
-// and initialises the sequence with the given length with random values.
-func (p *MemoryStore) New(clientUUID uuid.UUID, sequenceLength uint16) error {
+// New creates a new session state for the given client uuid, referencing the given sequence,
+// which is not copied. The name is empty unless the sequence is a named sequence.
+func (p *MemoryStore) New(clientUUID uuid.UUID, name string, sequence Sequence) error {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 	if _, ok := p.sessions[clientUUID]; ok {
 		return ErrSessionAlreadyExists
 	}
 	p.sessions[clientUUID] = Session{
-		Sequence: make([]*uint32, sequenceLength),
-	}
-	r := rand.New(rand.NewSource(time.Now().Unix())) // nolint: gosec // we don't need high security here
-	for i := uint16(0); i < sequenceLength; i++ {
-		x := r.Uint32()
-		p.sessions[clientUUID].Sequence[i] = &x
+		Name:     name,
+		Sequence: sequence,
 	}
 	return nil
 }
@@ -0,0 +1,48 @@
+package session
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStoreShared(t *testing.T) {
+	t.Parallel()
+	store := NewMemoryStore()
+	sequence := Uint32SliceToSequence([]uint32{1, 2, 3})
+	a, b := uuid.New(), uuid.New()
+	require.NoError(t, store.New(a, "abc", sequence))
+	require.NoError(t, store.New(b, "abc", sequence))
+	require.ErrorIs(t, store.New(a, "abc", sequence), ErrSessionAlreadyExists)
+
+	sessA, err := store.Get(a)
+	require.NoError(t, err)
+	sessA.Ack, sessA.Window = 2, 4
+	require.NoError(t, store.Set(a, sessA))
+
+	// the sessions reference the same sequence, but track their progress independently
+	sessA, err = store.Get(a)
+	require.NoError(t, err)
+	sessB, err := store.Get(b)
+	require.NoError(t, err)
+	require.Same(t, sessA.Sequence[0], sessB.Sequence[0])
+	require.Equal(t, "abc", sessB.Name)
+	require.Equal(t, uint16(2), sessA.Ack)
+	require.Equal(t, uint16(0), sessB.Ack)
+
+	require.NoError(t, store.Clear(a))
+	_, err = store.Get(a)
+	require.ErrorIs(t, err, ErrSessionNotFound)
+	_, err = store.Get(b)
+	require.NoError(t, err)
+}
+
+func TestNewRandomSequence(t *testing.T) {
+	t.Parallel()
+	sequence := NewRandomSequence(100)
+	require.Len(t, sequence, 100)
+	for _, x := range sequence {
+		require.NotNil(t, x)
+	}
+}
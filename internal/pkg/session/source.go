@@ -0,0 +1,55 @@
+package session
+
+import (
+	"io"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Source produces the items of a live session over time.
+type Source interface {
+	// Poll returns the items produced since the last poll.
+	// Once the stream has ended and all its items have been returned, it returns io.EOF.
+	Poll() ([]uint32, error)
+}
+
+// RandomSource is a Source that produces a random item at regular intervals.
+// It is safe for concurrent use.
+type RandomSource struct {
+	interval time.Duration
+	limit    uint32 // the number of items after which the stream ends, or 0 for no limit
+	start    time.Time
+	produced uint32
+	r        *rand.Rand
+	mu       sync.Mutex
+}
+
+// NewRandomSource creates a new RandomSource producing an item every interval, and ending after limit items.
+// If limit is 0, the stream never ends.
+func NewRandomSource(interval time.Duration, limit uint32) *RandomSource {
+	return &RandomSource{
+		interval: interval,
+		limit:    limit,
+		start:    time.Now(),
+		r:        rand.New(rand.NewSource(time.Now().UnixNano())), // nolint: gosec // we don't need high security here
+	}
+}
+
+// Poll returns the items due since the last poll.
+func (s *RandomSource) Poll() ([]uint32, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.limit > 0 && s.produced == s.limit {
+		return nil, io.EOF
+	}
+	due := uint32(time.Since(s.start) / s.interval)
+	if s.limit > 0 && due > s.limit {
+		due = s.limit
+	}
+	var items []uint32
+	for ; s.produced < due; s.produced++ {
+		items = append(items, s.r.Uint32())
+	}
+	return items, nil
+}
@@ -0,0 +1,14 @@
+//go:build go1.18
+// +build go1.18
+
+package session_test
+
+import (
+	"testing"
+
+	"risp/internal/pkg/session/storetest"
+)
+
+func FuzzMemoryStore(f *testing.F) {
+	storetest.Fuzz(f, newMemoryStore)
+}
@@ -0,0 +1,17 @@
+package session_test
+
+import (
+	"testing"
+
+	"risp/internal/pkg/session"
+	"risp/internal/pkg/session/storetest"
+)
+
+func newMemoryStore(*testing.T) session.Store {
+	return session.NewMemoryStore()
+}
+
+func TestMemoryStore(t *testing.T) {
+	t.Parallel()
+	storetest.Run(t, newMemoryStore)
+}
@@ -0,0 +1,106 @@
+package storetest
+
+import (
+	"sync"
+	"testing"
+
+	"risp/internal/pkg/session"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+// parallel calls fn from each of the given number of goroutines, and requires none of the calls to fail.
+func parallel(t *testing.T, n int, fn func(i int) error) {
+	t.Helper()
+	errs := make(chan error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := fn(i); err != nil {
+				errs <- err
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.NoError(t, err)
+	}
+}
+
+func testConcurrentClients(t *testing.T, store session.Store) {
+	// each client runs its sessions through their whole lifecycle at the same time as the others
+	parallel(t, concurrency, func(i int) error {
+		id := uuid.New()
+		for k := 0; k < iterations; k++ {
+			if err := store.New(id, "", sequence(uint32(i), uint32(k))); err != nil {
+				return errors.Wrap(err, "new failed")
+			}
+			sess, err := store.Get(id)
+			if err != nil {
+				return errors.Wrap(err, "get failed")
+			}
+			if got := sess.Sequence.ToUint32Slice(); got[0] != uint32(i) || got[1] != uint32(k) {
+				return errors.Errorf("got the sequence %v of another session", got)
+			}
+			sess.Ack = 2
+			if err := store.Set(id, sess); err != nil {
+				return errors.Wrap(err, "set failed")
+			}
+			if err := store.Clear(id); err != nil {
+				return errors.Wrap(err, "clear failed")
+			}
+		}
+		return nil
+	})
+}
+
+func testConcurrentNew(t *testing.T, store session.Store) {
+	id := uuid.New()
+	var mu sync.Mutex
+	created := 0
+	// exactly one of the sessions created at once for the same client succeeds
+	parallel(t, concurrency, func(i int) error {
+		err := store.New(id, "", sequence(uint32(i)))
+		if errors.Is(err, session.ErrSessionAlreadyExists) {
+			return nil
+		}
+		if err != nil {
+			return errors.Wrap(err, "new failed")
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		created++
+		return nil
+	})
+	require.Equal(t, 1, created)
+}
+
+func testConcurrentSetGet(t *testing.T, store session.Store) {
+	id := uuid.New()
+	require.NoError(t, store.New(id, "", sequence(1, 2, 3)))
+	// every session set has an equal ack and window, so a reader seeing them differ has seen a torn write
+	parallel(t, concurrency, func(i int) error {
+		for k := 0; k < iterations; k++ {
+			if i%2 == 0 {
+				n := uint16(i*iterations + k)
+				if err := store.Set(id, session.Session{Sequence: sequence(1, 2, 3), Ack: n, Window: n}); err != nil {
+					return errors.Wrap(err, "set failed")
+				}
+				continue
+			}
+			sess, err := store.Get(id)
+			if err != nil {
+				return errors.Wrap(err, "get failed")
+			}
+			if sess.Ack != sess.Window {
+				return errors.Errorf("got a torn session with ack %d and window %d", sess.Ack, sess.Window)
+			}
+		}
+		return nil
+	})
+}
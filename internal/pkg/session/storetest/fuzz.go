@@ -0,0 +1,114 @@
+//go:build go1.18
+// +build go1.18
+
+package storetest
+
+import (
+	"testing"
+
+	"risp/internal/pkg/session"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+// fuzzClients is the number of clients the operations of the fuzz target are spread over,
+// which is small so that the operations often refer to the same session.
+const fuzzClients = 4
+
+// model is the expected state of a store, which is the state of the MemoryStore.
+type model map[uuid.UUID]session.Session
+
+// Fuzz fuzzes the store with arbitrary sequences of operations, requiring each to have the same outcome
+// as it has on a model of the MemoryStore. It is run with go test -fuzz, and otherwise runs the seed corpus:
+//
+//	func FuzzStore(f *testing.F) {
+//		storetest.Fuzz(f, func(t *testing.T) session.Store {
+//			return NewStore()
+//		})
+//	}
+//
+// Every three bytes of the input are an operation: its kind, the client it is made for and its argument.
+func Fuzz(f *testing.F, newStore NewStore) {
+	f.Add([]byte{0, 0, 3, 1, 0, 0, 2, 0, 1, 1, 0, 0, 3, 0, 0, 3, 0, 0})
+	f.Add([]byte{2, 1, 1, 3, 1, 0, 0, 1, 1, 0, 1, 2, 1, 1, 0})
+	f.Add([]byte{0, 0, 1, 0, 1, 2, 2, 1, 9, 3, 0, 0, 1, 1, 0, 2, 0, 4})
+	f.Fuzz(func(t *testing.T, ops []byte) {
+		store := newStore(t)
+		clients := make([]uuid.UUID, fuzzClients)
+		for i := range clients {
+			clients[i] = uuid.New()
+		}
+		want := make(model)
+		for i := 0; i+2 < len(ops); i += 3 {
+			kind, id, arg := ops[i]%4, clients[int(ops[i+1])%fuzzClients], ops[i+2]
+			switch kind {
+			case 0:
+				want.new(t, store, id, arg)
+			case 1:
+				want.get(t, store, id)
+			case 2:
+				want.set(t, store, id, arg)
+			case 3:
+				want.clear(t, store, id)
+			}
+		}
+		for _, id := range clients {
+			want.get(t, store, id)
+		}
+	})
+}
+
+// new creates a session with a sequence of the given length.
+func (m model) new(t *testing.T, store session.Store, id uuid.UUID, length byte) {
+	t.Helper()
+	items := make([]uint32, length%8)
+	for i := range items {
+		items[i] = uint32(length) + uint32(i)
+	}
+	err := store.New(id, "", sequence(items...))
+	if _, ok := m[id]; ok {
+		require.ErrorIs(t, err, session.ErrSessionAlreadyExists)
+		return
+	}
+	require.NoError(t, err)
+	m[id] = session.Session{Sequence: sequence(items...)}
+}
+
+// get requires the store to hold the expected session, if any.
+func (m model) get(t *testing.T, store session.Store, id uuid.UUID) {
+	t.Helper()
+	want, ok := m[id]
+	if !ok {
+		_, err := store.Get(id)
+		require.ErrorIs(t, err, session.ErrSessionNotFound)
+		return
+	}
+	requireSession(t, store, id, want)
+}
+
+// set sets the progress of the session from the given argument.
+func (m model) set(t *testing.T, store session.Store, id uuid.UUID, progress byte) {
+	t.Helper()
+	sess, ok := m[id]
+	sess.Ack, sess.Window = uint16(progress>>4), uint16(progress&0xf)
+	err := store.Set(id, sess)
+	if !ok {
+		require.ErrorIs(t, err, session.ErrSessionNotFound)
+		return
+	}
+	require.NoError(t, err)
+	m[id] = sess
+}
+
+// clear clears the session.
+func (m model) clear(t *testing.T, store session.Store, id uuid.UUID) {
+	t.Helper()
+	err := store.Clear(id)
+	if _, ok := m[id]; !ok {
+		require.ErrorIs(t, err, session.ErrSessionNotFound)
+		return
+	}
+	require.NoError(t, err)
+	delete(m, id)
+}
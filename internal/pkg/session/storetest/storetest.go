@@ -0,0 +1,176 @@
+// Package storetest implements a conformance test suite for session.Store implementations.
+//
+// An implementation proves it behaves like the MemoryStore by running the suite against itself:
+//
+//	func TestStore(t *testing.T) {
+//		storetest.Run(t, func(t *testing.T) session.Store {
+//			return NewStore()
+//		})
+//	}
+//
+// The suite includes concurrent tests, which should be run with -race, and a fuzz target is provided by Fuzz.
+// Errors are compared with errors.Is, so an implementation may wrap the errors of the session package.
+package storetest
+
+import (
+	"testing"
+
+	"risp/internal/pkg/session"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+// NewStore creates an empty store for a single test, releasing any resources it holds with t.Cleanup.
+type NewStore func(t *testing.T) session.Store
+
+// tests are the behavioural tests every store must pass, each run against an empty store.
+var tests = []struct {
+	name string
+	test func(t *testing.T, store session.Store)
+}{
+	{"new then get", testNewGet},
+	{"new existing", testNewExisting},
+	{"get missing", testGetMissing},
+	{"set missing", testSetMissing},
+	{"clear missing", testClearMissing},
+	{"set then get", testSetGet},
+	{"clear then new", testClearNew},
+	{"get copies", testGetCopies},
+	{"sessions are independent", testIndependent},
+	{"shared sequence", testSharedSequence},
+	{"concurrent clients", testConcurrentClients},
+	{"concurrent new", testConcurrentNew},
+	{"concurrent set and get", testConcurrentSetGet},
+}
+
+// Run runs the conformance suite, creating a new store for each test.
+func Run(t *testing.T, newStore NewStore) {
+	t.Helper()
+	for i := range tests {
+		tt := tests[i]
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			tt.test(t, newStore(t))
+		})
+	}
+}
+
+// sequence returns a sequence of the given items.
+func sequence(items ...uint32) session.Sequence {
+	return session.Uint32SliceToSequence(items)
+}
+
+// requireSession requires the store to hold the given session state for the client.
+// Sequences are compared by value, since a store need not keep the sequence it was given.
+func requireSession(t *testing.T, store session.Store, clientUUID uuid.UUID, want session.Session) {
+	t.Helper()
+	got, err := store.Get(clientUUID)
+	require.NoError(t, err)
+	require.Equal(t, want.Name, got.Name)
+	require.Equal(t, want.Sequence.ToUint32Slice(), got.Sequence.ToUint32Slice())
+	require.Equal(t, want.Ack, got.Ack)
+	require.Equal(t, want.Window, got.Window)
+}
+
+func testNewGet(t *testing.T, store session.Store) {
+	id := uuid.New()
+	require.NoError(t, store.New(id, "primes", sequence(2, 3, 5)))
+	// a new session has made no progress
+	requireSession(t, store, id, session.Session{Name: "primes", Sequence: sequence(2, 3, 5)})
+}
+
+func testNewExisting(t *testing.T, store session.Store) {
+	id := uuid.New()
+	require.NoError(t, store.New(id, "", sequence(1, 2)))
+	require.NoError(t, store.Set(id, session.Session{Sequence: sequence(1, 2), Ack: 1, Window: 1}))
+	require.ErrorIs(t, store.New(id, "other", sequence(3)), session.ErrSessionAlreadyExists)
+	// the existing session is left as it was
+	requireSession(t, store, id, session.Session{Sequence: sequence(1, 2), Ack: 1, Window: 1})
+}
+
+func testGetMissing(t *testing.T, store session.Store) {
+	_, err := store.Get(uuid.New())
+	require.ErrorIs(t, err, session.ErrSessionNotFound)
+}
+
+func testSetMissing(t *testing.T, store session.Store) {
+	id := uuid.New()
+	require.ErrorIs(t, store.Set(id, session.Session{Sequence: sequence(1), Ack: 1}), session.ErrSessionNotFound)
+	// a failed set does not create the session
+	_, err := store.Get(id)
+	require.ErrorIs(t, err, session.ErrSessionNotFound)
+}
+
+func testClearMissing(t *testing.T, store session.Store) {
+	require.ErrorIs(t, store.Clear(uuid.New()), session.ErrSessionNotFound)
+}
+
+func testSetGet(t *testing.T, store session.Store) {
+	id := uuid.New()
+	require.NoError(t, store.New(id, "", sequence(1, 2, 3)))
+	want := session.Session{Sequence: sequence(1, 2, 3), Ack: 2, Window: 4}
+	require.NoError(t, store.Set(id, want))
+	requireSession(t, store, id, want)
+	// the last set wins
+	want.Ack, want.Window = 3, 0
+	require.NoError(t, store.Set(id, want))
+	requireSession(t, store, id, want)
+}
+
+func testClearNew(t *testing.T, store session.Store) {
+	id := uuid.New()
+	require.NoError(t, store.New(id, "", sequence(1)))
+	require.NoError(t, store.Clear(id))
+	_, err := store.Get(id)
+	require.ErrorIs(t, err, session.ErrSessionNotFound)
+	require.ErrorIs(t, store.Clear(id), session.ErrSessionNotFound)
+	// the client can start a new session once the last one is cleared
+	require.NoError(t, store.New(id, "", sequence(4, 5)))
+	requireSession(t, store, id, session.Session{Sequence: sequence(4, 5)})
+}
+
+func testGetCopies(t *testing.T, store session.Store) {
+	id := uuid.New()
+	require.NoError(t, store.New(id, "", sequence(1, 2, 3)))
+	a, err := store.Get(id)
+	require.NoError(t, err)
+	b, err := store.Get(id)
+	require.NoError(t, err)
+	// changing a copy affects neither the store nor the other copies until it is set
+	a.Ack, a.Window, a.Name = 2, 1, "changed"
+	require.Equal(t, uint16(0), b.Ack)
+	require.Equal(t, "", b.Name)
+	requireSession(t, store, id, session.Session{Sequence: sequence(1, 2, 3)})
+	require.NoError(t, store.Set(id, a))
+	require.Equal(t, uint16(0), b.Ack)
+}
+
+func testIndependent(t *testing.T, store session.Store) {
+	a, b := uuid.New(), uuid.New()
+	require.NoError(t, store.New(a, "", sequence(1, 2)))
+	require.NoError(t, store.New(b, "", sequence(3, 4, 5)))
+	require.NoError(t, store.Set(a, session.Session{Sequence: sequence(1, 2), Ack: 2}))
+	requireSession(t, store, b, session.Session{Sequence: sequence(3, 4, 5)})
+	require.NoError(t, store.Clear(a))
+	requireSession(t, store, b, session.Session{Sequence: sequence(3, 4, 5)})
+}
+
+func testSharedSequence(t *testing.T, store session.Store) {
+	shared := sequence(7, 8, 9)
+	a, b := uuid.New(), uuid.New()
+	require.NoError(t, store.New(a, "shared", shared))
+	require.NoError(t, store.New(b, "shared", shared))
+	require.NoError(t, store.Set(a, session.Session{Name: "shared", Sequence: shared, Ack: 3}))
+	// the sessions of a named sequence track their progress through it independently
+	requireSession(t, store, a, session.Session{Name: "shared", Sequence: shared, Ack: 3})
+	requireSession(t, store, b, session.Session{Name: "shared", Sequence: shared})
+	// the sequence given to the store is not modified
+	require.Equal(t, []uint32{7, 8, 9}, shared.ToUint32Slice())
+}
+
+// concurrency is the number of goroutines of the concurrent tests.
+const concurrency = 16
+
+// iterations is the number of operations each goroutine of the concurrent tests makes.
+const iterations = 100
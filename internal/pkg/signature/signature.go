@@ -0,0 +1,117 @@
+// Package signature implements the Ed25519 signatures with which a RISP server vouches for the checksums it sends.
+//
+// The checksum in the closing message only shows that the items received match the checksum received, so a proxy
+// rewriting both goes unnoticed. A server holding a private key signs a Statement of the checksum together with the
+// session and the sequence it describes, and a client holding the matching public key verifies the signature before
+// trusting the checksum.
+//
+// Keys are read from PEM files, a private key in PKCS #8 form and a public key in PKIX form, such as those
+// generated by:
+//
+//	openssl genpkey -algorithm ed25519 -out server.key
+//	openssl pkey -in server.key -pubout -out server.pub
+package signature
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/pem"
+	"os"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+// domain separates the statements signed by a RISP server from anything else signed with the same key.
+const domain = "risp checksum v1\x00"
+
+// Statement is what the server signs when it sends the checksum of a sequence.
+type Statement struct {
+	UUID     uuid.UUID // the session of the client
+	Name     string    // the name of the sequence, if it was requested by name
+	Live     bool
+	Len      uint32 // the length of the sequence, or the index after the last item of a live stream
+	Checksum uint64
+}
+
+// bytes returns the encoding of the statement that is signed.
+func (s Statement) bytes() []byte {
+	b := make([]byte, 0, len(domain)+len(s.UUID)+1+4+8+len(s.Name))
+	b = append(b, domain...)
+	b = append(b, s.UUID[:]...)
+	if s.Live {
+		b = append(b, 1)
+	} else {
+		b = append(b, 0)
+	}
+	var n [8]byte
+	binary.BigEndian.PutUint32(n[:4], s.Len)
+	b = append(b, n[:4]...)
+	binary.BigEndian.PutUint64(n[:], s.Checksum)
+	b = append(b, n[:]...)
+	// the name is last, so its length is implied
+	return append(b, s.Name...)
+}
+
+// Sign signs the statement with the private key.
+func Sign(key ed25519.PrivateKey, s Statement) []byte {
+	return ed25519.Sign(key, s.bytes())
+}
+
+// Verify reports whether the signature of the statement is valid for the public key.
+func Verify(key ed25519.PublicKey, s Statement, sig []byte) bool {
+	return ed25519.Verify(key, s.bytes(), sig)
+}
+
+// LoadPrivateKey reads an Ed25519 private key from a PEM file in PKCS #8 form.
+func LoadPrivateKey(path string) (ed25519.PrivateKey, error) {
+	der, err := load(path, "PRIVATE KEY")
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parse private key %s failed", path)
+	}
+	ed, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, errors.Errorf("private key %s is not an Ed25519 key", path)
+	}
+	return ed, nil
+}
+
+// LoadPublicKey reads an Ed25519 public key from a PEM file in PKIX form.
+func LoadPublicKey(path string) (ed25519.PublicKey, error) {
+	der, err := load(path, "PUBLIC KEY")
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parse public key %s failed", path)
+	}
+	ed, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, errors.Errorf("public key %s is not an Ed25519 key", path)
+	}
+	return ed, nil
+}
+
+// load reads the first PEM block of the given type from the file.
+func load(path, typ string) ([]byte, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "read key failed")
+	}
+	for {
+		var block *pem.Block
+		block, b = pem.Decode(b)
+		if block == nil {
+			return nil, errors.Errorf("no %s found in %s", typ, path)
+		}
+		if block.Type == typ {
+			return block.Bytes, nil
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package signature
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerify(t *testing.T) {
+	t.Parallel()
+	pub, key, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	s := Statement{UUID: uuid.New(), Name: "primes", Len: 100, Checksum: 24133}
+	sig := Sign(key, s)
+	require.True(t, Verify(pub, s, sig))
+
+	// a signature only holds for the statement it was made for
+	for _, tampered := range []Statement{
+		{UUID: uuid.New(), Name: s.Name, Len: s.Len, Checksum: s.Checksum},
+		{UUID: s.UUID, Name: "evens", Len: s.Len, Checksum: s.Checksum},
+		{UUID: s.UUID, Name: s.Name, Live: true, Len: s.Len, Checksum: s.Checksum},
+		{UUID: s.UUID, Name: s.Name, Len: s.Len + 1, Checksum: s.Checksum},
+		{UUID: s.UUID, Name: s.Name, Len: s.Len, Checksum: s.Checksum + 1},
+	} {
+		require.False(t, Verify(pub, tampered, sig), tampered)
+	}
+	other, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	require.False(t, Verify(other, s, sig))
+	require.False(t, Verify(pub, s, nil))
+}
+
+func TestLoad(t *testing.T) {
+	t.Parallel()
+	pub, key, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	dir := t.TempDir()
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "server.key"), pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600))
+	der, err = x509.MarshalPKIXPublicKey(pub)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "server.pub"), pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o600))
+
+	loaded, err := LoadPrivateKey(filepath.Join(dir, "server.key"))
+	require.NoError(t, err)
+	require.True(t, key.Equal(loaded))
+	loadedPub, err := LoadPublicKey(filepath.Join(dir, "server.pub"))
+	require.NoError(t, err)
+	require.True(t, pub.Equal(loadedPub))
+
+	// each key is read from the file of its own kind
+	_, err = LoadPrivateKey(filepath.Join(dir, "server.pub"))
+	require.Error(t, err)
+	_, err = LoadPublicKey(filepath.Join(dir, "server.key"))
+	require.Error(t, err)
+	_, err = LoadPublicKey(filepath.Join(dir, "missing.pub"))
+	require.Error(t, err)
+}
@@ -0,0 +1,132 @@
+// Package tracing sets up OpenTelemetry tracing.
+//
+// Spans are exported to stdout, to a file, or to an OTLP collector over gRPC. The trace context is propagated
+// between the client and the server in the gRPC metadata, so that the spans of a server stream are part of the
+// trace of the client transfer that opened it. Until Setup installs an exporter, every span is a no-op.
+package tracing
+
+import (
+	"context"
+	"io"
+	"os"
+
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+)
+
+// The supported exporters.
+const (
+	// None disables tracing.
+	None = "none"
+	// Stdout writes each span to stdout as a line of JSON.
+	Stdout = "stdout"
+	// File writes each span to a file as a line of JSON.
+	File = "file"
+	// OTLP sends the spans to an OTLP collector over gRPC.
+	OTLP = "otlp"
+)
+
+// config is the configuration of the tracer provider.
+type config struct {
+	exporter sdktrace.SpanExporter
+	closer   io.Closer // closes the output of the exporter, if it needs closing
+}
+
+// Cfg configures the tracer provider.
+type Cfg func(context.Context, *config) error
+
+// WithExporter exports spans using the named exporter, which should be one of: none, stdout, file, otlp.
+// The spans are written to the file at the given path by the file exporter, and sent to the collector
+// at the given endpoint (host:port) by the otlp exporter.
+func WithExporter(name string, path string, endpoint string) Cfg {
+	return func(ctx context.Context, c *config) error {
+		var err error
+		switch name {
+		case "", None:
+			return nil
+		case Stdout:
+			c.exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+		case File:
+			var f *os.File
+			f, err = os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+			if err != nil {
+				return errors.Wrap(err, "open trace file failed")
+			}
+			c.closer = f
+			c.exporter, err = stdouttrace.New(stdouttrace.WithWriter(f))
+		case OTLP:
+			c.exporter, err = otlptracegrpc.New(ctx,
+				otlptracegrpc.WithEndpoint(endpoint),
+				otlptracegrpc.WithInsecure(), // the collector is expected to run locally
+			)
+		default:
+			return errors.Errorf("unknown trace exporter %q", name)
+		}
+		return errors.Wrapf(err, "create %s exporter failed", name)
+	}
+}
+
+// Setup installs the global tracer provider for the named service, and returns a function that
+// flushes the spans that have not been exported yet and shuts the tracer provider down.
+// If no exporter is configured, tracing stays disabled.
+func Setup(ctx context.Context, service string, cfgs ...Cfg) (func(context.Context) error, error) {
+	c := &config{}
+	for _, cfg := range cfgs {
+		if err := cfg(ctx, c); err != nil {
+			return nil, errors.Wrap(err, "apply tracing cfg failed")
+		}
+	}
+	if c.exporter == nil {
+		return func(context.Context) error { return nil }, nil
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(c.exporter),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceNameKey.String(service))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	return func(ctx context.Context) error {
+		if err := provider.Shutdown(ctx); err != nil {
+			return errors.Wrap(err, "shut down tracer provider failed")
+		}
+		if c.closer != nil {
+			return errors.Wrap(c.closer.Close(), "close trace output failed")
+		}
+		return nil
+	}, nil
+}
+
+// End ends the span, recording the error if it is not nil.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// DialOptions returns the options that trace the calls of a gRPC client, and propagate the trace context to the server.
+func DialOptions() []grpc.DialOption {
+	return []grpc.DialOption{
+		grpc.WithUnaryInterceptor(otelgrpc.UnaryClientInterceptor()),
+		grpc.WithStreamInterceptor(otelgrpc.StreamClientInterceptor()),
+	}
+}
+
+// ServerOptions returns the options that trace the calls handled by a gRPC server, as part of the trace of the client.
+func ServerOptions() []grpc.ServerOption {
+	return []grpc.ServerOption{
+		grpc.UnaryInterceptor(otelgrpc.UnaryServerInterceptor()),
+		grpc.StreamInterceptor(otelgrpc.StreamServerInterceptor()),
+	}
+}
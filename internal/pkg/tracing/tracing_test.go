@@ -0,0 +1,57 @@
+package tracing
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+)
+
+func TestSetupFile(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "trace.json")
+	shutdown, err := Setup(ctx, "test", WithExporter(File, path, ""))
+	require.NoError(t, err)
+	_, span := otel.Tracer("test").Start(ctx, "ok")
+	End(span, nil)
+	_, span = otel.Tracer("test").Start(ctx, "failed")
+	End(span, errors.New("failed"))
+	require.NoError(t, shutdown(ctx))
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	type stub struct {
+		Name   string
+		Status struct{ Code string }
+	}
+	var spans []stub
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var span stub
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &span))
+		spans = append(spans, span)
+	}
+	require.NoError(t, scanner.Err())
+	require.Len(t, spans, 2)
+	require.Equal(t, "ok", spans[0].Name)
+	require.Equal(t, "Unset", spans[0].Status.Code)
+	require.Equal(t, "failed", spans[1].Name)
+	require.Equal(t, "Error", spans[1].Status.Code)
+}
+
+func TestSetupNone(t *testing.T) {
+	t.Parallel()
+	shutdown, err := Setup(context.Background(), "test", WithExporter(None, "", ""))
+	require.NoError(t, err)
+	require.NoError(t, shutdown(context.Background()))
+
+	_, err = Setup(context.Background(), "test", WithExporter("unknown", "", ""))
+	require.Error(t, err)
+}
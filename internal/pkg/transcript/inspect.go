@@ -0,0 +1,524 @@
+package transcript
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	risppb "risp/api/proto/gen/pb-go/github.com/mschristensen/risp/api/build/go"
+	"risp/internal/pkg/batch"
+	"risp/pkg/checksum"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+// The results of checking the checksum the server sent a session against the items in the transcript.
+const (
+	ChecksumVerified     = "verified"     // the checksum is the sum of the items in the transcript
+	ChecksumMismatch     = "mismatch"     // the checksum is not the sum of the items in the transcript
+	ChecksumUnverifiable = "unverifiable" // some of the items were not sent while the transcript was recorded
+	ChecksumMissing      = "missing"      // the server never sent the checksum
+)
+
+// Report is the timeline of each client session in a transcript.
+type Report struct {
+	RecordedBy Side      `json:"recorded_by"`
+	Entries    int       `json:"entries"`
+	Sessions   []Session `json:"sessions"`
+}
+
+// Session is the timeline of a client session, across every stream on which the client connected. The sessions of
+// the transfers a client split into ranges are one session, since they share the client UUID.
+type Session struct {
+	UUID          string       `json:"uuid"`
+	Name          string       `json:"name,omitempty"`
+	Live          bool         `json:"live,omitempty"`
+	Len           uint32       `json:"len"`
+	State         string       `json:"state"` // the last state the session reached
+	Start         time.Time    `json:"start"`
+	Duration      float64      `json:"duration_seconds"`
+	Streams       int          `json:"streams"`
+	Acks          int          `json:"acks"`
+	ItemsSent     int          `json:"items_sent"`
+	Retransmitted []uint32     `json:"retransmitted"` // the indices of the items sent more than once
+	Windows       []WindowSize `json:"windows"`
+	Gaps          []Gap        `json:"reconnect_gaps"`
+	Checksum      Checksum     `json:"checksum"`
+	Events        []Event      `json:"events"`
+}
+
+// WindowSize is the size of a window requested by the client.
+type WindowSize struct {
+	Offset float64 `json:"offset_seconds"` // the time since the start of the session
+	Window uint32  `json:"window"`
+}
+
+// Gap is the time between a stream of a transfer ending and the client reconnecting on the next.
+type Gap struct {
+	Offset   float64 `json:"offset_seconds"` // the time since the start of the session at which the client reconnected
+	Stream   uint64  `json:"stream"`         // the stream on which the client reconnected
+	Duration float64 `json:"duration_seconds"`
+}
+
+// Checksum is the result of checking the checksum the server sent against the items in the transcript.
+type Checksum struct {
+	Result string `json:"result"`
+	Server uint64 `json:"server,omitempty"` // the checksum sent by the server
+	Sum    uint64 `json:"sum,omitempty"`    // the sum of the items in the transcript
+}
+
+// Event is a step of a session, which is a message sent by the client or the server, a run of consecutive
+// items sent by the server, an error ending a stream, or the client reconnecting.
+type Event struct {
+	Offset     float64 `json:"offset_seconds"` // the time since the start of the session
+	Stream     uint64  `json:"stream"`
+	TransferID uint32  `json:"transfer_id,omitempty"`
+	From       Side    `json:"from"`
+	State      string  `json:"state"`
+	Kind       string  `json:"kind"`
+	Detail     string  `json:"detail"`
+}
+
+// transfer identifies a transfer in a transcript, which is the transfer of the given ID on a stream.
+type transfer struct {
+	stream     uint64
+	transferID uint32
+}
+
+// lane identifies the transfers of a session that follow each other, which are those of a range of the sequence,
+// or of the whole sequence if the range is empty. A client reconnects by starting the next transfer of its lane.
+type lane struct {
+	rangeStart uint32
+	rangeEnd   uint32
+}
+
+// inspection accumulates the timeline of a session while the transcript is read.
+type inspection struct {
+	Session
+	start    int64
+	end      int64
+	streams  map[uint64]bool
+	lanes    map[lane]transfer     // the latest transfer of each lane
+	last     map[uint64]int64      // the time of the latest entry of the session on each stream
+	sent     map[uint32]int        // the number of times each item was sent
+	payloads map[uint32]uint32     // the latest payload of each item
+	run      *Event                // the run of items being sent, which the next consecutive item extends
+	runs     map[*Event][2]uint32  // the first and last index of each run of items
+	retries  map[*Event]int        // the number of retransmitted items in each run of items
+	closing  *risppb.ServerMessage // the latest CLOSING reply of the server
+	order    []*Event              // the events, in the order in which they happened
+}
+
+// Inspect builds the timeline of each client session in the transcript, in the order in which the sessions started.
+// Heartbeats are left out of the timelines.
+func Inspect(entries []*risppb.TranscriptEntry) (*Report, error) {
+	side, err := RecordedBy(entries)
+	if err != nil {
+		return nil, err
+	}
+	var sessions []*inspection
+	byUUID := make(map[string]*inspection)
+	transfers := make(map[transfer]*inspection)
+	streams := make(map[uint64][]*inspection) // the sessions with transfers on each stream
+	for _, entry := range entries {
+		switch event := entry.Event.(type) {
+		case *risppb.TranscriptEntry_Client:
+			msg := event.Client
+			id := clientUUID(msg.Uuid)
+			s, ok := byUUID[id]
+			if !ok {
+				s = newInspection(id, entry.Time)
+				byUUID[id] = s
+				sessions = append(sessions, s)
+			}
+			t := transfer{entry.Stream, msg.TransferId}
+			if transfers[t] != s {
+				transfers[t] = s
+				streams[entry.Stream] = append(streams[entry.Stream], s)
+			}
+			s.client(entry, t, msg)
+		case *risppb.TranscriptEntry_Server:
+			t := transfer{entry.Stream, event.Server.TransferId}
+			s, ok := transfers[t]
+			if !ok {
+				continue
+			}
+			s.server(entry, t, event.Server)
+		case *risppb.TranscriptEntry_Error:
+			// the error ends the stream, and so every transfer on it
+			from := side
+			if entry.Direction == risppb.Direction_RECEIVED {
+				from = side.other()
+			}
+			for _, s := range streams[entry.Stream] {
+				s.error(entry, from, event.Error)
+			}
+		}
+	}
+	report := &Report{
+		RecordedBy: side,
+		Entries:    len(entries),
+		Sessions:   make([]Session, len(sessions)),
+	}
+	for i, s := range sessions {
+		report.Sessions[i] = s.finish()
+	}
+	return report, nil
+}
+
+// other returns the other side of the protocol.
+func (s Side) other() Side {
+	if s == Client {
+		return Server
+	}
+	return Client
+}
+
+// clientUUID formats the UUID of a client, or the raw bytes if they are not a valid UUID.
+func clientUUID(b []byte) string {
+	id, err := uuid.FromBytes(b)
+	if err != nil {
+		return fmt.Sprintf("%x", b)
+	}
+	return id.String()
+}
+
+// newInspection starts the timeline of the session of the given client UUID.
+func newInspection(id string, start int64) *inspection {
+	return &inspection{
+		Session: Session{
+			UUID:  id,
+			Start: time.Unix(0, start),
+		},
+		start:    start,
+		streams:  make(map[uint64]bool),
+		lanes:    make(map[lane]transfer),
+		last:     make(map[uint64]int64),
+		sent:     make(map[uint32]int),
+		payloads: make(map[uint32]uint32),
+		runs:     make(map[*Event][2]uint32),
+		retries:  make(map[*Event]int),
+	}
+}
+
+// offset returns the time of the entry since the start of the session.
+func (s *inspection) offset(entry *risppb.TranscriptEntry) float64 {
+	return time.Duration(entry.Time - s.start).Seconds()
+}
+
+// add appends an event for the entry to the timeline.
+func (s *inspection) add(entry *risppb.TranscriptEntry, transferID uint32, from Side, state, kind, detail string) *Event {
+	s.end = entry.Time
+	s.streams[entry.Stream] = true
+	s.State = state
+	e := &Event{
+		Offset:     s.offset(entry),
+		Stream:     entry.Stream,
+		TransferID: transferID,
+		From:       from,
+		State:      state,
+		Kind:       kind,
+		Detail:     detail,
+	}
+	s.order = append(s.order, e)
+	s.run = nil
+	return e
+}
+
+// client adds a message sent by the client to the timeline.
+func (s *inspection) client(entry *risppb.TranscriptEntry, t transfer, msg *risppb.ClientMessage) {
+	if msg.Heartbeat {
+		s.touch(entry)
+		return
+	}
+	state := msg.State.String()
+	switch msg.State {
+	case risppb.ConnectionState_CONNECTING:
+		s.reconnect(entry, t, lane{msg.RangeStart, msg.RangeEnd})
+		if msg.Name != "" {
+			s.Name = msg.Name
+		}
+		s.Live = s.Live || msg.Live
+		if msg.Len > 0 && msg.RangeEnd == 0 {
+			s.Len = msg.Len
+		}
+		detail := []string{fmt.Sprintf("ack %d window %d", msg.Ack, msg.Window)}
+		if msg.Len > 0 {
+			detail = append(detail, fmt.Sprintf("len %d", msg.Len))
+		}
+		if msg.RangeEnd > 0 {
+			detail = append(detail, fmt.Sprintf("range %d-%d", msg.RangeStart, msg.RangeEnd))
+		}
+		if msg.Name != "" {
+			detail = append(detail, fmt.Sprintf("name %s", msg.Name))
+		}
+		if msg.Live {
+			detail = append(detail, "live")
+		}
+		if msg.Ack > msg.RangeStart {
+			detail = append(detail, "resumed")
+		}
+		s.add(entry, msg.TransferId, Client, state, "handshake", strings.Join(detail, " "))
+		s.window(entry, msg.Window)
+	case risppb.ConnectionState_CONNECTED:
+		s.Acks++
+		s.add(entry, msg.TransferId, Client, state, "ack", fmt.Sprintf("ack %d window %d", msg.Ack, msg.Window))
+		s.window(entry, msg.Window)
+	case risppb.ConnectionState_CLOSING:
+		s.add(entry, msg.TransferId, Client, state, "closing", fmt.Sprintf("ack %d", msg.Ack))
+	case risppb.ConnectionState_CLOSED:
+		s.add(entry, msg.TransferId, Client, state, "closed", "")
+	}
+	s.touch(entry)
+}
+
+// server adds a message sent by the server to the timeline.
+func (s *inspection) server(entry *risppb.TranscriptEntry, t transfer, msg *risppb.ServerMessage) {
+	defer s.touch(entry)
+	if msg.Heartbeat {
+		return
+	}
+	state := msg.State.String()
+	switch msg.State {
+	case risppb.ConnectionState_CONNECTING:
+		if msg.Len > 0 && !s.Live {
+			s.Len = msg.Len
+		}
+		detail := fmt.Sprintf("len %d", msg.Len)
+		if msg.Encoding != risppb.Encoding_NONE {
+			detail = fmt.Sprintf("%s encoding %s", detail, msg.Encoding)
+		}
+		s.add(entry, msg.TransferId, Server, state, "handshake reply", detail)
+	case risppb.ConnectionState_CONNECTED:
+		if msg.Count == 0 {
+			s.item(entry, msg, msg.Index, msg.Payload)
+			return
+		}
+		items, err := batch.Decode(msg.Encoding, msg.Items, msg.Count)
+		if err != nil {
+			s.add(entry, msg.TransferId, Server, state, "corrupt batch", err.Error())
+			return
+		}
+		for i, item := range items {
+			s.item(entry, msg, msg.Index+uint32(i), item)
+		}
+	case risppb.ConnectionState_CLOSING:
+		s.closing = msg
+		detail := fmt.Sprintf("checksum %d", msg.Checksum)
+		if s.Live {
+			detail = fmt.Sprintf("end %d checksum %d", msg.Index, msg.Checksum)
+		}
+		if len(msg.Signature) > 0 {
+			detail += " (signed)"
+		}
+		s.add(entry, msg.TransferId, Server, state, "closing reply", detail)
+	case risppb.ConnectionState_CLOSED:
+		s.add(entry, msg.TransferId, Server, state, "closed", "")
+	}
+}
+
+// item adds an item sent by the server in the message to the timeline, extending the current run of items if it
+// follows on from it. The items of a batch are added one by one.
+func (s *inspection) item(entry *risppb.TranscriptEntry, msg *risppb.ServerMessage, index, payload uint32) {
+	s.ItemsSent++
+	retransmitted := s.sent[index] > 0
+	s.sent[index]++
+	s.payloads[index] = payload
+	if run := s.run; run != nil && run.Stream == entry.Stream && run.TransferID == msg.TransferId && s.runs[run][1]+1 == index {
+		s.runs[run] = [2]uint32{s.runs[run][0], index}
+		if retransmitted {
+			s.retries[run]++
+		}
+		s.end = entry.Time
+		return
+	}
+	run := s.add(entry, msg.TransferId, Server, msg.State.String(), "items", "")
+	s.runs[run] = [2]uint32{index, index}
+	if retransmitted {
+		s.retries[run]++
+	}
+	s.run = run
+}
+
+// error adds the error that ended a stream to the timeline.
+func (s *inspection) error(entry *risppb.TranscriptEntry, from Side, err string) {
+	s.add(entry, 0, from, s.State, "error", err)
+	s.touch(entry)
+}
+
+// window records the size of a window requested by the client.
+func (s *inspection) window(entry *risppb.TranscriptEntry, window uint32) {
+	if window > 0 {
+		s.Windows = append(s.Windows, WindowSize{Offset: s.offset(entry), Window: window})
+	}
+}
+
+// touch records the time of the latest entry of the session on the stream of the entry.
+func (s *inspection) touch(entry *risppb.TranscriptEntry) {
+	s.last[entry.Stream] = entry.Time
+}
+
+// reconnect records the gap since the previous transfer of the lane if the handshake starts a new stream for it.
+func (s *inspection) reconnect(entry *risppb.TranscriptEntry, t transfer, l lane) {
+	previous, ok := s.lanes[l]
+	s.lanes[l] = t
+	if !ok || previous.stream == t.stream {
+		return
+	}
+	gap := time.Duration(entry.Time - s.last[previous.stream]).Seconds()
+	s.Gaps = append(s.Gaps, Gap{Offset: s.offset(entry), Stream: entry.Stream, Duration: gap})
+	s.add(entry, t.transferID, Client, s.State, "reconnect", fmt.Sprintf("after %.3fs", gap))
+}
+
+// finish completes the timeline of the session.
+func (s *inspection) finish() Session {
+	session := s.Session
+	session.Duration = time.Duration(s.end - s.start).Seconds()
+	session.Streams = len(s.streams)
+	session.Retransmitted = make([]uint32, 0)
+	for index, n := range s.sent {
+		if n > 1 {
+			session.Retransmitted = append(session.Retransmitted, index)
+		}
+	}
+	sort.Slice(session.Retransmitted, func(i, j int) bool {
+		return session.Retransmitted[i] < session.Retransmitted[j]
+	})
+	if session.Windows == nil {
+		session.Windows = make([]WindowSize, 0)
+	}
+	if session.Gaps == nil {
+		session.Gaps = make([]Gap, 0)
+	}
+	session.Checksum = s.checksum()
+	session.Events = make([]Event, len(s.order))
+	for i, e := range s.order {
+		if run, ok := s.runs[e]; ok {
+			e.Detail = fmt.Sprintf("items %d-%d", run[0], run[1])
+			if run[0] == run[1] {
+				e.Detail = fmt.Sprintf("item %d", run[0])
+			}
+			if n := s.retries[e]; n > 0 {
+				e.Detail = fmt.Sprintf("%s (%d retransmitted)", e.Detail, n)
+			}
+		}
+		session.Events[i] = *e
+	}
+	return session
+}
+
+// checksum checks the checksum the server sent against the sum of the items in the transcript, which covers
+// the whole sequence, or the items before the end of a live stream.
+func (s *inspection) checksum() Checksum {
+	if s.closing == nil {
+		return Checksum{Result: ChecksumMissing}
+	}
+	end := s.Len
+	if s.Live {
+		end = s.closing.Index
+	}
+	var sum checksum.Running
+	for i := uint32(0); i < end; i++ {
+		payload, ok := s.payloads[i]
+		if !ok {
+			return Checksum{Result: ChecksumUnverifiable, Server: s.closing.Checksum}
+		}
+		sum.Add(payload)
+	}
+	result := Checksum{Result: ChecksumVerified, Server: s.closing.Checksum, Sum: uint64(sum)}
+	if result.Sum != result.Server {
+		result.Result = ChecksumMismatch
+	}
+	return result
+}
+
+// WriteText writes the timeline of each session in a human-readable form.
+func (r Report) WriteText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "recorded by\t%s\n", r.RecordedBy)
+	fmt.Fprintf(tw, "entries\t%d\n", r.Entries)
+	fmt.Fprintf(tw, "sessions\t%d\n", len(r.Sessions))
+	for _, s := range r.Sessions {
+		fmt.Fprintf(tw, "\nsession\t%s\n", s.UUID)
+		if s.Name != "" {
+			fmt.Fprintf(tw, "name\t%s\n", s.Name)
+		}
+		if s.Live {
+			fmt.Fprintf(tw, "live\ttrue\n")
+		} else {
+			fmt.Fprintf(tw, "len\t%d\n", s.Len)
+		}
+		fmt.Fprintf(tw, "state\t%s\n", s.State)
+		fmt.Fprintf(tw, "duration\t%.3fs\n", s.Duration)
+		fmt.Fprintf(tw, "streams\t%d\n", s.Streams)
+		fmt.Fprintf(tw, "acks\t%d\n", s.Acks)
+		fmt.Fprintf(tw, "items sent\t%d\n", s.ItemsSent)
+		retransmitted := fmt.Sprint(len(s.Retransmitted))
+		if len(s.Retransmitted) > 0 {
+			retransmitted += ": " + indexRanges(s.Retransmitted)
+		}
+		fmt.Fprintf(tw, "retransmitted\t%s\n", retransmitted)
+		windows := make([]string, len(s.Windows))
+		for i, window := range s.Windows {
+			windows[i] = fmt.Sprint(window.Window)
+		}
+		fmt.Fprintf(tw, "windows\t%s\n", strings.Join(windows, " "))
+		gaps := make([]string, len(s.Gaps))
+		for i, gap := range s.Gaps {
+			gaps[i] = fmt.Sprintf("%.3fs", gap.Duration)
+		}
+		fmt.Fprintf(tw, "reconnect gaps\t%s\n", strings.Join(gaps, " "))
+		switch s.Checksum.Result {
+		case ChecksumVerified, ChecksumMismatch:
+			fmt.Fprintf(tw, "checksum\t%s (server %d, items %d)\n", s.Checksum.Result, s.Checksum.Server, s.Checksum.Sum)
+		case ChecksumUnverifiable:
+			fmt.Fprintf(tw, "checksum\t%s (server %d)\n", s.Checksum.Result, s.Checksum.Server)
+		default:
+			fmt.Fprintf(tw, "checksum\t%s\n", s.Checksum.Result)
+		}
+		// a line without cells ends the columns of the summary, so that the timeline is aligned on its own
+		fmt.Fprintf(tw, "timeline\n")
+		// the kind and the detail are the last cell, so that the lines without a detail have no trailing padding
+		width := 0
+		for _, e := range s.Events {
+			if len(e.Kind) > width {
+				width = len(e.Kind)
+			}
+		}
+		for _, e := range s.Events {
+			last := strings.TrimRight(fmt.Sprintf("%-*s  %s", width, e.Kind, e.Detail), " ")
+			fmt.Fprintf(tw, "  +%.3fs\tstream %d\t%s\t%s\t%s\n", e.Offset, e.Stream, e.State, e.From, last)
+		}
+	}
+	return errors.Wrap(tw.Flush(), "write report failed")
+}
+
+// WriteJSON writes the timeline of each session as JSON.
+func (r Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return errors.Wrap(enc.Encode(r), "write report failed")
+}
+
+// indexRanges formats the sorted indices as ranges of consecutive indices, e.g. 4-7 12.
+func indexRanges(indices []uint32) string {
+	var ranges []string
+	for i := 0; i < len(indices); {
+		j := i
+		for j+1 < len(indices) && indices[j+1] == indices[j]+1 {
+			j++
+		}
+		if i == j {
+			ranges = append(ranges, fmt.Sprint(indices[i]))
+		} else {
+			ranges = append(ranges, fmt.Sprintf("%d-%d", indices[i], indices[j]))
+		}
+		i = j + 1
+	}
+	return strings.Join(ranges, " ")
+}
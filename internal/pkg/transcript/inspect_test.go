@@ -0,0 +1,111 @@
+package transcript
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	risppb "risp/api/proto/gen/pb-go/github.com/mschristensen/risp/api/build/go"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInspect(t *testing.T) {
+	t.Parallel()
+	id := uuid.New()
+	var entries []*risppb.TranscriptEntry
+	at := time.Duration(0)
+	add := func(stream uint64, direction risppb.Direction, event interface{}) {
+		entry := &risppb.TranscriptEntry{Time: int64(at), Stream: stream, Direction: direction}
+		switch event := event.(type) {
+		case *risppb.ClientMessage:
+			event.Uuid = id[:]
+			entry.Event = &risppb.TranscriptEntry_Client{Client: event}
+		case *risppb.ServerMessage:
+			entry.Event = &risppb.TranscriptEntry_Server{Server: event}
+		case string:
+			entry.Event = &risppb.TranscriptEntry_Error{Error: event}
+		}
+		entries = append(entries, entry)
+		at += 10 * time.Millisecond
+	}
+	item := func(stream uint64, index uint32) {
+		add(stream, risppb.Direction_RECEIVED, &risppb.ServerMessage{State: risppb.ConnectionState_CONNECTED, Index: index, Payload: index + 1})
+	}
+
+	// the first stream is killed part way through the second window
+	add(1, risppb.Direction_SENT, &risppb.ClientMessage{State: risppb.ConnectionState_CONNECTING, Len: 5, Window: 2})
+	add(1, risppb.Direction_RECEIVED, &risppb.ServerMessage{State: risppb.ConnectionState_CONNECTING, Len: 5})
+	item(1, 0)
+	item(1, 1)
+	add(1, risppb.Direction_SENT, &risppb.ClientMessage{State: risppb.ConnectionState_CONNECTED, Ack: 2, Window: 4})
+	add(1, risppb.Direction_SENT, &risppb.ClientMessage{State: risppb.ConnectionState_CONNECTED, Heartbeat: true})
+	item(1, 2)
+	item(1, 3)
+	add(1, risppb.Direction_RECEIVED, "killed")
+	at += time.Second
+
+	// the client resumes from the last item it acknowledged, so the server sends the items after it again
+	add(2, risppb.Direction_SENT, &risppb.ClientMessage{State: risppb.ConnectionState_CONNECTING, Ack: 2, Window: 2})
+	add(2, risppb.Direction_RECEIVED, &risppb.ServerMessage{State: risppb.ConnectionState_CONNECTING, Len: 5})
+	item(2, 2)
+	item(2, 3)
+	add(2, risppb.Direction_SENT, &risppb.ClientMessage{State: risppb.ConnectionState_CONNECTED, Ack: 4, Window: 1})
+	item(2, 4)
+	add(2, risppb.Direction_SENT, &risppb.ClientMessage{State: risppb.ConnectionState_CLOSING, Ack: 5})
+	add(2, risppb.Direction_RECEIVED, &risppb.ServerMessage{State: risppb.ConnectionState_CLOSING, Checksum: 15})
+	add(2, risppb.Direction_SENT, &risppb.ClientMessage{State: risppb.ConnectionState_CLOSED})
+
+	report, err := Inspect(entries)
+	require.NoError(t, err)
+	require.Equal(t, Client, report.RecordedBy)
+	require.Equal(t, len(entries), report.Entries)
+	require.Len(t, report.Sessions, 1)
+	s := report.Sessions[0]
+	require.Equal(t, id.String(), s.UUID)
+	require.Equal(t, uint32(5), s.Len)
+	require.Equal(t, "CLOSED", s.State)
+	require.Equal(t, 2, s.Streams)
+	require.Equal(t, 2, s.Acks)
+	require.Equal(t, 7, s.ItemsSent)
+	require.Equal(t, []uint32{2, 3}, s.Retransmitted)
+	windows := make([]uint32, len(s.Windows))
+	for i, window := range s.Windows {
+		windows[i] = window.Window
+	}
+	require.Equal(t, []uint32{2, 4, 2, 1}, windows)
+	require.Len(t, s.Gaps, 1)
+	require.Equal(t, uint64(2), s.Gaps[0].Stream)
+	require.InDelta(t, 1.01, s.Gaps[0].Duration, 1e-9)
+	require.Equal(t, Checksum{Result: ChecksumVerified, Server: 15, Sum: 15}, s.Checksum)
+
+	kinds := make([]string, len(s.Events))
+	for i, e := range s.Events {
+		kinds[i] = e.Kind
+	}
+	require.Equal(t, []string{
+		"handshake", "handshake reply", "items", "ack", "items", "error",
+		"reconnect", "handshake", "handshake reply", "items", "ack", "items", "closing", "closing reply", "closed",
+	}, kinds)
+	require.Equal(t, "items 2-3 (2 retransmitted)", s.Events[9].Detail)
+	require.Equal(t, Server, s.Events[5].From)
+	require.Equal(t, "CONNECTED", s.Events[5].State)
+
+	// a checksum that is not the sum of the items is a mismatch
+	entries[len(entries)-2].GetServer().Checksum = 16
+	report, err = Inspect(entries)
+	require.NoError(t, err)
+	require.Equal(t, ChecksumMismatch, report.Sessions[0].Checksum.Result)
+
+	var text bytes.Buffer
+	require.NoError(t, report.WriteText(&text))
+	require.Regexp(t, `retransmitted +2: 2-3\n`, text.String())
+	require.Regexp(t, `checksum +mismatch \(server 16, items 15\)\n`, text.String())
+	var buf bytes.Buffer
+	require.NoError(t, report.WriteJSON(&buf))
+	var decoded Report
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &decoded))
+	require.Equal(t, report.Sessions[0].Events, decoded.Sessions[0].Events)
+}
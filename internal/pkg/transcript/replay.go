@@ -0,0 +1,36 @@
+package transcript
+
+import (
+	"fmt"
+
+	risppb "risp/api/proto/gen/pb-go/github.com/mschristensen/risp/api/build/go"
+)
+
+// Divergence is the first point at which a replay departs from the transcript it replays.
+type Divergence struct {
+	Entry int    // the index of the entry at which the replay diverged
+	Want  string // the entry of the transcript
+	Got   string // what the replayed side did instead
+}
+
+// Error implements error.
+func (d *Divergence) Error() string {
+	return fmt.Sprintf("replay diverged at entry %d: transcript has %s, replay has %s", d.Entry, d.Want, d.Got)
+}
+
+// Describe describes an entry of a transcript, e.g. for a divergence.
+func Describe(entry *risppb.TranscriptEntry) string {
+	direction := "sent"
+	if entry.Direction == risppb.Direction_RECEIVED {
+		direction = "received"
+	}
+	switch event := entry.Event.(type) {
+	case *risppb.TranscriptEntry_Client:
+		return fmt.Sprintf("%s %s", direction, event.Client)
+	case *risppb.TranscriptEntry_Server:
+		return fmt.Sprintf("%s %s", direction, event.Server)
+	case *risppb.TranscriptEntry_Error:
+		return fmt.Sprintf("%s error %q", direction, event.Error)
+	}
+	return "an empty entry"
+}
@@ -0,0 +1,194 @@
+// Package transcript implements the recording of the messages exchanged by a RISP client or server.
+//
+// A transcript is a file of length-delimited TranscriptEntry protobuf messages, each preceded by its length
+// as a varint. Each entry records a message sent or received by the side that recorded it, with the time
+// and the stream it was recorded on, or the error with which a message was rejected. Entries are recorded
+// in the order in which the client or server handled them, which is the order in which they must be replayed
+// to reproduce its state transitions.
+//
+// Each entry is written to the file as soon as it is recorded, so that the transcript survives the process
+// dying. A partially written trailing entry is discarded when the transcript is read.
+//
+// Inspect summarises a transcript as the timeline of each client session in it.
+package transcript
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	risppb "risp/api/proto/gen/pb-go/github.com/mschristensen/risp/api/build/go"
+
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/proto"
+)
+
+// maxEntrySize is the largest entry accepted when reading a transcript, which is far larger than any message.
+const maxEntrySize = 1 << 20
+
+// ErrCorrupt indicates that the transcript file could not be parsed.
+var ErrCorrupt = errors.New("corrupt transcript")
+
+// Side is the side of the protocol that recorded a transcript.
+type Side string
+
+// The sides of the protocol.
+const (
+	Client Side = "client"
+	Server Side = "server"
+)
+
+// Recorder records the messages of a client or a server to a transcript file.
+// It is safe for concurrent use, so that the streams of a server, or the clients of a transfer, can share it.
+type Recorder struct {
+	f       *os.File
+	mu      sync.Mutex
+	streams uint64 // the number of streams allocated by NextStream
+	err     error  // the first error writing the transcript
+}
+
+// Create creates the transcript file at the given path, truncating it if it exists.
+func Create(path string) (*Recorder, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return nil, errors.Wrap(err, "create transcript file failed")
+	}
+	return &Recorder{f: f}, nil
+}
+
+// NextStream allocates the ID of a stream to record.
+func (r *Recorder) NextStream() uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.streams++
+	return r.streams
+}
+
+// Record records a ClientMessage or a ServerMessage sent or received on the given stream.
+// Recording never fails the transfer, so any error writing the transcript is returned by Close.
+func (r *Recorder) Record(stream uint64, direction risppb.Direction, msg proto.Message) {
+	entry := &risppb.TranscriptEntry{
+		Stream:    stream,
+		Direction: direction,
+	}
+	switch msg := msg.(type) {
+	case *risppb.ClientMessage:
+		entry.Event = &risppb.TranscriptEntry_Client{Client: msg}
+	case *risppb.ServerMessage:
+		entry.Event = &risppb.TranscriptEntry_Server{Server: msg}
+	default:
+		r.fail(errors.Errorf("cannot record a %T", msg))
+		return
+	}
+	r.write(entry)
+}
+
+// RecordError records the error that ended the given stream, with which the recording side rejected the last
+// message it received if the direction is SENT, or with which the other side ended the stream if it is RECEIVED.
+func (r *Recorder) RecordError(stream uint64, direction risppb.Direction, err error) {
+	r.write(&risppb.TranscriptEntry{
+		Stream:    stream,
+		Direction: direction,
+		Event:     &risppb.TranscriptEntry_Error{Error: err.Error()},
+	})
+}
+
+// write timestamps the entry and appends it to the file.
+func (r *Recorder) write(entry *risppb.TranscriptEntry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return
+	}
+	entry.Time = time.Now().UnixNano()
+	b, err := proto.Marshal(entry)
+	if err != nil {
+		r.err = errors.Wrap(err, "marshal entry failed")
+		return
+	}
+	buf := make([]byte, binary.MaxVarintLen64, binary.MaxVarintLen64+len(b))
+	buf = append(buf[:binary.PutUvarint(buf, uint64(len(b)))], b...)
+	if _, err := r.f.Write(buf); err != nil {
+		r.err = errors.Wrap(err, "write entry failed")
+	}
+}
+
+// fail records the first error of the recorder.
+func (r *Recorder) fail(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err == nil {
+		r.err = err
+	}
+}
+
+// Close closes the transcript file, returning the first error recording the transcript if there was one.
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.f.Close(); err != nil && r.err == nil {
+		r.err = errors.Wrap(err, "close transcript file failed")
+	}
+	return r.err
+}
+
+// Load reads the transcript file at the given path.
+func Load(path string) ([]*risppb.TranscriptEntry, error) {
+	f, err := os.Open(path) // nolint: gosec // the transcript is given by the user
+	if err != nil {
+		return nil, errors.Wrap(err, "open transcript file failed")
+	}
+	defer f.Close() // nolint: errcheck // the file is only read
+	entries, err := Read(f)
+	if err != nil {
+		return nil, errors.Wrapf(err, "read transcript %s failed", path)
+	}
+	return entries, nil
+}
+
+// Read reads the entries of a transcript, discarding a partially written trailing entry.
+func Read(r io.Reader) ([]*risppb.TranscriptEntry, error) {
+	br := bufio.NewReader(r)
+	var entries []*risppb.TranscriptEntry
+	for {
+		size, err := binary.ReadUvarint(br)
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, errors.Wrapf(ErrCorrupt, "entry %d: %s", len(entries), err)
+		}
+		if size > maxEntrySize {
+			return nil, errors.Wrapf(ErrCorrupt, "entry %d: size %d too large", len(entries), size)
+		}
+		b := make([]byte, size)
+		if _, err := io.ReadFull(br, b); err != nil {
+			if errors.Is(err, io.ErrUnexpectedEOF) {
+				return entries, nil
+			}
+			return nil, errors.Wrap(err, "read entry failed")
+		}
+		entry := &risppb.TranscriptEntry{}
+		if err := proto.Unmarshal(b, entry); err != nil {
+			return nil, errors.Wrapf(ErrCorrupt, "entry %d: %s", len(entries), err)
+		}
+		entries = append(entries, entry)
+	}
+}
+
+// RecordedBy returns the side that recorded the transcript, which is told by the direction of its client messages.
+func RecordedBy(entries []*risppb.TranscriptEntry) (Side, error) {
+	for _, entry := range entries {
+		if entry.GetClient() == nil {
+			continue
+		}
+		if entry.Direction == risppb.Direction_SENT {
+			return Client, nil
+		}
+		return Server, nil
+	}
+	return "", errors.Wrap(ErrCorrupt, "no client messages")
+}
@@ -0,0 +1,47 @@
+package transcript
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	risppb "risp/api/proto/gen/pb-go/github.com/mschristensen/risp/api/build/go"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecord(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "transcript")
+	r, err := Create(path)
+	require.NoError(t, err)
+	stream := r.NextStream()
+	r.Record(stream, risppb.Direction_SENT, &risppb.ClientMessage{State: risppb.ConnectionState_CONNECTING, Len: 3})
+	r.Record(stream, risppb.Direction_RECEIVED, &risppb.ServerMessage{State: risppb.ConnectionState_CONNECTED, Index: 1, Payload: 7})
+	r.RecordError(stream, risppb.Direction_SENT, errors.New("rejected"))
+	require.NoError(t, r.Close())
+
+	// simulate the process dying part way through writing an entry
+	raw, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o600)
+	require.NoError(t, err)
+	_, err = raw.Write([]byte{20, 1, 2})
+	require.NoError(t, err)
+	require.NoError(t, raw.Close())
+
+	entries, err := Load(path)
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+	for _, entry := range entries {
+		require.Equal(t, stream, entry.Stream)
+		require.NotZero(t, entry.Time)
+	}
+	require.Equal(t, uint32(3), entries[0].GetClient().Len)
+	require.Equal(t, risppb.Direction_RECEIVED, entries[1].Direction)
+	require.Equal(t, uint32(7), entries[1].GetServer().Payload)
+	require.Equal(t, "rejected", entries[2].GetError())
+
+	side, err := RecordedBy(entries)
+	require.NoError(t, err)
+	require.Equal(t, Client, side)
+}
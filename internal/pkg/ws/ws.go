@@ -0,0 +1,279 @@
+// Package ws carries the RISP protocol over a WebSocket, for networks that block the HTTP/2 streams of gRPC.
+//
+// A WebSocket stands in for the Connect stream of the gRPC service, so the client and the server run the same
+// handshake, windows, acks and session resumption over either transport. Each ClientMessage and ServerMessage
+// is sent in a binary frame holding its protobuf encoding. A server that fails the stream sends its gRPC status
+// in a final text frame holding the JSON encoding of a google.rpc.Status, so that the client handles the error
+// as it would over gRPC, and otherwise closes the WebSocket once the stream ends.
+//
+// The trace context of the client is propagated in the headers of the WebSocket handshake.
+package ws
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+
+	risppb "risp/api/proto/gen/pb-go/github.com/mschristensen/risp/api/build/go"
+
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"golang.org/x/net/websocket"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// Path is the path of the WebSocket endpoint of a server.
+const Path = "/v1/connect"
+
+// maxFrame is the largest frame accepted, which is far larger than any message of the protocol.
+const maxFrame = 1 << 20
+
+// frame is a frame received on a WebSocket.
+type frame struct {
+	data []byte
+	text bool
+}
+
+// frames receives whole frames, keeping track of their type.
+var frames = websocket.Codec{
+	Unmarshal: func(data []byte, payloadType byte, v interface{}) error {
+		f, ok := v.(*frame)
+		if !ok {
+			return errors.New("unexpected frame receiver")
+		}
+		f.data = data
+		f.text = payloadType == websocket.TextFrame
+		return nil
+	},
+}
+
+// recv receives the next message on the WebSocket into msg. A text frame holds the status of a failed stream,
+// which is returned as its gRPC error.
+func recv(conn *websocket.Conn, msg proto.Message) error {
+	var f frame
+	if err := frames.Receive(conn, &f); err != nil {
+		return err
+	}
+	if f.text {
+		st := &spb.Status{}
+		if err := protojson.Unmarshal(f.data, st); err != nil {
+			return errors.Wrap(err, "unmarshal status failed")
+		}
+		return status.ErrorProto(st)
+	}
+	return errors.Wrap(proto.Unmarshal(f.data, msg), "unmarshal message failed")
+}
+
+// send sends the message on the WebSocket in a binary frame.
+func send(conn *websocket.Conn, msg proto.Message) error {
+	b, err := proto.Marshal(msg)
+	if err != nil {
+		return errors.Wrap(err, "marshal message failed")
+	}
+	return websocket.Message.Send(conn, b)
+}
+
+// sendMsg sends the message, which must be a protobuf message, on the WebSocket.
+func sendMsg(conn *websocket.Conn, m interface{}) error {
+	msg, ok := m.(proto.Message)
+	if !ok {
+		return errors.Errorf("cannot send %T, which is not a protobuf message", m)
+	}
+	return send(conn, msg)
+}
+
+// recvMsg receives the next message on the WebSocket into m, which must be a protobuf message.
+func recvMsg(conn *websocket.Conn, m interface{}) error {
+	msg, ok := m.(proto.Message)
+	if !ok {
+		return errors.Errorf("cannot receive into %T, which is not a protobuf message", m)
+	}
+	return recv(conn, msg)
+}
+
+// Handler returns an HTTP handler that accepts WebSockets from any origin, and serves each as a stream by calling
+// serve, as the gRPC server calls the Connect method for each stream.
+func Handler(serve func(*ServerStream) error) http.Handler {
+	return websocket.Server{
+		Handler: func(conn *websocket.Conn) {
+			conn.MaxPayloadBytes = maxFrame
+			conn.PayloadType = websocket.BinaryFrame
+			r := conn.Request()
+			s := &ServerStream{
+				conn: conn,
+				ctx:  otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header)),
+			}
+			s.close(serve(s))
+		},
+	}
+}
+
+// ServerStream is the server side of a stream carried over a WebSocket, implementing risppb.RISP_ConnectServer.
+// A WebSocket has no headers or trailers once it is open, so the stream metadata is discarded.
+type ServerStream struct {
+	conn *websocket.Conn
+	ctx  context.Context
+}
+
+// Send implements risppb.RISP_ConnectServer.
+func (s *ServerStream) Send(msg *risppb.ServerMessage) error {
+	return send(s.conn, msg)
+}
+
+// Recv implements risppb.RISP_ConnectServer.
+func (s *ServerStream) Recv() (*risppb.ClientMessage, error) {
+	msg := &risppb.ClientMessage{}
+	if err := recv(s.conn, msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// Context implements grpc.ServerStream, returning the context of the WebSocket request, which carries the trace
+// context of the client.
+func (s *ServerStream) Context() context.Context {
+	return s.ctx
+}
+
+// SetHeader implements grpc.ServerStream. The header is discarded.
+func (s *ServerStream) SetHeader(metadata.MD) error {
+	return nil
+}
+
+// SendHeader implements grpc.ServerStream. The header is discarded.
+func (s *ServerStream) SendHeader(metadata.MD) error {
+	return nil
+}
+
+// SetTrailer implements grpc.ServerStream. The trailer is discarded.
+func (s *ServerStream) SetTrailer(metadata.MD) {}
+
+// SendMsg implements grpc.ServerStream.
+func (s *ServerStream) SendMsg(m interface{}) error {
+	return sendMsg(s.conn, m)
+}
+
+// RecvMsg implements grpc.ServerStream.
+func (s *ServerStream) RecvMsg(m interface{}) error {
+	return recvMsg(s.conn, m)
+}
+
+// RemoteAddr returns the address of the client.
+func (s *ServerStream) RemoteAddr() string {
+	return s.conn.Request().RemoteAddr
+}
+
+// close sends the status of the stream to the client if it failed, and closes the WebSocket.
+func (s *ServerStream) close(err error) {
+	if err != nil {
+		if b, merr := protojson.Marshal(status.Convert(err).Proto()); merr == nil {
+			_ = websocket.Message.Send(s.conn, string(b))
+		}
+	}
+	_ = s.conn.Close()
+}
+
+// ClientStream is the client side of a stream carried over a WebSocket, implementing risppb.RISP_ConnectClient.
+// A WebSocket has no headers or trailers once it is open, so the stream metadata is always empty.
+type ClientStream struct {
+	conn *websocket.Conn
+	ctx  context.Context
+	done chan struct{} // closed when the WebSocket is closed
+	once sync.Once
+}
+
+// Dial opens a stream over a WebSocket to the server at the given address (host:port).
+// The stream is closed when the context is done.
+func Dial(ctx context.Context, addr string) (*ClientStream, error) {
+	config, err := websocket.NewConfig(fmt.Sprintf("ws://%s%s", addr, Path), fmt.Sprintf("http://%s", addr))
+	if err != nil {
+		return nil, errors.Wrap(err, "new config failed")
+	}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(config.Header))
+	var d net.Dialer
+	nc, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, errors.Wrapf(err, "connect to %s failed", addr)
+	}
+	conn, err := websocket.NewClient(config, nc)
+	if err != nil {
+		_ = nc.Close()
+		return nil, errors.Wrapf(err, "open WebSocket to %s failed", addr)
+	}
+	conn.MaxPayloadBytes = maxFrame
+	conn.PayloadType = websocket.BinaryFrame
+	s := &ClientStream{
+		conn: conn,
+		ctx:  ctx,
+		done: make(chan struct{}),
+	}
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = s.Close()
+		case <-s.done:
+		}
+	}()
+	return s, nil
+}
+
+// Send implements risppb.RISP_ConnectClient.
+func (s *ClientStream) Send(msg *risppb.ClientMessage) error {
+	return send(s.conn, msg)
+}
+
+// Recv implements risppb.RISP_ConnectClient.
+func (s *ClientStream) Recv() (*risppb.ServerMessage, error) {
+	msg := &risppb.ServerMessage{}
+	if err := recv(s.conn, msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// Context implements grpc.ClientStream.
+func (s *ClientStream) Context() context.Context {
+	return s.ctx
+}
+
+// Header implements grpc.ClientStream. The header is always empty.
+func (s *ClientStream) Header() (metadata.MD, error) {
+	return metadata.MD{}, nil
+}
+
+// Trailer implements grpc.ClientStream. The trailer is always empty.
+func (s *ClientStream) Trailer() metadata.MD {
+	return metadata.MD{}
+}
+
+// SendMsg implements grpc.ClientStream.
+func (s *ClientStream) SendMsg(m interface{}) error {
+	return sendMsg(s.conn, m)
+}
+
+// RecvMsg implements grpc.ClientStream.
+func (s *ClientStream) RecvMsg(m interface{}) error {
+	return recvMsg(s.conn, m)
+}
+
+// CloseSend implements grpc.ClientStream. A WebSocket cannot be half-closed, so the whole stream is closed.
+func (s *ClientStream) CloseSend() error {
+	return s.Close()
+}
+
+// Close closes the WebSocket. It is safe to call more than once. The server closes the WebSocket once the stream
+// ends, in which case the close frame cannot be sent, so the error closing the WebSocket is not reported.
+func (s *ClientStream) Close() error {
+	s.once.Do(func() {
+		close(s.done)
+		_ = s.conn.Close()
+	})
+	return nil
+}
@@ -30,3 +30,14 @@ func Sum(sequence ...*uint32) (uint64, error) {
 	}
 	return sum, nil
 }
+
+// Running is the checksum of a sequence of unbounded length, which is updated as the values arrive.
+// The sum wraps around on overflow, so it is the sum of the values modulo 2^64.
+type Running uint64
+
+// Add adds the values to the checksum.
+func (r *Running) Add(values ...uint32) {
+	for _, v := range values {
+		*r += Running(v)
+	}
+}